@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"parking-lot/config"
+	"parking-lot/domain"
+	"parking-lot/repository"
+	"parking-lot/service"
+)
+
+const usage = `Usage: apikey [command]
+
+Commands:
+  create <name> <role>   create a key for a gate, attendant, admin or reporting client and print it
+  list                   show all keys with their role and whether they are revoked
+  revoke <id>            revoke a key, requests using it are rejected from then on`
+
+// errUsage is returned for a missing or unknown command
+var errUsage = errors.New(usage)
+
+func main() {
+	config.InitAppConfig()
+
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+	if config.GetAppConfig().Storage.Driver == config.StorageDriverMemory {
+		log.Fatal("API keys cannot be managed for the memory storage, use the admin API of the running server instead")
+	}
+
+	db, err := config.InitDBConnection()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	authService := service.NewAuthService(repository.NewAPIKeyRepository(db), "")
+	err = run(context.Background(), authService, os.Args[1:], os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run runs the command in args, printing keys and the key list to out
+func run(ctx context.Context, authService domain.AuthService, args []string, out io.Writer) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "create":
+		if len(args) < 3 {
+			return errUsage
+		}
+
+		apiKey, key, err := authService.CreateAPIKey(ctx, args[1], domain.Role(args[2]))
+		if err != nil {
+			return fmt.Errorf("failed to create API key: %w", err)
+		}
+
+		log.Printf("Created %s key %d for %s, store it now as it cannot be shown again:\n", apiKey.Role, apiKey.ID, apiKey.Name)
+		fmt.Fprintln(out, key)
+	case "list":
+		return printAPIKeys(ctx, authService, out)
+	case "revoke":
+		if len(args) < 2 {
+			return errUsage
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid API key ID %q: %w", args[1], err)
+		}
+
+		err = authService.RevokeAPIKey(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to revoke API key: %w", err)
+		}
+		log.Printf("Revoked API key %d\n", id)
+	default:
+		return errUsage
+	}
+
+	return nil
+}
+
+func printAPIKeys(ctx context.Context, authService domain.AuthService, out io.Writer) error {
+	keys, err := authService.GetAPIKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get API keys: %w", err)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tROLE\tPREFIX\tCREATED\tREVOKED")
+	for _, key := range keys {
+		revoked := "-"
+		if key.RevokedAt != nil {
+			revoked = key.RevokedAt.Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Name, key.Role, key.Prefix, key.CreatedAt.Format(time.DateTime), revoked)
+	}
+	return w.Flush()
+}
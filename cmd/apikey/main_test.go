@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"parking-lot/domain"
+	"parking-lot/repository"
+	"parking-lot/service"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		// wantErr is the error expected, or any error for errAny
+		wantErr error
+	}{
+		{
+			name:    "rejects a missing command",
+			wantErr: errUsage,
+		},
+		{
+			name:    "rejects an unknown command",
+			args:    []string{"rotate", "1"},
+			wantErr: errUsage,
+		},
+		{
+			name:    "rejects a create without a role",
+			args:    []string{"create", "North gate"},
+			wantErr: errUsage,
+		},
+		{
+			name:    "rejects a create of an unknown role",
+			args:    []string{"create", "North gate", "superuser"},
+			wantErr: errAny,
+		},
+		{
+			name:    "rejects a revoke without an ID",
+			args:    []string{"revoke"},
+			wantErr: errUsage,
+		},
+		{
+			name:    "rejects an invalid ID",
+			args:    []string{"revoke", "north"},
+			wantErr: errAny,
+		},
+		{
+			name:    "rejects an unknown ID",
+			args:    []string{"revoke", "9"},
+			wantErr: errAny,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authService := service.NewAuthService(repository.NewMemoryAPIKeyRepository(), "")
+
+			err := run(context.Background(), authService, tt.args, io.Discard)
+			if err == nil || tt.wantErr != errAny && !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunManagesKeys(t *testing.T) {
+	ctx := context.Background()
+	authService := service.NewAuthService(repository.NewMemoryAPIKeyRepository(), "")
+
+	var out bytes.Buffer
+	err := run(ctx, authService, []string{"create", "North gate", "gate"}, &out)
+	if err != nil {
+		t.Fatalf("create error = %v", err)
+	}
+	key := strings.TrimSpace(out.String())
+	apiKey, err := authService.Authenticate(ctx, key)
+	if err != nil || apiKey == nil || apiKey.Name != "North gate" || apiKey.Role != domain.RoleGate {
+		t.Fatalf("got key %+v, %v for the printed key, want the North gate key", apiKey, err)
+	}
+
+	out.Reset()
+	err = run(ctx, authService, []string{"list"}, &out)
+	if err != nil {
+		t.Fatalf("list error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], "North gate") || !strings.HasSuffix(lines[1], "-") {
+		t.Fatalf("got list\n%s\nwant the North gate key, not revoked", out.String())
+	}
+	if strings.Contains(out.String(), key) {
+		t.Errorf("got the key in the list, want only its prefix")
+	}
+
+	err = run(ctx, authService, []string{"revoke", "1"}, io.Discard)
+	if err != nil {
+		t.Fatalf("revoke error = %v", err)
+	}
+	apiKey, err = authService.Authenticate(ctx, key)
+	if err != nil || apiKey != nil {
+		t.Errorf("got key %+v, %v for the revoked key, want it rejected", apiKey, err)
+	}
+
+	out.Reset()
+	err = run(ctx, authService, []string{"list"}, &out)
+	if err != nil {
+		t.Fatalf("list error = %v", err)
+	}
+	if strings.HasSuffix(strings.TrimSpace(out.String()), "-") {
+		t.Errorf("got list\n%s\nwant the key revoked", out.String())
+	}
+}
+
+// errAny stands for any error in the tests
+var errAny = errors.New("any error")
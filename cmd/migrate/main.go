@@ -1,15 +1,35 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
 	"log"
+	"os"
+	"strconv"
 
 	"parking-lot/config"
+	"parking-lot/domain"
+	"parking-lot/repository"
+	"parking-lot/service"
 )
 
+const usage = `Usage: migrate [command]
+
+Commands:
+  up                     apply all pending migrations and reconcile parking spots (default)
+  down                   revert the most recently applied migration
+  status                 show applied and pending migrations
+  to <version>           migrate up or down to the given version
+  reconcile [--dry-run]  bring parking spots in line with the configured layout`
+
 func main() {
 	config.InitAppConfig()
 
-	log.Println("Starting database migration...")
+	command := "up"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
 
 	db, err := config.InitDBConnection()
 	if err != nil {
@@ -17,15 +37,99 @@ func main() {
 	}
 	defer db.Close()
 
-	err = config.CreateTables(db)
-	if err != nil {
-		log.Fatalf("Failed to create tables: %v", err)
+	switch command {
+	case "up":
+		migrateUp(db)
+	case "down":
+		log.Println("Reverting last migration...")
+		err = config.MigrateDown(db)
+		if err != nil {
+			log.Fatalf("Failed to revert migration: %v", err)
+		}
+		log.Println("Migration reverted successfully.")
+	case "status":
+		printStatus(db)
+	case "to":
+		if len(os.Args) < 3 {
+			log.Fatal(usage)
+		}
+		version, err := strconv.Atoi(os.Args[2])
+		if err != nil {
+			log.Fatalf("Invalid version %q: %v", os.Args[2], err)
+		}
+
+		log.Printf("Migrating database to version %d...\n", version)
+		err = config.MigrateTo(db, version)
+		if err != nil {
+			log.Fatalf("Failed to migrate: %v", err)
+		}
+		log.Println("Database migration completed successfully.")
+	case "reconcile":
+		dryRun := len(os.Args) > 2 && os.Args[2] == "--dry-run"
+		reconcileLayout(db, dryRun)
+	default:
+		log.Fatal(usage)
 	}
+}
 
-	err = config.InitializeParkingSpots(db)
+func migrateUp(db *sql.DB) {
+	log.Println("Starting database migration...")
+
+	err := config.MigrateUp(db)
 	if err != nil {
-		log.Fatalf("Failed to initialize parking spots: %v", err)
+		log.Fatalf("Failed to apply migrations: %v", err)
 	}
 
+	reconcileLayout(db, false)
+
 	log.Println("Database migration completed successfully.")
 }
+
+func reconcileLayout(db *sql.DB, dryRun bool) {
+	parkingRepo := repository.NewParkingRepository(db)
+	if config.GetAppConfig().Storage.Driver == config.StorageDriverSQLite {
+		parkingRepo = repository.NewSQLiteParkingRepository(db)
+	}
+
+	diff, err := service.NewLayoutService(parkingRepo).ReconcileLayout(context.Background(), dryRun)
+	if err != nil {
+		log.Fatalf("Failed to reconcile parking spots: %v", err)
+	}
+
+	if !dryRun {
+		return
+	}
+
+	if diff.IsEmpty() {
+		fmt.Println("Parking spots already match the configured layout.")
+		return
+	}
+	printSpots("+", "add", diff.Added)
+	printSpots("+", "reactivate", diff.Reactivated)
+	printSpots("-", "deactivate", diff.Deactivated)
+	for _, spot := range diff.Retyped {
+		printSpots("~", fmt.Sprintf("retype to %s %s", spot.SizeClass, spot.VehicleType), []domain.ParkingSpot{spot})
+	}
+	printSpots("!", "keep, vehicle parked", diff.Occupied)
+}
+
+func printSpots(sign, action string, spots []domain.ParkingSpot) {
+	for _, spot := range spots {
+		fmt.Printf("%s %d-%d-%d\t%s\n", sign, spot.Floor, spot.Row, spot.Column, action)
+	}
+}
+
+func printStatus(db *sql.DB) {
+	statuses, err := config.GetMigrationStatus(db)
+	if err != nil {
+		log.Fatalf("Failed to get migration status: %v", err)
+	}
+
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = "applied at " + status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+}
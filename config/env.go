@@ -5,7 +5,9 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 	"parking-lot/domain"
@@ -16,10 +18,25 @@ var (
 	appConfig AppConfig
 )
 
+const (
+	StorageDriverPostgres = "postgres"
+	StorageDriverSQLite   = "sqlite"
+	StorageDriverMemory   = "memory"
+)
+
 type AppConfig struct {
-	DB      DBConfig
-	Parking ParkingConfig
-	Server  ServerConfig
+	// VehicleTypes holds the vehicle types the parking lot accepts
+	VehicleTypes *domain.VehicleTypeRegistry
+	DB           DBConfig
+	Parking      ParkingConfig
+	Server       ServerConfig
+	Storage      StorageConfig
+	Tariff       TariffConfig
+	Reservation  ReservationConfig
+	Admin        AdminConfig
+	Webhook      WebhookConfig
+	Stream       StreamConfig
+	GateSocket   GateSocketConfig
 }
 
 type DBConfig struct {
@@ -29,6 +46,10 @@ type DBConfig struct {
 	Password string
 	DBName   string
 	SSLMode  string
+	// Path is the database file used by the sqlite driver
+	Path string
+	// MaxOpenConns limits the connection pool, 0 for no limit
+	MaxOpenConns int
 }
 
 type ParkingConfig struct {
@@ -36,10 +57,78 @@ type ParkingConfig struct {
 	Rows            int
 	Columns         int
 	FloorVehicleMap map[int]domain.VehicleType
+	// VehicleTypes is the registry the floor and spot vehicle types are taken from
+	VehicleTypes *domain.VehicleTypeRegistry
+	// SpotRules override the floor's vehicle type and size class for rows or ranges of spots
+	SpotRules []SpotRule
+	// SizeClassFallback lets vehicles park on a larger spot when no spot of their size class is free
+	SizeClassFallback bool
+	// AllocationStrategy decides which available spot a vehicle is parked on
+	AllocationStrategy domain.AllocationStrategy
+	Gates              []domain.Gate
 }
 
 type ServerConfig struct {
 	Port string
+	// ShutdownTimeout is how long in-flight requests may take to finish once the server is stopping
+	ShutdownTimeout time.Duration
+	// RequestTimeout bounds the database work of a single request, 0 disables the deadline
+	RequestTimeout time.Duration
+	// CORSAllowedOrigins are the origins browsers may call the API from, none when empty
+	CORSAllowedOrigins []string
+	// IdempotencyTTL is how long responses are replayed to requests retried with the same idempotency key
+	IdempotencyTTL time.Duration
+}
+
+type StorageConfig struct {
+	Driver string
+}
+
+type AdminConfig struct {
+	// APIToken is accepted as an admin API key, e.g. to work before any key was created. Disabled when empty.
+	APIToken string
+}
+
+type WebhookConfig struct {
+	// MaxAttempts is how often a delivery is tried before it is dead
+	MaxAttempts int
+	// InitialBackoff is the wait after the first failed attempt, doubled after every further one up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Timeout bounds a single delivery request
+	Timeout time.Duration
+	// PollInterval is how often the dispatcher looks for due deliveries
+	PollInterval time.Duration
+	// Retention is how long delivered events are kept
+	Retention time.Duration
+}
+
+type StreamConfig struct {
+	// BufferSize is how many of the latest events are kept for clients resuming the event stream
+	BufferSize int
+	// HeartbeatInterval is how often an idle event stream sends a comment, so proxies keep it open
+	HeartbeatInterval time.Duration
+	// PollInterval is how often the parking records are looked up for spots taken or freed by other instances
+	PollInterval time.Duration
+}
+
+type GateSocketConfig struct {
+	// HeartbeatInterval is how often gates are pinged, a gate silent for two intervals is disconnected
+	HeartbeatInterval time.Duration
+}
+
+type ReservationConfig struct {
+	// GracePeriod is how early a reserved vehicle can arrive, and how late before the reservation is released
+	GracePeriod time.Duration
+	// Hold is how long before its start a reservation keeps walk-ins off its spot, at least the grace period
+	Hold time.Duration
+	// ExpiryInterval is how often reservations of vehicles that did not show up are released
+	ExpiryInterval time.Duration
+}
+
+type TariffConfig struct {
+	VehicleTariffs    map[domain.VehicleType]domain.Tariff
+	LostTicketPenalty int64
 }
 
 // InitAppConfig is a syntax sugar to initialize the application configuration
@@ -55,10 +144,20 @@ func GetAppConfig() AppConfig {
 			log.Println("Warning: .env file not found, using default environment variables")
 		}
 
+		vehicleTypes := getVehicleTypes()
+
 		appConfig = AppConfig{
-			DB:      getDBConfig(),
-			Parking: getParkingConfig(),
-			Server:  getServerConfig(),
+			VehicleTypes: vehicleTypes,
+			DB:           getDBConfig(),
+			Parking:      getParkingConfig(vehicleTypes),
+			Server:       getServerConfig(),
+			Storage:      getStorageConfig(),
+			Tariff:       getTariffConfig(vehicleTypes),
+			Reservation:  getReservationConfig(),
+			Admin:        getAdminConfig(),
+			Webhook:      getWebhookConfig(),
+			Stream:       getStreamConfig(),
+			GateSocket:   getGateSocketConfig(),
 		}
 	})
 
@@ -85,10 +184,63 @@ func getDBConfig() DBConfig {
 		Password: getEnv("DB_PASSWORD", "postgres"),
 		DBName:   getEnv("DB_NAME", "parking_lot"),
 		SSLMode:  getEnv("DB_SSLMODE", "disable"),
+		Path:     getEnv("DB_PATH", "parking_lot.db"),
+		// readiness fails while every pooled connection is in use
+		MaxOpenConns: int(getEnvInt64("DB_MAX_OPEN_CONNS", 25)),
+	}
+}
+
+// getVehicleTypes returns the registry of the vehicle types listed in VEHICLE_TYPES. Built-in types
+// keep their defaults unless overridden, other types park on standard spots and need a license plate.
+func getVehicleTypes() *domain.VehicleTypeRegistry {
+	builtin := domain.NewVehicleTypeRegistry(domain.BuiltinVehicleTypes...)
+
+	defaultTypes := make([]string, 0, len(builtin.Types()))
+	for _, vehicleType := range builtin.Types() {
+		defaultTypes = append(defaultTypes, string(vehicleType))
+	}
+
+	var definitions []domain.VehicleTypeDefinition
+	for _, name := range strings.Split(getEnv("VEHICLE_TYPES", strings.Join(defaultTypes, ",")), ",") {
+		vehicleType := domain.VehicleType(strings.ToLower(strings.TrimSpace(name)))
+		if vehicleType == "" {
+			continue
+		}
+
+		definition, ok := builtin.Get(vehicleType)
+		if !ok {
+			definition = domain.VehicleTypeDefinition{
+				Type:                 vehicleType,
+				SizeClass:            domain.SizeStandard,
+				RequiresLicensePlate: true,
+			}
+		}
+
+		prefix := fmt.Sprintf("VEHICLE_TYPE_%s_", strings.ToUpper(string(vehicleType)))
+		sizeClass := domain.SizeClass(getEnv(prefix+"SIZE_CLASS", string(definition.SizeClass)))
+		if sizeClass.IsValid() {
+			definition.SizeClass = sizeClass
+		} else {
+			log.Printf("Warning: invalid size class for vehicle type %v, using default size class %v\n", vehicleType, definition.SizeClass)
+		}
+
+		requiresLicensePlate, err := strconv.ParseBool(getEnv(prefix+"LICENSE_PLATE_REQUIRED", strconv.FormatBool(definition.RequiresLicensePlate)))
+		if err == nil {
+			definition.RequiresLicensePlate = requiresLicensePlate
+		}
+
+		definitions = append(definitions, definition)
+	}
+
+	if len(definitions) == 0 {
+		log.Println("Warning: no vehicle types configured, using the built-in vehicle types")
+		return builtin
 	}
+
+	return domain.NewVehicleTypeRegistry(definitions...)
 }
 
-func getParkingConfig() ParkingConfig {
+func getParkingConfig(vehicleTypes *domain.VehicleTypeRegistry) ParkingConfig {
 	floors, err := strconv.Atoi(getEnv("PARKING_FLOORS", "3"))
 	if err != nil {
 		floors = 3
@@ -104,28 +256,212 @@ func getParkingConfig() ParkingConfig {
 		columns = 5
 	}
 
-	// get floor vehicle map
+	// get floor vehicle map, floors default to cars or the first vehicle type if cars are not accepted
+	defaultVehicleType := domain.Car
+	if !vehicleTypes.IsValid(defaultVehicleType) {
+		defaultVehicleType = vehicleTypes.Types()[0]
+	}
+
 	floorVehicleMap := make(map[int]domain.VehicleType)
 	for f := 1; f <= floors; f++ {
-		vehicleType := domain.VehicleType(getEnv(fmt.Sprintf("PARKING_FLOOR_%v_VEHICLE_TYPE", f), string(domain.Car)))
-		if vehicleType.IsValid() {
+		vehicleType := domain.VehicleType(getEnv(fmt.Sprintf("PARKING_FLOOR_%v_VEHICLE_TYPE", f), string(defaultVehicleType)))
+		if vehicleTypes.IsValid(vehicleType) {
 			floorVehicleMap[f] = vehicleType
 		} else {
-			log.Printf("Warning: invalid vehicle type for floor %v, using default vehicle type %v\n", f, domain.Car)
-			floorVehicleMap[f] = domain.Car
+			log.Printf("Warning: invalid vehicle type %v for floor %v, must be %v, using default vehicle type %v\n", vehicleType, f, vehicleTypes, defaultVehicleType)
+			floorVehicleMap[f] = defaultVehicleType
 		}
 	}
 
+	spotRules, err := parseSpotRules(getEnv("PARKING_SPOT_TYPES", ""), vehicleTypes)
+	if err != nil {
+		log.Printf("Warning: invalid spot types, using the floor vehicle types: %v\n", err)
+		spotRules = nil
+	}
+
+	sizeClassFallback, err := strconv.ParseBool(getEnv("PARKING_SIZE_CLASS_FALLBACK", "true"))
+	if err != nil {
+		sizeClassFallback = true
+	}
+
+	allocationStrategy := domain.AllocationStrategy(getEnv("PARKING_ALLOCATION_STRATEGY", string(domain.AllocationFirstFit)))
+	if !allocationStrategy.IsValid() {
+		log.Printf("Warning: invalid allocation strategy %v, using default allocation strategy %v\n", allocationStrategy, domain.AllocationFirstFit)
+		allocationStrategy = domain.AllocationFirstFit
+	}
+
 	return ParkingConfig{
-		Floors:          floors,
-		Rows:            rows,
-		Columns:         columns,
-		FloorVehicleMap: floorVehicleMap,
+		Floors:             floors,
+		Rows:               rows,
+		Columns:            columns,
+		FloorVehicleMap:    floorVehicleMap,
+		VehicleTypes:       vehicleTypes,
+		SpotRules:          spotRules,
+		SizeClassFallback:  sizeClassFallback,
+		AllocationStrategy: allocationStrategy,
+		Gates:              getGates(),
 	}
 }
 
+// getGates returns the gates of the parking lot, numbered from 1
+func getGates() []domain.Gate {
+	count := getEnvInt64("PARKING_GATES", 1)
+
+	var gates []domain.Gate
+	for g := int64(1); g <= count; g++ {
+		prefix := fmt.Sprintf("PARKING_GATE_%v_", g)
+
+		direction := domain.GateDirection(getEnv(prefix+"DIRECTION", string(domain.GateBoth)))
+		if !direction.IsValid() {
+			log.Printf("Warning: invalid direction for gate %v, using default direction %v\n", g, domain.GateBoth)
+			direction = domain.GateBoth
+		}
+
+		gates = append(gates, domain.Gate{
+			ID:        g,
+			Name:      getEnv(prefix+"NAME", fmt.Sprintf("Gate %v", g)),
+			Direction: direction,
+			Position: domain.Position{
+				Floor:  int(getEnvInt64(prefix+"FLOOR", 1)),
+				Row:    int(getEnvInt64(prefix+"ROW", 1)),
+				Column: int(getEnvInt64(prefix+"COLUMN", 1)),
+			},
+		})
+	}
+
+	return gates
+}
+
 func getServerConfig() ServerConfig {
 	return ServerConfig{
-		Port: getEnv("PORT", "8080"),
+		Port:               getEnv("PORT", "8080"),
+		ShutdownTimeout:    time.Duration(getEnvInt64("SHUTDOWN_TIMEOUT_SECONDS", 30)) * time.Second,
+		RequestTimeout:     time.Duration(getEnvInt64("REQUEST_TIMEOUT_SECONDS", 10)) * time.Second,
+		CORSAllowedOrigins: getCORSAllowedOrigins(),
+		IdempotencyTTL:     time.Duration(getEnvInt64("IDEMPOTENCY_TTL_HOURS", 24)) * time.Hour,
+	}
+}
+
+func getCORSAllowedOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(getEnv("CORS_ALLOWED_ORIGINS", ""), ",") {
+		origin = strings.TrimSpace(origin)
+		if origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+func getStorageConfig() StorageConfig {
+	driver := getEnv("STORAGE_DRIVER", StorageDriverPostgres)
+	if driver != StorageDriverPostgres && driver != StorageDriverSQLite && driver != StorageDriverMemory {
+		log.Printf("Warning: invalid storage driver %v, using default storage driver %v\n", driver, StorageDriverPostgres)
+		driver = StorageDriverPostgres
+	}
+
+	return StorageConfig{
+		Driver: driver,
+	}
+}
+
+// defaultTariffs holds the default first hour rate, hourly rate and daily cap per vehicle type,
+// vehicle types without one default to the car tariff
+var defaultTariffs = map[domain.VehicleType][3]int64{
+	domain.Car:             {5000, 3000, 40000},
+	domain.Motorcycle:      {2000, 2000, 15000},
+	domain.Bicycle:         {1000, 500, 5000},
+	domain.Truck:           {10000, 6000, 80000},
+	domain.Van:             {7000, 4000, 50000},
+	domain.ElectricVehicle: {5000, 3000, 40000},
+	domain.Bus:             {15000, 8000, 120000},
+	domain.DisabledAccess:  {2500, 1500, 20000},
+}
+
+func getTariffConfig(vehicleTypes *domain.VehicleTypeRegistry) TariffConfig {
+	gracePeriod := getEnvInt64("TARIFF_GRACE_PERIOD_MINUTES", 15)
+	overnightStart := getEnvInt64("TARIFF_OVERNIGHT_START_HOUR", 22)
+	overnightEnd := getEnvInt64("TARIFF_OVERNIGHT_END_HOUR", 6)
+
+	vehicleTariffs := make(map[domain.VehicleType]domain.Tariff)
+	for _, vehicleType := range vehicleTypes.Types() {
+		defaults, ok := defaultTariffs[vehicleType]
+		if !ok {
+			defaults = defaultTariffs[domain.Car]
+		}
+
+		prefix := fmt.Sprintf("TARIFF_%s_", strings.ToUpper(string(vehicleType)))
+		vehicleTariffs[vehicleType] = domain.Tariff{
+			FirstHourRate:      getEnvInt64(prefix+"FIRST_HOUR_RATE", defaults[0]),
+			HourlyRate:         getEnvInt64(prefix+"HOURLY_RATE", defaults[1]),
+			GracePeriod:        time.Duration(gracePeriod) * time.Minute,
+			DailyCap:           getEnvInt64(prefix+"DAILY_CAP", defaults[2]),
+			OvernightRate:      getEnvInt64(prefix+"OVERNIGHT_RATE", 0),
+			OvernightStartHour: int(overnightStart),
+			OvernightEndHour:   int(overnightEnd),
+		}
+	}
+
+	return TariffConfig{
+		VehicleTariffs:    vehicleTariffs,
+		LostTicketPenalty: getEnvInt64("TARIFF_LOST_TICKET_PENALTY", 50000),
+	}
+}
+
+func getReservationConfig() ReservationConfig {
+	return ReservationConfig{
+		GracePeriod:    time.Duration(getEnvInt64("RESERVATION_GRACE_PERIOD_MINUTES", 15)) * time.Minute,
+		Hold:           time.Duration(getEnvInt64("RESERVATION_HOLD_MINUTES", 60)) * time.Minute,
+		ExpiryInterval: time.Duration(getEnvPositiveInt64("RESERVATION_EXPIRY_INTERVAL_SECONDS", 60)) * time.Second,
+	}
+}
+
+func getWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		MaxAttempts:    int(getEnvPositiveInt64("WEBHOOK_MAX_ATTEMPTS", 8)),
+		InitialBackoff: time.Duration(getEnvInt64("WEBHOOK_INITIAL_BACKOFF_SECONDS", 10)) * time.Second,
+		MaxBackoff:     time.Duration(getEnvInt64("WEBHOOK_MAX_BACKOFF_SECONDS", 3600)) * time.Second,
+		Timeout:        time.Duration(getEnvPositiveInt64("WEBHOOK_TIMEOUT_SECONDS", 10)) * time.Second,
+		PollInterval:   time.Duration(getEnvPositiveInt64("WEBHOOK_POLL_INTERVAL_SECONDS", 2)) * time.Second,
+		Retention:      time.Duration(getEnvInt64("WEBHOOK_RETENTION_HOURS", 168)) * time.Hour,
+	}
+}
+
+func getStreamConfig() StreamConfig {
+	return StreamConfig{
+		BufferSize:        int(getEnvPositiveInt64("STREAM_BUFFER_SIZE", 1000)),
+		HeartbeatInterval: time.Duration(getEnvPositiveInt64("STREAM_HEARTBEAT_SECONDS", 15)) * time.Second,
+		PollInterval:      time.Duration(getEnvPositiveInt64("STREAM_POLL_INTERVAL_SECONDS", 1)) * time.Second,
+	}
+}
+
+func getGateSocketConfig() GateSocketConfig {
+	return GateSocketConfig{
+		HeartbeatInterval: time.Duration(getEnvPositiveInt64("GATE_SOCKET_HEARTBEAT_SECONDS", 15)) * time.Second,
+	}
+}
+
+func getAdminConfig() AdminConfig {
+	return AdminConfig{
+		APIToken: getEnv("ADMIN_API_TOKEN", ""),
+	}
+}
+
+func getEnvInt64(key string, fallback int64) int64 {
+	value, err := strconv.ParseInt(getEnv(key, strconv.FormatInt(fallback, 10)), 10, 64)
+	if err != nil {
+		log.Printf("Warning: invalid value for %v, using default value %v\n", key, fallback)
+		return fallback
+	}
+	return value
+}
+
+// getEnvPositiveInt64 reads a setting that cannot work with zero or less, such as a ticker interval, and
+// stops the program when it is not positive
+func getEnvPositiveInt64(key string, fallback int64) int64 {
+	value := getEnvInt64(key, fallback)
+	if value <= 0 {
+		log.Fatalf("Invalid value for %v: %v, it must be positive\n", key, value)
 	}
+	return value
 }
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"parking-lot/domain"
+)
+
+// SpotRule assigns a vehicle type and size class to a range of spots. A zero range matches
+// every floor, row or column.
+type SpotRule struct {
+	Floors      [2]int
+	Rows        [2]int
+	Columns     [2]int
+	VehicleType domain.VehicleType
+	SizeClass   domain.SizeClass
+}
+
+func (r SpotRule) Matches(floor, row, column int) bool {
+	return inRange(r.Floors, floor) && inRange(r.Rows, row) && inRange(r.Columns, column)
+}
+
+func inRange(r [2]int, value int) bool {
+	return r == [2]int{} || (value >= r[0] && value <= r[1])
+}
+
+// SpotType returns the vehicle type and size class of the spot at the position. The last matching
+// spot rule wins, spots without one get the floor's vehicle type and its default size class.
+func (c ParkingConfig) SpotType(floor, row, column int) (domain.VehicleType, domain.SizeClass) {
+	vehicleType := c.FloorVehicleMap[floor]
+	sizeClass := c.VehicleTypes.DefaultSizeClass(vehicleType)
+
+	for _, rule := range c.SpotRules {
+		if rule.Matches(floor, row, column) {
+			vehicleType, sizeClass = rule.VehicleType, rule.SizeClass
+		}
+	}
+
+	return vehicleType, sizeClass
+}
+
+// parseSpotRules parses spot rules separated by semicolons, each of the form
+// "<floor>[-<row>[-<column>]]=<vehicle type>[:<size class>]", where floor, row and column are
+// a number or an inclusive range like "1..3". For example "2-1..2=motorcycle;3-5-1..4=car:large"
+// makes the first two rows of floor 2 motorcycle spots and the first four spots of row 5 on floor 3
+// large car spots.
+func parseSpotRules(value string, vehicleTypes *domain.VehicleTypeRegistry) ([]SpotRule, error) {
+	var rules []SpotRule
+	for _, spec := range strings.Split(value, ";") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		position, spotType, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("spot rule %q is missing a vehicle type", spec)
+		}
+
+		var rule SpotRule
+		ranges := []*[2]int{&rule.Floors, &rule.Rows, &rule.Columns}
+		parts := strings.Split(position, "-")
+		if len(parts) > len(ranges) {
+			return nil, fmt.Errorf("spot rule %q has too many position parts", spec)
+		}
+		for i, part := range parts {
+			r, err := parseRange(part)
+			if err != nil {
+				return nil, fmt.Errorf("spot rule %q: %w", spec, err)
+			}
+			*ranges[i] = r
+		}
+
+		vehicleType, sizeClass, hasSizeClass := strings.Cut(spotType, ":")
+		rule.VehicleType = domain.VehicleType(strings.TrimSpace(vehicleType))
+		if !vehicleTypes.IsValid(rule.VehicleType) {
+			return nil, fmt.Errorf("spot rule %q has invalid vehicle type %q, must be %v", spec, rule.VehicleType, vehicleTypes)
+		}
+
+		rule.SizeClass = vehicleTypes.DefaultSizeClass(rule.VehicleType)
+		if hasSizeClass {
+			rule.SizeClass = domain.SizeClass(strings.TrimSpace(sizeClass))
+			if !rule.SizeClass.IsValid() {
+				return nil, fmt.Errorf("spot rule %q has invalid size class %q", spec, rule.SizeClass)
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// parseRange parses a number or an inclusive range like "1..3"
+func parseRange(value string) ([2]int, error) {
+	from, to, isRange := strings.Cut(strings.TrimSpace(value), "..")
+	if !isRange {
+		to = from
+	}
+
+	start, err := strconv.Atoi(from)
+	if err != nil {
+		return [2]int{}, fmt.Errorf("invalid number %q", from)
+	}
+
+	end, err := strconv.Atoi(to)
+	if err != nil {
+		return [2]int{}, fmt.Errorf("invalid number %q", to)
+	}
+
+	if start < 1 || end < start {
+		return [2]int{}, fmt.Errorf("invalid range %q", value)
+	}
+
+	return [2]int{start, end}, nil
+}
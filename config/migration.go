@@ -0,0 +1,336 @@
+package config
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations
+var migrationFS embed.FS
+
+// migrationFilePattern matches migration files such as 0001_create_initial_tables.up.sql
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a numbered schema change with the SQL to apply and revert it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus tells whether a migration has been applied to the database
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// LoadMigrations returns the embedded migrations of the configured storage driver, ordered by version
+func LoadMigrations() ([]Migration, error) {
+	dir := path.Join("migrations", migrationDialect())
+
+	entries, err := fs.ReadDir(migrationFS, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	migrationMap := make(map[int]*Migration)
+	for _, entry := range entries {
+		matches := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(matches[1])
+		content, err := fs.ReadFile(migrationFS, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := migrationMap[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			migrationMap[version] = migration
+		}
+		if matches[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(migrationMap))
+	for _, migration := range migrationMap {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s must have both up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// LatestSchemaVersion returns the version of the newest embedded migration
+func LatestSchemaVersion() (int, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// SchemaVersion returns the version of the newest migration applied to the database, 0 if none
+func SchemaVersion(db *sql.DB) (int, error) {
+	err := createMigrationTable(db)
+	if err != nil {
+		return 0, err
+	}
+
+	var version sql.NullInt64
+	err = db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(version.Int64), nil
+}
+
+// CheckSchemaVersion returns an error if the database schema is not at the latest migration
+func CheckSchemaVersion(db *sql.DB) error {
+	latest, err := LatestSchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	if current != latest {
+		return fmt.Errorf("database schema is at version %d but version %d is required, run the migrations", current, latest)
+	}
+
+	return nil
+}
+
+// GetMigrationStatus returns every embedded migration along with when it was applied
+func GetMigrationStatus(db *sql.DB) ([]MigrationStatus, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, migration := range migrations {
+		statuses[i] = MigrationStatus{Migration: migration}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+
+	return statuses, nil
+}
+
+// MigrateUp applies all pending migrations
+func MigrateUp(db *sql.DB) error {
+	latest, err := LatestSchemaVersion()
+	if err != nil {
+		return err
+	}
+	return MigrateTo(db, latest)
+}
+
+// MigrateDown reverts the most recently applied migration
+func MigrateDown(db *sql.DB) error {
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if current == 0 {
+		return errors.New("no migration to revert")
+	}
+
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return err
+	}
+
+	// revert to the version just below the current one
+	target := 0
+	for _, migration := range migrations {
+		if migration.Version < current {
+			target = migration.Version
+		}
+	}
+
+	return MigrateTo(db, target)
+}
+
+// MigrateTo applies or reverts migrations until the database schema is at the given version
+func MigrateTo(db *sql.DB, version int) error {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return err
+	}
+
+	if version != 0 && !containsVersion(migrations, version) {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	// apply pending migrations up to the target version in ascending order
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok || migration.Version > version {
+			continue
+		}
+		err = runMigration(db, migration, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	// revert applied migrations above the target version in descending order
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+		if _, ok := applied[migration.Version]; !ok || migration.Version <= version {
+			continue
+		}
+		err = runMigration(db, migration, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runMigration applies (up) or reverts a migration and records it in schema_migrations
+// within a single transaction
+func runMigration(db *sql.DB, migration Migration, up bool) error {
+	var err error
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if up {
+		_, err = tx.Exec(migration.Up)
+		if err != nil {
+			return fmt.Errorf("error applying migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`, migration.Version, migration.Name, time.Now())
+	} else {
+		_, err = tx.Exec(migration.Down)
+		if err != nil {
+			return fmt.Errorf("error reverting migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	if up {
+		log.Printf("Applied migration %04d_%s\n", migration.Version, migration.Name)
+	} else {
+		log.Printf("Reverted migration %04d_%s\n", migration.Version, migration.Name)
+	}
+	return nil
+}
+
+// appliedMigrations returns the applied migration versions and when they were applied
+func appliedMigrations(db *sql.DB) (map[int]time.Time, error) {
+	err := createMigrationTable(db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt time.Time
+		)
+		err = rows.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+func createMigrationTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)
+	`)
+	return err
+}
+
+// migrationDialect returns the migration directory for the configured storage driver
+func migrationDialect() string {
+	if GetAppConfig().Storage.Driver == StorageDriverSQLite {
+		return StorageDriverSQLite
+	}
+	return StorageDriverPostgres
+}
+
+func containsVersion(migrations []Migration, version int) bool {
+	for _, migration := range migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1 @@
+ALTER TABLE parking_records DROP COLUMN fee;
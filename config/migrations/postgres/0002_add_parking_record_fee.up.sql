@@ -0,0 +1 @@
+ALTER TABLE parking_records ADD COLUMN fee BIGINT;
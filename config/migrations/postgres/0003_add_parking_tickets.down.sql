@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS parking_records_ticket_code_idx;
+
+ALTER TABLE parking_records DROP COLUMN ticket_code;
+
+UPDATE vehicles SET license_plate = 'UNKNOWN-' || id WHERE license_plate IS NULL;
+
+ALTER TABLE vehicles ALTER COLUMN license_plate SET NOT NULL;
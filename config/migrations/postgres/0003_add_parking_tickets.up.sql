@@ -0,0 +1,6 @@
+-- Vehicles without a license plate (e.g. bicycles) are identified by their ticket only
+ALTER TABLE vehicles ALTER COLUMN license_plate DROP NOT NULL;
+
+ALTER TABLE parking_records ADD COLUMN ticket_code VARCHAR(32);
+
+CREATE UNIQUE INDEX parking_records_ticket_code_idx ON parking_records (ticket_code);
@@ -0,0 +1,5 @@
+-- Spot types are filled in from the configured layout when the layout is reconciled after migrating
+ALTER TABLE parking_spots ADD COLUMN vehicle_type VARCHAR(20) NOT NULL DEFAULT 'car';
+ALTER TABLE parking_spots ADD COLUMN size_class VARCHAR(20) NOT NULL DEFAULT 'standard';
+
+CREATE INDEX parking_spots_type_idx ON parking_spots (vehicle_type, size_class);
@@ -0,0 +1,12 @@
+CREATE TABLE spot_maintenances (
+	id SERIAL PRIMARY KEY,
+	parking_spot_id INT NOT NULL REFERENCES parking_spots(id),
+	reason TEXT NOT NULL,
+	start_time TIMESTAMP NOT NULL,
+	end_time TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Maintenances are looked up by spot and time window when allocating spots
+CREATE INDEX spot_maintenances_spot_idx ON spot_maintenances (parking_spot_id, start_time, end_time);
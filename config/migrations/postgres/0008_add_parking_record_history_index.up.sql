@@ -0,0 +1,2 @@
+-- Vehicle history pages through the records of a vehicle by entry time
+CREATE INDEX parking_records_vehicle_entry_time_idx ON parking_records (vehicle_id, entry_time);
@@ -0,0 +1,10 @@
+CREATE TABLE api_keys (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	role VARCHAR(20) NOT NULL,
+	key_prefix VARCHAR(20) NOT NULL,
+	-- SHA-256 of the key in hex, the key itself is never stored
+	key_hash CHAR(64) UNIQUE NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	revoked_at TIMESTAMP
+);
@@ -0,0 +1,15 @@
+CREATE TABLE idempotency_keys (
+	-- 0 for requests authenticated with ADMIN_API_TOKEN
+	api_key_id INT NOT NULL,
+	idempotency_key VARCHAR(255) NOT NULL,
+	request_hash CHAR(64) NOT NULL,
+	-- status_code and response_body are NULL while the first request is in progress
+	status_code INT,
+	response_body BYTEA,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	expires_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (api_key_id, idempotency_key)
+);
+
+-- Expired keys are deleted before new ones are stored
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
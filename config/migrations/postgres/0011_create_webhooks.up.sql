@@ -0,0 +1,28 @@
+CREATE TABLE webhooks (
+	id SERIAL PRIMARY KEY,
+	url TEXT NOT NULL,
+	-- comma-separated event types, e.g. vehicle.parked,vehicle.unparked
+	event_types TEXT NOT NULL,
+	secret VARCHAR(100) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Events are written here in the transaction that caused them, one row per subscribed webhook,
+-- and delivered from here by the webhook dispatcher
+CREATE TABLE webhook_outbox (
+	id SERIAL PRIMARY KEY,
+	webhook_id INT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+	event_id VARCHAR(50) NOT NULL,
+	event_type VARCHAR(50) NOT NULL,
+	payload TEXT NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'pending',
+	attempts INT NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMP NOT NULL,
+	last_error TEXT,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- The dispatcher polls for due pending deliveries, and delivered ones are deleted after a while
+CREATE INDEX webhook_outbox_status_idx ON webhook_outbox (status, next_attempt_at);
+CREATE INDEX webhook_outbox_webhook_idx ON webhook_outbox (webhook_id);
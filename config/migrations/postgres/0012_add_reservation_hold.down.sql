@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS reservations_active_hold_idx;
+ALTER TABLE reservations DROP COLUMN held_from;
@@ -0,0 +1,6 @@
+-- Reservations hold their spot from held_from, ahead of their start, so walk-ins cannot take it shortly before
+ALTER TABLE reservations ADD COLUMN held_from TIMESTAMP;
+UPDATE reservations SET held_from = start_time;
+ALTER TABLE reservations ALTER COLUMN held_from SET NOT NULL;
+
+CREATE INDEX reservations_active_hold_idx ON reservations (parking_spot_id, held_from, end_time) WHERE status = 'active';
@@ -0,0 +1,43 @@
+ALTER TABLE vehicles
+	ALTER COLUMN created_at TYPE TIMESTAMP,
+	ALTER COLUMN updated_at TYPE TIMESTAMP;
+
+ALTER TABLE parking_spots
+	ALTER COLUMN created_at TYPE TIMESTAMP,
+	ALTER COLUMN updated_at TYPE TIMESTAMP;
+
+ALTER TABLE parking_records
+	ALTER COLUMN entry_time TYPE TIMESTAMP,
+	ALTER COLUMN exit_time TYPE TIMESTAMP,
+	ALTER COLUMN created_at TYPE TIMESTAMP,
+	ALTER COLUMN updated_at TYPE TIMESTAMP;
+
+ALTER TABLE reservations
+	ALTER COLUMN start_time TYPE TIMESTAMP,
+	ALTER COLUMN end_time TYPE TIMESTAMP,
+	ALTER COLUMN held_from TYPE TIMESTAMP,
+	ALTER COLUMN created_at TYPE TIMESTAMP,
+	ALTER COLUMN updated_at TYPE TIMESTAMP;
+
+ALTER TABLE spot_maintenances
+	ALTER COLUMN start_time TYPE TIMESTAMP,
+	ALTER COLUMN end_time TYPE TIMESTAMP,
+	ALTER COLUMN created_at TYPE TIMESTAMP,
+	ALTER COLUMN updated_at TYPE TIMESTAMP;
+
+ALTER TABLE api_keys
+	ALTER COLUMN created_at TYPE TIMESTAMP,
+	ALTER COLUMN revoked_at TYPE TIMESTAMP;
+
+ALTER TABLE idempotency_keys
+	ALTER COLUMN created_at TYPE TIMESTAMP,
+	ALTER COLUMN expires_at TYPE TIMESTAMP,
+	ALTER COLUMN lease_expires_at TYPE TIMESTAMP;
+
+ALTER TABLE webhooks
+	ALTER COLUMN created_at TYPE TIMESTAMP;
+
+ALTER TABLE webhook_outbox
+	ALTER COLUMN next_attempt_at TYPE TIMESTAMP,
+	ALTER COLUMN created_at TYPE TIMESTAMP,
+	ALTER COLUMN updated_at TYPE TIMESTAMP;
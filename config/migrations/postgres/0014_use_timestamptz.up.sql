@@ -0,0 +1,46 @@
+-- TIMESTAMP columns drop the offset of the times written to them, so a host outside UTC shifted every stored
+-- time and the fees computed from them. Existing values are read in the time zone of the session, run this
+-- migration with PGTZ set to the time zone of the hosts that wrote them if that was not UTC.
+ALTER TABLE vehicles
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN updated_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE parking_spots
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN updated_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE parking_records
+	ALTER COLUMN entry_time TYPE TIMESTAMPTZ,
+	ALTER COLUMN exit_time TYPE TIMESTAMPTZ,
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN updated_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE reservations
+	ALTER COLUMN start_time TYPE TIMESTAMPTZ,
+	ALTER COLUMN end_time TYPE TIMESTAMPTZ,
+	ALTER COLUMN held_from TYPE TIMESTAMPTZ,
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN updated_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE spot_maintenances
+	ALTER COLUMN start_time TYPE TIMESTAMPTZ,
+	ALTER COLUMN end_time TYPE TIMESTAMPTZ,
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN updated_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE api_keys
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN revoked_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE idempotency_keys
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN expires_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN lease_expires_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE webhooks
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE webhook_outbox
+	ALTER COLUMN next_attempt_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN updated_at TYPE TIMESTAMPTZ;
@@ -0,0 +1 @@
+DROP INDEX parking_records_updated_at_idx;
@@ -0,0 +1,2 @@
+-- The event stream of every instance polls the parking records written since its last poll
+CREATE INDEX parking_records_updated_at_idx ON parking_records (updated_at);
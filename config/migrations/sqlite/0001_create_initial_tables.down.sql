@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS parking_records;
+
+DROP TABLE IF EXISTS parking_spots;
+
+DROP TABLE IF EXISTS vehicles;
@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS vehicles (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	license_plate VARCHAR(50) UNIQUE NOT NULL,
+	type VARCHAR(20) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS parking_spots (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	floor INT NOT NULL,
+	row INT NOT NULL,
+	"column" INT NOT NULL,
+	is_active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(floor, row, "column")
+);
+
+CREATE TABLE IF NOT EXISTS parking_records (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	vehicle_id INTEGER NOT NULL REFERENCES vehicles(id),
+	parking_spot_id INTEGER NOT NULL REFERENCES parking_spots(id),
+	entry_time TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	exit_time TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A spot can only hold one parked vehicle, and a vehicle can only be parked once
+CREATE UNIQUE INDEX IF NOT EXISTS parking_records_active_spot_idx
+ON parking_records (parking_spot_id) WHERE exit_time IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS parking_records_active_vehicle_idx
+ON parking_records (vehicle_id) WHERE exit_time IS NULL;
@@ -0,0 +1,22 @@
+DROP INDEX IF EXISTS parking_records_ticket_code_idx;
+
+ALTER TABLE parking_records DROP COLUMN ticket_code;
+
+PRAGMA defer_foreign_keys = ON;
+
+CREATE TABLE vehicles_backup AS SELECT * FROM vehicles;
+
+DROP TABLE vehicles;
+
+CREATE TABLE vehicles (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	license_plate VARCHAR(50) UNIQUE NOT NULL,
+	type VARCHAR(20) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO vehicles (id, license_plate, type, created_at, updated_at)
+SELECT id, COALESCE(license_plate, 'UNKNOWN-' || id), type, created_at, updated_at FROM vehicles_backup;
+
+DROP TABLE vehicles_backup;
@@ -0,0 +1,25 @@
+-- Vehicles without a license plate (e.g. bicycles) are identified by their ticket only.
+-- SQLite cannot drop NOT NULL, so the vehicles table is rebuilt. Foreign key checks are deferred
+-- until commit, when parking_records references the rebuilt table again.
+PRAGMA defer_foreign_keys = ON;
+
+CREATE TABLE vehicles_backup AS SELECT * FROM vehicles;
+
+DROP TABLE vehicles;
+
+CREATE TABLE vehicles (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	license_plate VARCHAR(50) UNIQUE,
+	type VARCHAR(20) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO vehicles (id, license_plate, type, created_at, updated_at)
+SELECT id, license_plate, type, created_at, updated_at FROM vehicles_backup;
+
+DROP TABLE vehicles_backup;
+
+ALTER TABLE parking_records ADD COLUMN ticket_code VARCHAR(32);
+
+CREATE UNIQUE INDEX parking_records_ticket_code_idx ON parking_records (ticket_code);
@@ -0,0 +1,16 @@
+CREATE TABLE reservations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	parking_spot_id INTEGER NOT NULL REFERENCES parking_spots(id),
+	license_plate VARCHAR(50) NOT NULL,
+	vehicle_type VARCHAR(20) NOT NULL,
+	start_time TIMESTAMP NOT NULL,
+	end_time TIMESTAMP NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'active',
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Active reservations are looked up by spot and time window when allocating spots
+CREATE INDEX reservations_active_spot_idx ON reservations (parking_spot_id, start_time, end_time) WHERE status = 'active';
+
+CREATE INDEX reservations_license_plate_idx ON reservations (license_plate);
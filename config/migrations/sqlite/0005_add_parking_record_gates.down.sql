@@ -0,0 +1,2 @@
+ALTER TABLE parking_records DROP COLUMN exit_gate_id;
+ALTER TABLE parking_records DROP COLUMN entry_gate_id;
@@ -0,0 +1,2 @@
+ALTER TABLE parking_records ADD COLUMN entry_gate_id INT;
+ALTER TABLE parking_records ADD COLUMN exit_gate_id INT;
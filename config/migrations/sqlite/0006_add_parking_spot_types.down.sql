@@ -0,0 +1,4 @@
+DROP INDEX parking_spots_type_idx;
+
+ALTER TABLE parking_spots DROP COLUMN size_class;
+ALTER TABLE parking_spots DROP COLUMN vehicle_type;
@@ -0,0 +1,12 @@
+CREATE TABLE spot_maintenances (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	parking_spot_id INTEGER NOT NULL REFERENCES parking_spots(id),
+	reason TEXT NOT NULL,
+	start_time TIMESTAMP NOT NULL,
+	end_time TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Maintenances are looked up by spot and time window when allocating spots
+CREATE INDEX spot_maintenances_spot_idx ON spot_maintenances (parking_spot_id, start_time, end_time);
@@ -0,0 +1 @@
+DROP INDEX parking_records_vehicle_entry_time_idx;
@@ -0,0 +1,6 @@
+-- Reservations hold their spot from held_from, ahead of their start, so walk-ins cannot take it shortly before.
+-- SQLite cannot add a NOT NULL column without a default, every reservation is written with one.
+ALTER TABLE reservations ADD COLUMN held_from TIMESTAMP;
+UPDATE reservations SET held_from = start_time;
+
+CREATE INDEX reservations_active_hold_idx ON reservations (parking_spot_id, held_from, end_time) WHERE status = 'active';
@@ -0,0 +1,5 @@
+-- A request in progress holds its key until lease_expires_at, after that a retry takes the key over, so a key
+-- is not stuck when its first request never finished, e.g. because the instance stopped.
+-- SQLite cannot add a NOT NULL column without a default, every key is written with one.
+ALTER TABLE idempotency_keys ADD COLUMN lease_expires_at TIMESTAMP;
+UPDATE idempotency_keys SET lease_expires_at = created_at;
@@ -0,0 +1,3 @@
+-- SQLite stores times as text with their offset, which PostgreSQL TIMESTAMP columns dropped.
+-- Nothing to convert here, the migration keeps the versions of both drivers in line.
+SELECT 1;
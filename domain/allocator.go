@@ -0,0 +1,41 @@
+package domain
+
+// AllocationStrategy is how a spot is chosen among the available ones
+type AllocationStrategy string
+
+const (
+	AllocationFirstFit          AllocationStrategy = "first_fit"
+	AllocationNearestToGate     AllocationStrategy = "nearest_to_gate"
+	AllocationBalanceFloors     AllocationStrategy = "balance_floors"
+	AllocationHighestFloorFirst AllocationStrategy = "highest_floor_first"
+	AllocationRandom            AllocationStrategy = "random"
+)
+
+func (s AllocationStrategy) IsValid() bool {
+	switch s {
+	case AllocationFirstFit, AllocationNearestToGate, AllocationBalanceFloors, AllocationHighestFloorFirst, AllocationRandom:
+		return true
+	}
+	return false
+}
+
+// Position is a location in the parking lot
+type Position struct {
+	Floor  int `json:"floor"`
+	Row    int `json:"row"`
+	Column int `json:"column"`
+}
+
+// AllocationRequest describes the vehicle a spot is allocated for
+type AllocationRequest struct {
+	VehicleType VehicleType
+	SizeClass   SizeClass
+	// Entrance is where the vehicle enters the parking lot, nil if unknown
+	Entrance *Position
+}
+
+// SpotAllocator decides which available spot a vehicle gets
+type SpotAllocator interface {
+	// Rank returns the candidate spots ordered from most to least preferred
+	Rank(candidates []ParkingSpot, request AllocationRequest) []ParkingSpot
+}
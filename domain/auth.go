@@ -0,0 +1,83 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Role decides which endpoints an API key may call
+type Role string
+
+const (
+	// RoleGate is a gate device parking and unparking vehicles
+	RoleGate Role = "gate"
+	// RoleAttendant is staff parking and unparking vehicles and handling reservations
+	RoleAttendant Role = "attendant"
+	// RoleAdmin may call every endpoint, including the admin API
+	RoleAdmin Role = "admin"
+	// RoleReporting may only read, e.g. for dashboards and display boards
+	RoleReporting Role = "reporting"
+)
+
+var Roles = []Role{RoleGate, RoleAttendant, RoleAdmin, RoleReporting}
+
+func (r Role) IsValid() bool {
+	for _, role := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey authenticates a client with a role. Only a hash of the key is stored, the key itself
+// is shown once when it is created.
+type APIKey struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Role Role   `json:"role"`
+	// Prefix is the start of the key, to tell keys apart without storing them
+	Prefix    string     `json:"prefix"`
+	KeyHash   string     `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// APIKeyRepository defines the interface for API key operations
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *APIKey) error
+	// GetAPIKeyByHash returns the key with the given hash, also when it is revoked
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*APIKey, error)
+	GetAPIKeys(ctx context.Context) ([]APIKey, error)
+	// RevokeAPIKey revokes the key at the given time and reports whether an unrevoked key was found
+	RevokeAPIKey(ctx context.Context, id int64, at time.Time) (bool, error)
+}
+
+// AuthService defines the interface for API key business logic
+type AuthService interface {
+	// CreateAPIKey creates a key for the role and returns it together with the key itself
+	CreateAPIKey(ctx context.Context, name string, role Role) (*APIKey, string, error)
+	// Authenticate returns the unrevoked key matching the given key, or nil if there is none
+	Authenticate(ctx context.Context, key string) (*APIKey, error)
+	GetAPIKeys(ctx context.Context) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64) error
+}
+
+type APIKeyRequest struct {
+	Name string `json:"name"`
+	Role Role   `json:"role"`
+}
+
+type APIKeyResponse struct {
+	Success bool    `json:"success"`
+	Message string  `json:"message"`
+	APIKey  *APIKey `json:"api_key,omitempty"`
+	// Key is only returned when the key is created
+	Key string `json:"key,omitempty"`
+}
+
+type APIKeysResponse struct {
+	Success bool     `json:"success"`
+	Message string   `json:"message"`
+	APIKeys []APIKey `json:"api_keys"`
+}
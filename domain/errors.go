@@ -0,0 +1,109 @@
+package domain
+
+import "fmt"
+
+// ErrorKind classifies domain errors, so the transport can map them to its own status codes
+type ErrorKind int
+
+const (
+	// KindInternal is an unexpected failure, like a database error
+	KindInternal ErrorKind = iota
+	// KindInvalidRequest is a request that cannot be read, like malformed JSON
+	KindInvalidRequest
+	// KindValidation is a request that was read but has invalid or missing values
+	KindValidation
+	// KindNotFound is a request for something that does not exist, like an unknown ticket
+	KindNotFound
+	// KindConflict is a request that conflicts with the current state, like parking a parked vehicle
+	KindConflict
+	// KindCapacityExhausted is a request that cannot be served because the parking lot is full
+	KindCapacityExhausted
+	// KindTimeout is a request that ran past its deadline, usually waiting on the database
+	KindTimeout
+)
+
+// Error codes are stable and machine-readable, clients can branch on them instead of the message
+const (
+	CodeInternal              = "internal_error"
+	CodeInvalidRequest        = "invalid_request"
+	CodeInvalidVehicleType    = "invalid_vehicle_type"
+	CodeInvalidSizeClass      = "invalid_size_class"
+	CodeLicensePlateRequired  = "license_plate_required"
+	CodeTicketOrPlateRequired = "ticket_code_or_license_plate_required"
+	CodeGateDirection         = "gate_direction_not_allowed"
+	CodeInvalidReservation    = "invalid_reservation"
+	CodeInvalidMaintenance    = "invalid_maintenance"
+	CodeInvalidHistoryFilter  = "invalid_history_filter"
+	CodeGateNotFound          = "gate_not_found"
+	CodeVehicleNotFound       = "vehicle_not_found"
+	CodeTicketNotFound        = "ticket_not_found"
+	CodeReservationNotFound   = "reservation_not_found"
+	CodeSpotNotFound          = "spot_not_found"
+	CodeVehicleAlreadyParked  = "vehicle_already_parked"
+	CodeVehicleNotParked      = "vehicle_not_parked"
+	CodeReservationNotActive  = "reservation_not_active"
+	CodeSpotOccupied          = "spot_occupied"
+	CodeNoAvailableSpots      = "no_available_spots"
+	CodeNotReady              = "not_ready"
+	CodeNoSpotsForReservation = "no_spots_for_reservation"
+	CodeRequestTimeout        = "request_timeout"
+	CodeInvalidAPIKey         = "invalid_api_key"
+	CodeAPIKeyNotFound        = "api_key_not_found"
+	CodeIdempotencyKeyReused  = "idempotency_key_reused"
+	CodeIdempotencyInProgress = "idempotency_key_in_progress"
+	CodeInvalidWebhook        = "invalid_webhook"
+	CodeWebhookNotFound       = "webhook_not_found"
+	CodeDeliveryNotFound      = "delivery_not_found"
+	CodeInvalidStreamFilter   = "invalid_stream_filter"
+	CodeInvalidGateMessage    = "invalid_gate_message"
+)
+
+// Error is a domain error with a kind and a stable code
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newError(kind ErrorKind, code, format string, args ...any) error {
+	return &Error{
+		Kind:    kind,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func NewInvalidRequestError(code, format string, args ...any) error {
+	return newError(KindInvalidRequest, code, format, args...)
+}
+
+func NewValidationError(code, format string, args ...any) error {
+	return newError(KindValidation, code, format, args...)
+}
+
+func NewNotFoundError(code, format string, args ...any) error {
+	return newError(KindNotFound, code, format, args...)
+}
+
+func NewConflictError(code, format string, args ...any) error {
+	return newError(KindConflict, code, format, args...)
+}
+
+func NewCapacityExhaustedError(code, format string, args ...any) error {
+	return newError(KindCapacityExhausted, code, format, args...)
+}
+
+func NewTimeoutError(code, format string, args ...any) error {
+	return newError(KindTimeout, code, format, args...)
+}
+
+// ErrorResponse is the body of every failed request
+type ErrorResponse struct {
+	Success   bool   `json:"success"`
+	Message   string `json:"message"`
+	ErrorCode string `json:"error_code"`
+}
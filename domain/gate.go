@@ -0,0 +1,36 @@
+package domain
+
+// GateDirection is the way vehicles pass through a gate
+type GateDirection string
+
+const (
+	GateEntry GateDirection = "entry"
+	GateExit  GateDirection = "exit"
+	GateBoth  GateDirection = "both"
+)
+
+func (d GateDirection) IsValid() bool {
+	return d == GateEntry || d == GateExit || d == GateBoth
+}
+
+// Gate is where vehicles enter or leave the parking lot
+type Gate struct {
+	ID        int64         `json:"id"`
+	Name      string        `json:"name"`
+	Direction GateDirection `json:"direction"`
+	Position
+}
+
+func (g Gate) AllowsEntry() bool {
+	return g.Direction == GateEntry || g.Direction == GateBoth
+}
+
+func (g Gate) AllowsExit() bool {
+	return g.Direction == GateExit || g.Direction == GateBoth
+}
+
+type GatesResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	Gates   []Gate `json:"gates,omitempty"`
+}
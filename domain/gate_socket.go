@@ -0,0 +1,56 @@
+package domain
+
+import "encoding/json"
+
+type GateMessageType string
+
+const (
+	// GateHello opens a session, the gate sends its API key, gate ID and session, the server answers with
+	// its own hello
+	GateHello GateMessageType = "hello"
+	// GatePark parks a vehicle at the gate, the server answers with an ack carrying the result
+	GatePark GateMessageType = "park"
+	// GateUnpark unparks a vehicle at the gate, the server answers with an ack carrying the result
+	GateUnpark GateMessageType = "unpark"
+	// GateAck acknowledges the message of the other side with the seq in its ack
+	GateAck GateMessageType = "ack"
+	// GateOpenBarrier tells the gate to open its barrier
+	GateOpenBarrier GateMessageType = "open-barrier"
+	// GateDisplayMessage tells the gate to show a text to the driver
+	GateDisplayMessage GateMessageType = "display-message"
+	GatePing           GateMessageType = "ping"
+	GatePong           GateMessageType = "pong"
+	// GateError reports a message the server could not handle, the connection is closed after errors
+	// in the hello
+	GateError GateMessageType = "error"
+)
+
+// GateMessage is a message of the gate socket protocol in either direction. Each side numbers its park,
+// unpark, open-barrier and display-message messages with seq from 1 within a session, and resends those the
+// other side did not ack after reconnecting. Heartbeats, acks, hellos and errors have no seq.
+type GateMessage struct {
+	Type GateMessageType `json:"type"`
+	Seq  int64           `json:"seq,omitempty"`
+	// Ack is the seq of the message of the other side that is acknowledged, or that caused the error
+	Ack int64 `json:"ack,omitempty"`
+
+	// APIKey authenticates the gate in its hello
+	APIKey string `json:"api_key,omitempty"`
+	GateID int64  `json:"gate_id,omitempty"`
+	// Session is chosen by the gate and kept across reconnects, a new session starts both sequences anew
+	Session string `json:"session,omitempty"`
+	// LastAck is the last seq of the server the gate received, sent in its hello to get the later ones again
+	LastAck          int64 `json:"last_ack,omitempty"`
+	HeartbeatSeconds int   `json:"heartbeat_seconds,omitempty"`
+
+	Park   *ParkRequest   `json:"park,omitempty"`
+	Unpark *UnparkRequest `json:"unpark,omitempty"`
+	// Result is the ParkResponse, UnparkResponse or ErrorResponse of an acked park or unpark, and the
+	// ErrorResponse of an error
+	Result json.RawMessage `json:"result,omitempty"`
+	// Replayed is set on the ack of a park or unpark that was already handled, with the original result
+	Replayed bool `json:"replayed,omitempty"`
+
+	// Text is shown by display-message
+	Text string `json:"text,omitempty"`
+}
@@ -0,0 +1,24 @@
+package domain
+
+import "context"
+
+// HealthCheck is the result of one readiness check
+type HealthCheck struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	// Message explains why the check failed, empty when healthy
+	Message string `json:"message,omitempty"`
+}
+
+// HealthService defines the interface for the checks deciding whether the application can serve traffic
+type HealthService interface {
+	// CheckReadiness runs every readiness check and reports whether all of them passed
+	CheckReadiness(ctx context.Context) (bool, []HealthCheck)
+}
+
+type HealthResponse struct {
+	Success   bool          `json:"success"`
+	Message   string        `json:"message"`
+	ErrorCode string        `json:"error_code,omitempty"`
+	Checks    []HealthCheck `json:"checks,omitempty"`
+}
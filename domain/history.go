@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HistoryCursor points at the last record of a history page, the next page starts after it
+type HistoryCursor struct {
+	EntryTime time.Time
+	RecordID  int64
+}
+
+// String encodes the cursor as an opaque token for clients
+func (c HistoryCursor) String() string {
+	value := fmt.Sprintf("%d:%d", c.EntryTime.UnixNano(), c.RecordID)
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+// ParseHistoryCursor decodes a cursor token returned by HistoryCursor.String
+func ParseHistoryCursor(token string) (*HistoryCursor, error) {
+	value, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	entryTime, recordID, ok := strings.Cut(string(value), ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid cursor %q", token)
+	}
+
+	nanos, err := strconv.ParseInt(entryTime, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	id, err := strconv.ParseInt(recordID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	return &HistoryCursor{
+		EntryTime: time.Unix(0, nanos),
+		RecordID:  id,
+	}, nil
+}
+
+// HistoryFilter selects a page of parking records, newest first
+type HistoryFilter struct {
+	// From and To limit the records to those entering from From (inclusive) until To (exclusive),
+	// nil for no limit
+	From *time.Time
+	To   *time.Time
+	// After is the cursor of the previous page, nil for the first page
+	After *HistoryCursor
+	Limit int
+}
+
+// ParkingHistoryEntry is a single stay of a vehicle
+type ParkingHistoryEntry struct {
+	TicketCode  string       `json:"ticket_code"`
+	ParkingSpot *ParkingSpot `json:"parking_spot"`
+	EntryTime   time.Time    `json:"entry_time"`
+	// ExitTime is nil while the vehicle is parked
+	ExitTime        *time.Time `json:"exit_time"`
+	DurationMinutes int64      `json:"duration_minutes"`
+	// Fee is the fee charged, or the fee accrued so far while the vehicle is parked
+	Fee         int64  `json:"fee"`
+	IsParked    bool   `json:"is_parked"`
+	EntryGateID *int64 `json:"entry_gate_id,omitempty"`
+	ExitGateID  *int64 `json:"exit_gate_id,omitempty"`
+}
+
+// ParkingHistory is a page of a vehicle's stays, newest first
+type ParkingHistory struct {
+	Entries []ParkingHistoryEntry
+	// NextCursor is the cursor of the next page, empty on the last page
+	NextCursor string
+}
+
+type VehicleHistoryResponse struct {
+	Success    bool                  `json:"success"`
+	Message    string                `json:"message"`
+	History    []ParkingHistoryEntry `json:"history"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
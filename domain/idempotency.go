@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotencyRecord is the first response to a request sent with an idempotency key, replayed to
+// retries of the request until it expires. StatusCode is 0 while the first request is still running.
+type IdempotencyRecord struct {
+	APIKeyID int64
+	Key      string
+	// RequestHash identifies the request, a key cannot be reused for a different request
+	RequestHash  string
+	StatusCode   int
+	ResponseBody []byte
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+	// LeaseExpiresAt is when a first request that has not finished is given up, a retry then takes over the key
+	LeaseExpiresAt time.Time
+}
+
+// IdempotencyRepository defines the interface for idempotency key operations
+type IdempotencyRepository interface {
+	// ReserveIdempotencyKey stores the record unless its key is already taken by a record that has not
+	// expired at now, which is returned instead. Expired records are deleted, and so is a record of the
+	// same request still in progress whose lease expired at now.
+	ReserveIdempotencyKey(ctx context.Context, record *IdempotencyRecord, now time.Time) (*IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, apiKeyID int64, key string, statusCode int, responseBody []byte) error
+	DeleteIdempotencyKey(ctx context.Context, apiKeyID int64, key string) error
+}
+
+// IdempotencyService defines the interface for replaying the responses of retried requests
+type IdempotencyService interface {
+	// StartRequest returns the completed record to replay for a retried request, or nil when the request
+	// is new and must be completed with FinishRequest
+	StartRequest(ctx context.Context, apiKeyID int64, key, requestHash string) (*IdempotencyRecord, error)
+	// FinishRequest stores the response of a started request. Server errors are not stored, so the
+	// request can be retried with the same key.
+	FinishRequest(ctx context.Context, apiKeyID int64, key string, statusCode int, responseBody []byte) error
+}
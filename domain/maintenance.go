@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SpotSelector selects a single spot, a row or a whole floor. Row and Column are 0 to select
+// every row of the floor or every spot of the row.
+type SpotSelector struct {
+	Floor  int `json:"floor"`
+	Row    int `json:"row"`
+	Column int `json:"column"`
+}
+
+func (s SpotSelector) Matches(spot ParkingSpot) bool {
+	return spot.Floor == s.Floor &&
+		(s.Row == 0 || spot.Row == s.Row) &&
+		(s.Column == 0 || spot.Column == s.Column)
+}
+
+func (s SpotSelector) String() string {
+	switch {
+	case s.Row == 0:
+		return fmt.Sprintf("floor %d", s.Floor)
+	case s.Column == 0:
+		return fmt.Sprintf("row %d-%d", s.Floor, s.Row)
+	default:
+		return fmt.Sprintf("spot %d-%d-%d", s.Floor, s.Row, s.Column)
+	}
+}
+
+// SpotMaintenance closes a spot for cleaning or repairs from StartTime until EndTime,
+// or until it is ended by an admin when EndTime is nil
+type SpotMaintenance struct {
+	ID            int64        `json:"id"`
+	ParkingSpotID int64        `json:"parking_spot_id"`
+	ParkingSpot   *ParkingSpot `json:"parking_spot,omitempty"`
+	Reason        string       `json:"reason"`
+	StartTime     time.Time    `json:"start_time"`
+	EndTime       *time.Time   `json:"end_time,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+// MaintenanceRepository defines the interface for spot maintenance operations
+type MaintenanceRepository interface {
+	// StartMaintenance closes the active spots matched by the selector until endTime, or until ended when nil.
+	// Unless force is set, nothing is closed when a vehicle is parked on any of the spots, and the occupied
+	// spots are returned instead.
+	StartMaintenance(ctx context.Context, selector SpotSelector, reason string, endTime *time.Time, force bool) ([]SpotMaintenance, []ParkingSpot, error)
+	// EndMaintenance reopens the spots matched by the selector and returns how many maintenances were ended
+	EndMaintenance(ctx context.Context, selector SpotSelector) (int64, error)
+	// GetActiveMaintenances returns the maintenances closing spots now, with their spot
+	GetActiveMaintenances(ctx context.Context) ([]SpotMaintenance, error)
+}
+
+// MaintenanceService defines the interface for spot maintenance business logic
+type MaintenanceService interface {
+	StartMaintenance(ctx context.Context, selector SpotSelector, reason string, endTime *time.Time, force bool) ([]SpotMaintenance, error)
+	EndMaintenance(ctx context.Context, selector SpotSelector) (int64, error)
+	GetActiveMaintenances(ctx context.Context) ([]SpotMaintenance, error)
+}
+
+type MaintenanceRequest struct {
+	SpotSelector
+	Reason string `json:"reason"`
+	// EndTime is when the spots open again, nil to keep them closed until the maintenance is ended
+	EndTime *time.Time `json:"end_time"`
+	// Force closes spots even when a vehicle is parked on them, the vehicle can still leave
+	Force bool `json:"force"`
+}
+
+type MaintenanceResponse struct {
+	Success      bool              `json:"success"`
+	Message      string            `json:"message"`
+	Maintenances []SpotMaintenance `json:"maintenances,omitempty"`
+}
@@ -1,22 +1,43 @@
 package domain
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"time"
 )
 
-type VehicleType string
+// ErrVehicleAlreadyParked is returned when a vehicle already has an open parking record
+var ErrVehicleAlreadyParked = NewConflictError(CodeVehicleAlreadyParked, "vehicle is already parked")
+
+// ErrVehicleNotParked is returned when a parking record to close has been closed already
+var ErrVehicleNotParked = NewConflictError(CodeVehicleNotParked, "vehicle is not parked")
+
+// SizeClass is the size of a parking spot, or the size of spot a vehicle needs
+type SizeClass string
 
 const (
-	Motorcycle VehicleType = "motorcycle"
-	Bicycle    VehicleType = "bicycle"
-	Car        VehicleType = "car"
+	SizeCompact  SizeClass = "compact"
+	SizeStandard SizeClass = "standard"
+	SizeLarge    SizeClass = "large"
 )
 
-func (t VehicleType) IsValid() bool {
-	return t == Motorcycle || t == Bicycle || t == Car
+// sizeClasses lists the size classes from smallest to largest
+var sizeClasses = []SizeClass{SizeCompact, SizeStandard, SizeLarge}
+
+func (c SizeClass) IsValid() bool {
+	return c == SizeCompact || c == SizeStandard || c == SizeLarge
+}
+
+// Larger returns the size classes larger than this one, from smallest to largest
+func (c SizeClass) Larger() []SizeClass {
+	for i, class := range sizeClasses {
+		if class == c {
+			return sizeClasses[i+1:]
+		}
+	}
+	return nil
 }
 
 type Vehicle struct {
@@ -28,13 +49,21 @@ type Vehicle struct {
 }
 
 type ParkingSpot struct {
-	ID        int64     `json:"id"`
-	Floor     int       `json:"floor"`
-	Row       int       `json:"row"`
-	Column    int       `json:"column"`
-	IsActive  bool      `json:"is_active"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          int64       `json:"id"`
+	Floor       int         `json:"floor"`
+	Row         int         `json:"row"`
+	Column      int         `json:"column"`
+	VehicleType VehicleType `json:"vehicle_type"`
+	SizeClass   SizeClass   `json:"size_class"`
+	IsActive    bool        `json:"is_active"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// SpotFilter narrows down parking spots by vehicle type and size class, zero values match any spot
+type SpotFilter struct {
+	VehicleType VehicleType
+	SizeClasses []SizeClass
 }
 
 func (p ParkingSpot) MarshalJSON() ([]byte, error) {
@@ -50,62 +79,152 @@ func (p ParkingSpot) MarshalJSON() ([]byte, error) {
 }
 
 type ParkingRecord struct {
-	ID            int64        `json:"id"`
-	VehicleID     int64        `json:"vehicle_id"`
-	ParkingSpotID int64        `json:"parking_spot_id"`
-	EntryTime     time.Time    `json:"entry_time"`
-	ExitTime      sql.NullTime `json:"exit_time"`
-	CreatedAt     time.Time    `json:"created_at"`
-	UpdatedAt     time.Time    `json:"updated_at"`
+	ID            int64         `json:"id"`
+	VehicleID     int64         `json:"vehicle_id"`
+	ParkingSpotID int64         `json:"parking_spot_id"`
+	EntryTime     time.Time     `json:"entry_time"`
+	ExitTime      sql.NullTime  `json:"exit_time"`
+	Fee           sql.NullInt64 `json:"fee"`
+	TicketCode    string        `json:"ticket_code"`
+	EntryGateID   sql.NullInt64 `json:"entry_gate_id"`
+	ExitGateID    sql.NullInt64 `json:"exit_gate_id"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
 }
 
 func (p ParkingRecord) IsParked() bool {
 	return !p.ExitTime.Valid
 }
 
+// Ticket is issued when a vehicle parks and identifies its stay, even without a license plate
+type Ticket struct {
+	Code          string    `json:"code"`
+	LicensePlate  string    `json:"license_plate,omitempty"`
+	ParkingSpotID int64     `json:"parking_spot_id"`
+	IssuedAt      time.Time `json:"issued_at"`
+}
+
+// ParkingLookup identifies a stay by ticket code or, when the ticket code is empty, by license plate
+type ParkingLookup struct {
+	TicketCode   string
+	LicensePlate string
+}
+
+func (l ParkingLookup) String() string {
+	if l.TicketCode != "" {
+		return fmt.Sprintf("ticket %s", l.TicketCode)
+	}
+	return fmt.Sprintf("license plate %s", l.LicensePlate)
+}
+
 // ParkingRepository defines the interface for parking spot operations
 type ParkingRepository interface {
-	GetAvailableSpots(floors ...int) ([]ParkingSpot, error)
-	GetSpotByID(id int64) (*ParkingSpot, error)
-	GetSpotByPosition(floor, row, column int) (*ParkingSpot, error)
-	UpdateSpotStatus(id int64, isActive bool) error
-	CreateParkingRecord(record *ParkingRecord) error
-	UpdateParkingRecord(record *ParkingRecord) error
-	GetLastParkingRecordByVehicleID(vehicleID int64) (*ParkingRecord, error)
+	GetAvailableSpots(ctx context.Context, filter SpotFilter) ([]ParkingSpot, error)
+	GetSpotByID(ctx context.Context, id int64) (*ParkingSpot, error)
+	GetSpotByPosition(ctx context.Context, floor, row, column int) (*ParkingSpot, error)
+	UpdateSpotStatus(ctx context.Context, id int64, isActive bool) error
+	UpdateSpotType(ctx context.Context, id int64, vehicleType VehicleType, sizeClass SizeClass) error
+	CreateParkingRecord(ctx context.Context, record *ParkingRecord) error
+	// UpdateParkingRecord closes the open parking record, it returns ErrVehicleNotParked if the record was
+	// closed already
+	UpdateParkingRecord(ctx context.Context, record *ParkingRecord) error
+	GetLastParkingRecordByVehicleID(ctx context.Context, vehicleID int64) (*ParkingRecord, error)
+	GetParkingRecordByTicketCode(ctx context.Context, ticketCode string) (*ParkingRecord, error)
+	// GetParkingRecordsByVehicleID returns the vehicle's records matching the filter, newest first
+	GetParkingRecordsByVehicleID(ctx context.Context, vehicleID int64, filter HistoryFilter) ([]ParkingRecord, error)
+	// ClaimSpot atomically assigns the first candidate spot that is still free to the vehicle and persists
+	// the record. A vehicle without an ID is created in the same transaction, so a failed claim leaves
+	// nothing behind. It returns nil if none of the candidates is available.
+	ClaimSpot(ctx context.Context, vehicle *Vehicle, record *ParkingRecord, candidateIDs []int64) (*ParkingSpot, error)
+	GetAllSpots(ctx context.Context) ([]ParkingSpot, error)
+	// CountActiveSpots returns the number of active spots, whether free or not
+	CountActiveSpots(ctx context.Context) (int64, error)
+	CreateSpot(ctx context.Context, spot *ParkingSpot) error
+	// DeactivateSpotIfFree deactivates the spot unless a vehicle is parked on it,
+	// and reports whether it was deactivated
+	DeactivateSpotIfFree(ctx context.Context, id int64) (bool, error)
+	// GetSpotChanges returns the spots taken or freed by parking records written after since, oldest first
+	GetSpotChanges(ctx context.Context, since time.Time) ([]SpotChange, error)
 }
 
 // VehicleRepository defines the interface for vehicle operations
 type VehicleRepository interface {
-	GetVehicleByLicensePlate(licensePlate string) (*Vehicle, error)
-	CreateVehicle(vehicle *Vehicle) error
+	GetVehicleByID(ctx context.Context, id int64) (*Vehicle, error)
+	GetVehicleByLicensePlate(ctx context.Context, licensePlate string) (*Vehicle, error)
+	CreateVehicle(ctx context.Context, vehicle *Vehicle) error
 }
 
 // ParkingService defines the interface for parking business logic
 type ParkingService interface {
-	ParkVehicle(licensePlate string, vehicleType VehicleType) (*ParkingSpot, error)
-	UnparkVehicle(licensePlate string) error
-	GetAllAvailableSpots() ([]ParkingSpot, error)
-	SearchVehicle(licensePlate string) (*ParkingSpot, bool, error)
+	// ParkVehicle parks a vehicle needing a spot of the size class, entering through the gate.
+	// sizeClass is empty for the vehicle type's default and gateID is 0 if unknown.
+	ParkVehicle(ctx context.Context, licensePlate string, vehicleType VehicleType, sizeClass SizeClass, gateID int64) (*ParkingSpot, *Ticket, error)
+	// UnparkVehicle unparks a vehicle leaving through the gate, gateID is 0 if unknown
+	UnparkVehicle(ctx context.Context, lookup ParkingLookup, gateID int64) (*ParkingFee, error)
+	// UnparkLostTicket unparks a vehicle by license plate and charges the lost ticket penalty
+	UnparkLostTicket(ctx context.Context, licensePlate string, gateID int64) (*ParkingFee, error)
+	GetAllAvailableSpots(ctx context.Context) ([]ParkingSpot, error)
+	SearchVehicle(ctx context.Context, lookup ParkingLookup) (*ParkingSpot, bool, error)
+	QuoteFee(ctx context.Context, lookup ParkingLookup) (*ParkingFee, error)
+	// GetVehicleHistory returns a page of the stays of the vehicle with the license plate
+	GetVehicleHistory(ctx context.Context, licensePlate string, filter HistoryFilter) (*ParkingHistory, error)
+}
+
+// LayoutService defines the interface for keeping parking spots in line with the configured layout
+type LayoutService interface {
+	ReconcileLayout(ctx context.Context, dryRun bool) (*LayoutDiff, error)
+}
+
+// LayoutDiff describes the changes needed to bring the parking spots in line with the configured layout
+type LayoutDiff struct {
+	Added       []ParkingSpot `json:"added"`
+	Reactivated []ParkingSpot `json:"reactivated"`
+	Deactivated []ParkingSpot `json:"deactivated"`
+	// Retyped lists spots whose vehicle type or size class changed, with their new type and class
+	Retyped []ParkingSpot `json:"retyped"`
+	// Occupied lists spots that are no longer in the layout but were kept because a vehicle is parked on them
+	Occupied []ParkingSpot `json:"occupied"`
+}
+
+func (d LayoutDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Reactivated) == 0 && len(d.Deactivated) == 0 && len(d.Retyped) == 0 && len(d.Occupied) == 0
 }
 
 type ParkRequest struct {
 	LicensePlate string      `json:"license_plate"`
 	VehicleType  VehicleType `json:"vehicle_type"`
+	// SizeClass is the size of spot the vehicle needs, empty for the vehicle type's default
+	SizeClass SizeClass `json:"size_class"`
+	// GateID is the gate the vehicle enters through, 0 if unknown
+	GateID int64 `json:"gate_id"`
 }
 
 type ParkResponse struct {
 	Success     bool         `json:"success"`
 	Message     string       `json:"message"`
 	ParkingSpot *ParkingSpot `json:"parking_spot,omitempty"`
+	Ticket      *Ticket      `json:"ticket,omitempty"`
 }
 
 type UnparkRequest struct {
+	TicketCode   string `json:"ticket_code"`
 	LicensePlate string `json:"license_plate"`
+	// LostTicket unparks by license plate and charges the lost ticket penalty
+	LostTicket bool `json:"lost_ticket"`
+	// GateID is the gate the vehicle leaves through, 0 if unknown
+	GateID int64 `json:"gate_id"`
 }
 
 type UnparkResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Fee     *ParkingFee `json:"fee,omitempty"`
+}
+
+type QuoteResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Fee     *ParkingFee `json:"fee,omitempty"`
 }
 
 type SearchResponse struct {
@@ -119,10 +238,16 @@ type AvailableSpotsResponse struct {
 	Success      bool                 `json:"success"`
 	Message      string               `json:"message"`
 	ParkingSpots ParkingSpotByVehicle `json:"parking_spots,omitempty"`
+	// Maintenance lists the spots closed for maintenance now, which are not available
+	Maintenance []SpotMaintenance `json:"maintenance,omitempty"`
 }
 
-type ParkingSpotByVehicle struct {
-	Car        []ParkingSpot `json:"car"`
-	Motorcycle []ParkingSpot `json:"motorcycle"`
-	Bicycle    []ParkingSpot `json:"bicycle"`
+// ParkingSpotByVehicle groups parking spots by vehicle type, every registered type is present
+type ParkingSpotByVehicle map[VehicleType][]ParkingSpot
+
+type ReconcileLayoutResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	DryRun  bool        `json:"dry_run"`
+	Diff    *LayoutDiff `json:"diff,omitempty"`
 }
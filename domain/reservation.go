@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrSpotUnavailable is returned when a specific spot is requested but it is occupied or inactive
+var ErrSpotUnavailable = errors.New("parking spot is not available")
+
+// ErrReservationNotActive is returned when a reservation was claimed, cancelled or expired in the meantime
+var ErrReservationNotActive = errors.New("reservation is not active")
+
+type ReservationStatus string
+
+const (
+	// ReservationActive reservations hold their spot until claimed, cancelled or expired
+	ReservationActive    ReservationStatus = "active"
+	ReservationClaimed   ReservationStatus = "claimed"
+	ReservationCancelled ReservationStatus = "cancelled"
+	// ReservationExpired reservations were released because the vehicle did not show up in time
+	ReservationExpired ReservationStatus = "expired"
+)
+
+type Reservation struct {
+	ID            int64       `json:"id"`
+	ParkingSpotID int64       `json:"parking_spot_id"`
+	LicensePlate  string      `json:"license_plate"`
+	VehicleType   VehicleType `json:"vehicle_type"`
+	StartTime     time.Time   `json:"start_time"`
+	EndTime       time.Time   `json:"end_time"`
+	// HeldFrom is when the spot stops being given to other vehicles, ahead of the start time
+	HeldFrom  time.Time         `json:"held_from"`
+	Status    ReservationStatus `json:"status"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// CanBeClaimedAt reports whether the reserved vehicle can park on the reserved spot at the given time,
+// which is from the grace period before the start time until the end time
+func (r Reservation) CanBeClaimedAt(at time.Time, gracePeriod time.Duration) bool {
+	return r.Status == ReservationActive && !at.Before(r.StartTime.Add(-gracePeriod)) && at.Before(r.EndTime)
+}
+
+// HoldsSpotAt reports whether the reservation keeps other vehicles off its spot at the given time,
+// which is from its hold until its end time
+func (r Reservation) HoldsSpotAt(at time.Time) bool {
+	return r.Status == ReservationActive && !at.Before(r.HeldFrom) && at.Before(r.EndTime)
+}
+
+// ReservationRepository defines the interface for reservation operations
+type ReservationRepository interface {
+	// CreateReservation atomically picks a free active spot matching the filter without an overlapping
+	// reservation and stores the reservation for it. It returns nil if no spot is available.
+	CreateReservation(ctx context.Context, reservation *Reservation, filter SpotFilter) (*ParkingSpot, error)
+	GetReservationByID(ctx context.Context, id int64) (*Reservation, error)
+	GetReservationsByLicensePlate(ctx context.Context, licensePlate string) ([]Reservation, error)
+	// UpdateReservationStatus moves an active reservation to the status.
+	// It returns ErrReservationNotActive if the reservation is no longer active.
+	UpdateReservationStatus(ctx context.Context, id int64, status ReservationStatus) error
+	// ClaimReservation atomically parks the vehicle on the reserved spot and marks the reservation as claimed,
+	// creating the vehicle first like ParkingRepository.ClaimSpot if it has no ID.
+	// It returns ErrSpotUnavailable if the spot is inactive or another vehicle is parked on it, or if the
+	// reservation is no longer active.
+	ClaimReservation(ctx context.Context, vehicle *Vehicle, record *ParkingRecord, reservation *Reservation) (*ParkingSpot, error)
+	// ExpireReservations marks active reservations that started before the given time as expired
+	// and returns how many were expired
+	ExpireReservations(ctx context.Context, startedBefore time.Time) (int64, error)
+}
+
+// ReservationService defines the interface for reservation business logic
+type ReservationService interface {
+	CreateReservation(ctx context.Context, licensePlate string, vehicleType VehicleType, startTime, endTime time.Time) (*Reservation, *ParkingSpot, error)
+	GetReservation(ctx context.Context, id int64) (*Reservation, error)
+	GetReservationsByLicensePlate(ctx context.Context, licensePlate string) ([]Reservation, error)
+	CancelReservation(ctx context.Context, id int64) error
+	// ExpireNoShows releases reservations whose vehicle did not arrive within the grace period
+	ExpireNoShows(ctx context.Context) (int64, error)
+}
+
+type ReservationRequest struct {
+	LicensePlate string      `json:"license_plate"`
+	VehicleType  VehicleType `json:"vehicle_type"`
+	StartTime    time.Time   `json:"start_time"`
+	EndTime      time.Time   `json:"end_time"`
+}
+
+type ReservationResponse struct {
+	Success     bool         `json:"success"`
+	Message     string       `json:"message"`
+	Reservation *Reservation `json:"reservation,omitempty"`
+	ParkingSpot *ParkingSpot `json:"parking_spot,omitempty"`
+}
+
+type ReservationsResponse struct {
+	Success      bool          `json:"success"`
+	Message      string        `json:"message"`
+	Reservations []Reservation `json:"reservations"`
+}
@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// SpotCounts counts parking spots by state, every spot is counted in exactly one state
+type SpotCounts struct {
+	Total    int64 `json:"total"`
+	Occupied int64 `json:"occupied"`
+	// Reserved spots are free but held for a reservation that is active now
+	Reserved int64 `json:"reserved"`
+	// Inactive spots are removed from the layout or closed for maintenance, and free
+	Inactive int64 `json:"inactive"`
+	Free     int64 `json:"free"`
+}
+
+func (c *SpotCounts) Add(other SpotCounts) {
+	c.Total += other.Total
+	c.Occupied += other.Occupied
+	c.Reserved += other.Reserved
+	c.Inactive += other.Inactive
+	c.Free += other.Free
+}
+
+// Occupancy is the spot counts of a group of spots with their percentages. Percentages are relative to
+// the spots in use, which are all spots except the inactive ones.
+type Occupancy struct {
+	SpotCounts
+	OccupiedPercent float64 `json:"occupied_percent"`
+	ReservedPercent float64 `json:"reserved_percent"`
+	FreePercent     float64 `json:"free_percent"`
+}
+
+func NewOccupancy(counts SpotCounts) Occupancy {
+	occupancy := Occupancy{SpotCounts: counts}
+	if inUse := counts.Total - counts.Inactive; inUse > 0 {
+		occupancy.OccupiedPercent = percent(counts.Occupied, inUse)
+		occupancy.ReservedPercent = percent(counts.Reserved, inUse)
+		occupancy.FreePercent = percent(counts.Free, inUse)
+	}
+	return occupancy
+}
+
+// percent returns part of whole as a percentage rounded to two decimals
+func percent(part, whole int64) float64 {
+	return math.Round(float64(part)*10000/float64(whole)) / 100
+}
+
+type FloorOccupancy struct {
+	Floor int `json:"floor"`
+	Occupancy
+}
+
+type VehicleTypeOccupancy struct {
+	VehicleType VehicleType `json:"vehicle_type"`
+	Occupancy
+}
+
+// OccupancyStats is the occupancy of the whole lot, of each floor and of each vehicle type
+type OccupancyStats struct {
+	Lot          Occupancy              `json:"lot"`
+	Floors       []FloorOccupancy       `json:"floors"`
+	VehicleTypes []VehicleTypeOccupancy `json:"vehicle_types"`
+	ComputedAt   time.Time              `json:"computed_at"`
+}
+
+// OccupancyCount is the spot counts of the spots of one vehicle type on one floor
+type OccupancyCount struct {
+	Floor       int
+	VehicleType VehicleType
+	SpotCounts
+}
+
+// StatsRepository defines the interface for aggregate statistics
+type StatsRepository interface {
+	// GetOccupancyCounts counts the spots in each state at the given time, per floor and vehicle type
+	GetOccupancyCounts(ctx context.Context, at time.Time) ([]OccupancyCount, error)
+}
+
+// StatsService defines the interface for statistics business logic
+type StatsService interface {
+	GetOccupancy(ctx context.Context) (*OccupancyStats, error)
+}
+
+type OccupancyResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Stats   *OccupancyStats `json:"stats,omitempty"`
+}
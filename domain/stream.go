@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+type StreamEventType string
+
+const (
+	StreamSpotOccupied StreamEventType = "spot.occupied"
+	StreamSpotFreed    StreamEventType = "spot.freed"
+	// StreamFloorCapacityChanged carries the spot counts of a vehicle type on a floor after a spot changed
+	StreamFloorCapacityChanged StreamEventType = "floor.capacity_changed"
+	// StreamReset tells a resuming client that events were missed, it should reload the state it shows
+	StreamReset StreamEventType = "stream.reset"
+)
+
+// StreamEvent is an event of the live event stream. IDs increase within a stream, a client resumes
+// after the last ID it received.
+type StreamEvent struct {
+	ID          string          `json:"id"`
+	Type        StreamEventType `json:"type"`
+	Floor       int             `json:"floor,omitempty"`
+	VehicleType VehicleType     `json:"vehicle_type,omitempty"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	ParkingSpot *ParkingSpot    `json:"parking_spot,omitempty"`
+	Capacity    *SpotCounts     `json:"capacity,omitempty"`
+}
+
+// StreamFilter selects the events of some floors and vehicle types, an empty list selects all of them
+type StreamFilter struct {
+	Floors       []int
+	VehicleTypes []VehicleType
+}
+
+// Matches reports whether the event is selected by the filter, reset events always are
+func (f StreamFilter) Matches(event StreamEvent) bool {
+	if event.Type == StreamReset {
+		return true
+	}
+	if len(f.Floors) > 0 && !slices.Contains(f.Floors, event.Floor) {
+		return false
+	}
+	if len(f.VehicleTypes) > 0 && !slices.Contains(f.VehicleTypes, event.VehicleType) {
+		return false
+	}
+	return true
+}
+
+// SpotChange is a spot taken or freed, read back from the parking records so the parks and unparks of every
+// instance sharing the storage are streamed
+type SpotChange struct {
+	RecordID int64
+	SpotID   int64
+	Freed    bool
+	// ChangedAt is when the record was written, by the clock of the instance writing it
+	ChangedAt time.Time
+	// TakenAt is when the record was first written, as the vehicle parked
+	TakenAt time.Time
+}
+
+// SpotNotifier is told about spots taken or freed, after the change is saved
+type SpotNotifier interface {
+	SpotOccupied(ctx context.Context, spotID int64)
+	SpotFreed(ctx context.Context, spotID int64)
+}
+
+// EventStream turns spot changes into stream events for any number of subscribers
+type EventStream interface {
+	SpotNotifier
+	// Subscribe returns the buffered events after lastEventID that match the filter, and a channel receiving
+	// the later ones. The channel is closed when the subscriber falls behind or the stream stops, cancel
+	// must be called once the subscriber is done.
+	Subscribe(filter StreamFilter, lastEventID string) (replay []StreamEvent, events <-chan StreamEvent, cancel func())
+}
@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"time"
+)
+
+// Tariff defines how parking is charged for a vehicle type.
+// All amounts are in the smallest currency unit.
+type Tariff struct {
+	// FirstHourRate is charged for the first started hour
+	FirstHourRate int64
+	// HourlyRate is charged for every following started hour
+	HourlyRate int64
+	// GracePeriod is how long a vehicle can stay free of charge
+	GracePeriod time.Duration
+	// DailyCap is the maximum charged per 24 hours since entry, 0 means no cap
+	DailyCap int64
+	// OvernightRate replaces the hourly charges between OvernightStartHour and OvernightEndHour
+	// with a flat amount per night, 0 means overnight hours are charged hourly
+	OvernightRate      int64
+	OvernightStartHour int
+	OvernightEndHour   int
+}
+
+// Calculate returns the fee for a stay from entry to exit. Every started hour is charged,
+// hours within the overnight window are replaced by one flat overnight charge per night,
+// and the total of every 24 hours since entry is limited by the daily cap.
+func (t Tariff) Calculate(entry, exit time.Time) int64 {
+	duration := exit.Sub(entry)
+	if duration <= t.GracePeriod {
+		return 0
+	}
+
+	hours := int((duration + time.Hour - 1) / time.Hour)
+
+	// charges per 24 hours since entry, so the daily cap can be applied to each of them
+	dayCharges := make([]int64, (hours+23)/24)
+	chargedNights := make(map[time.Time]bool)
+	for i := 0; i < hours; i++ {
+		start := entry.Add(time.Duration(i) * time.Hour)
+
+		if night, ok := t.overnight(start); ok {
+			if !chargedNights[night] {
+				chargedNights[night] = true
+				dayCharges[i/24] += t.OvernightRate
+			}
+			continue
+		}
+
+		if i == 0 {
+			dayCharges[i/24] += t.FirstHourRate
+		} else {
+			dayCharges[i/24] += t.HourlyRate
+		}
+	}
+
+	var total int64
+	for _, charge := range dayCharges {
+		if t.DailyCap > 0 && charge > t.DailyCap {
+			charge = t.DailyCap
+		}
+		total += charge
+	}
+
+	return total
+}
+
+// overnight reports whether the time falls in the overnight window, and if so, the date the night started on.
+// The window is in the local time zone, whichever zone the storage returned the time in.
+func (t Tariff) overnight(at time.Time) (time.Time, bool) {
+	if t.OvernightRate <= 0 || t.OvernightStartHour == t.OvernightEndHour {
+		return time.Time{}, false
+	}
+
+	at = at.Local()
+	hour := at.Hour()
+	date := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+
+	if t.OvernightStartHour < t.OvernightEndHour {
+		// window within a single day, e.g. 00:00 - 06:00
+		return date, hour >= t.OvernightStartHour && hour < t.OvernightEndHour
+	}
+
+	// window across midnight, e.g. 22:00 - 06:00
+	if hour >= t.OvernightStartHour {
+		return date, true
+	}
+	if hour < t.OvernightEndHour {
+		return date.AddDate(0, 0, -1), true
+	}
+	return time.Time{}, false
+}
+
+// ParkingFee is the fee for a stay, either final after unparking or accrued so far
+type ParkingFee struct {
+	EntryTime       time.Time `json:"entry_time"`
+	ExitTime        time.Time `json:"exit_time"`
+	DurationMinutes int64     `json:"duration_minutes"`
+	// Penalty is the part of Amount charged for a lost ticket
+	Penalty int64 `json:"penalty,omitempty"`
+	Amount  int64 `json:"amount"`
+}
@@ -0,0 +1,116 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTariffCalculate(t *testing.T) {
+	tariff := Tariff{
+		FirstHourRate:      5000,
+		HourlyRate:         3000,
+		GracePeriod:        15 * time.Minute,
+		DailyCap:           40000,
+		OvernightStartHour: 22,
+		OvernightEndHour:   6,
+	}
+	overnightTariff := tariff
+	overnightTariff.OvernightRate = 8000
+
+	entry := time.Date(2026, 3, 10, 9, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name   string
+		tariff Tariff
+		entry  time.Time
+		exit   time.Time
+		want   int64
+	}{
+		{
+			name:   "free exactly at the grace period",
+			tariff: tariff,
+			entry:  entry,
+			exit:   entry.Add(15 * time.Minute),
+			want:   0,
+		},
+		{
+			name:   "first hour one minute past the grace period",
+			tariff: tariff,
+			entry:  entry,
+			exit:   entry.Add(16 * time.Minute),
+			want:   5000,
+		},
+		{
+			name:   "every started hour",
+			tariff: tariff,
+			entry:  entry,
+			exit:   entry.Add(2*time.Hour + time.Minute),
+			want:   5000 + 2*3000,
+		},
+		{
+			name:   "hourly across midnight without an overnight rate",
+			tariff: tariff,
+			entry:  time.Date(2026, 3, 10, 23, 30, 0, 0, time.Local),
+			exit:   time.Date(2026, 3, 11, 0, 45, 0, 0, time.Local),
+			want:   5000 + 3000,
+		},
+		{
+			name:   "one overnight charge across midnight",
+			tariff: overnightTariff,
+			entry:  time.Date(2026, 3, 10, 21, 0, 0, 0, time.Local),
+			exit:   time.Date(2026, 3, 11, 7, 0, 0, 0, time.Local),
+			want:   5000 + 8000 + 3000,
+		},
+		{
+			name:   "capped exactly at 24 hours",
+			tariff: tariff,
+			entry:  entry,
+			exit:   entry.Add(24 * time.Hour),
+			want:   40000,
+		},
+		{
+			name:   "second day starts after 24 hours",
+			tariff: tariff,
+			entry:  entry,
+			exit:   entry.Add(24*time.Hour + time.Minute),
+			want:   40000 + 3000,
+		},
+		{
+			name:   "capped per day over more than 48 hours",
+			tariff: tariff,
+			entry:  entry,
+			exit:   entry.Add(50 * time.Hour),
+			want:   2*40000 + 2*3000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.tariff.Calculate(tt.entry, tt.exit); got != tt.want {
+				t.Errorf("Calculate() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTariffCalculateOvernightInLocalTime(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+2", 2*60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	tariff := Tariff{
+		FirstHourRate:      5000,
+		HourlyRate:         3000,
+		OvernightRate:      8000,
+		OvernightStartHour: 22,
+		OvernightEndHour:   6,
+	}
+
+	// 21:00 to 07:00 local time, returned in UTC like by a database session in UTC
+	entry := time.Date(2026, 3, 10, 19, 0, 0, 0, time.UTC)
+	exit := time.Date(2026, 3, 11, 5, 0, 0, 0, time.UTC)
+
+	if got, want := tariff.Calculate(entry, exit), int64(5000+8000+3000); got != want {
+		t.Errorf("Calculate() = %d, want %d", got, want)
+	}
+}
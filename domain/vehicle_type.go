@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+type VehicleType string
+
+const (
+	Motorcycle      VehicleType = "motorcycle"
+	Bicycle         VehicleType = "bicycle"
+	Car             VehicleType = "car"
+	Truck           VehicleType = "truck"
+	Van             VehicleType = "van"
+	ElectricVehicle VehicleType = "ev"
+	Bus             VehicleType = "bus"
+	DisabledAccess  VehicleType = "disabled"
+)
+
+// VehicleTypeDefinition describes how vehicles of a type park
+type VehicleTypeDefinition struct {
+	Type VehicleType `json:"type"`
+	// SizeClass is the size of spot vehicles of this type need unless told otherwise
+	SizeClass SizeClass `json:"size_class"`
+	// RequiresLicensePlate is false for vehicles that can park without a license plate,
+	// they are identified by their ticket only
+	RequiresLicensePlate bool `json:"requires_license_plate"`
+}
+
+// BuiltinVehicleTypes are the vehicle types known out of the box
+var BuiltinVehicleTypes = []VehicleTypeDefinition{
+	{Type: Car, SizeClass: SizeStandard, RequiresLicensePlate: true},
+	{Type: Motorcycle, SizeClass: SizeCompact, RequiresLicensePlate: true},
+	{Type: Bicycle, SizeClass: SizeCompact, RequiresLicensePlate: false},
+	{Type: Truck, SizeClass: SizeLarge, RequiresLicensePlate: true},
+	{Type: Van, SizeClass: SizeLarge, RequiresLicensePlate: true},
+	{Type: ElectricVehicle, SizeClass: SizeStandard, RequiresLicensePlate: true},
+	{Type: Bus, SizeClass: SizeLarge, RequiresLicensePlate: true},
+	{Type: DisabledAccess, SizeClass: SizeStandard, RequiresLicensePlate: true},
+}
+
+// VehicleTypeRegistry holds the vehicle types the parking lot accepts
+type VehicleTypeRegistry struct {
+	definitions map[VehicleType]VehicleTypeDefinition
+	types       []VehicleType
+}
+
+func NewVehicleTypeRegistry(definitions ...VehicleTypeDefinition) *VehicleTypeRegistry {
+	registry := &VehicleTypeRegistry{
+		definitions: make(map[VehicleType]VehicleTypeDefinition, len(definitions)),
+	}
+	for _, definition := range definitions {
+		if _, exists := registry.definitions[definition.Type]; !exists {
+			registry.types = append(registry.types, definition.Type)
+		}
+		registry.definitions[definition.Type] = definition
+	}
+	return registry
+}
+
+// Get returns the definition of the vehicle type, and whether it is registered
+func (r *VehicleTypeRegistry) Get(vehicleType VehicleType) (VehicleTypeDefinition, bool) {
+	definition, ok := r.definitions[vehicleType]
+	return definition, ok
+}
+
+func (r *VehicleTypeRegistry) IsValid(vehicleType VehicleType) bool {
+	_, ok := r.definitions[vehicleType]
+	return ok
+}
+
+// Types returns the registered vehicle types in the order they were registered
+func (r *VehicleTypeRegistry) Types() []VehicleType {
+	return r.types
+}
+
+// Definitions returns the registered vehicle type definitions in the order they were registered
+func (r *VehicleTypeRegistry) Definitions() []VehicleTypeDefinition {
+	definitions := make([]VehicleTypeDefinition, 0, len(r.types))
+	for _, vehicleType := range r.types {
+		definitions = append(definitions, r.definitions[vehicleType])
+	}
+	return definitions
+}
+
+// DefaultSizeClass returns the size class vehicles of the type need unless told otherwise
+func (r *VehicleTypeRegistry) DefaultSizeClass(vehicleType VehicleType) SizeClass {
+	return r.definitions[vehicleType].SizeClass
+}
+
+// RequiresLicensePlate reports whether vehicles of the type must have a license plate to park
+func (r *VehicleTypeRegistry) RequiresLicensePlate(vehicleType VehicleType) bool {
+	definition, ok := r.definitions[vehicleType]
+	return !ok || definition.RequiresLicensePlate
+}
+
+// String lists the registered vehicle types for error messages, e.g. "'car', 'motorcycle' or 'bicycle'"
+func (r *VehicleTypeRegistry) String() string {
+	quoted := make([]string, len(r.types))
+	for i, vehicleType := range r.types {
+		quoted[i] = fmt.Sprintf("'%s'", vehicleType)
+	}
+	if len(quoted) < 2 {
+		return strings.Join(quoted, "")
+	}
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " or " + quoted[len(quoted)-1]
+}
+
+type VehicleTypesResponse struct {
+	Success      bool                    `json:"success"`
+	Message      string                  `json:"message"`
+	VehicleTypes []VehicleTypeDefinition `json:"vehicle_types,omitempty"`
+}
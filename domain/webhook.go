@@ -0,0 +1,167 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Transactor runs a function in a single transaction. Repository calls made with the context passed to
+// the function take part in the transaction, which is committed when the function returns nil.
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type EventType string
+
+const (
+	EventVehicleParked   EventType = "vehicle.parked"
+	EventVehicleUnparked EventType = "vehicle.unparked"
+	// EventLotFull is sent when a vehicle takes the last free spot for its vehicle type
+	EventLotFull EventType = "lot.full"
+	// EventSpotDisabled is sent for every spot closed for maintenance
+	EventSpotDisabled EventType = "spot.disabled"
+)
+
+var EventTypes = []EventType{EventVehicleParked, EventVehicleUnparked, EventLotFull, EventSpotDisabled}
+
+func (t EventType) IsValid() bool {
+	for _, eventType := range EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is the body of a webhook request
+type Event struct {
+	ID         string          `json:"id"`
+	Type       EventType       `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+type VehicleParkedEvent struct {
+	LicensePlate string      `json:"license_plate,omitempty"`
+	VehicleType  VehicleType `json:"vehicle_type"`
+	TicketCode   string      `json:"ticket_code"`
+	ParkingSpot  ParkingSpot `json:"parking_spot"`
+	EntryTime    time.Time   `json:"entry_time"`
+	EntryGateID  *int64      `json:"entry_gate_id,omitempty"`
+}
+
+type VehicleUnparkedEvent struct {
+	LicensePlate  string      `json:"license_plate,omitempty"`
+	VehicleType   VehicleType `json:"vehicle_type"`
+	TicketCode    string      `json:"ticket_code"`
+	ParkingSpotID int64       `json:"parking_spot_id"`
+	Fee           ParkingFee  `json:"fee"`
+	ExitGateID    *int64      `json:"exit_gate_id,omitempty"`
+}
+
+type LotFullEvent struct {
+	VehicleType VehicleType `json:"vehicle_type"`
+}
+
+type SpotDisabledEvent struct {
+	ParkingSpot ParkingSpot `json:"parking_spot"`
+	Reason      string      `json:"reason"`
+	StartTime   time.Time   `json:"start_time"`
+	EndTime     *time.Time  `json:"end_time,omitempty"`
+}
+
+// EventPublisher publishes domain events. Events published within a transaction of the Transactor are
+// only delivered once it commits.
+type EventPublisher interface {
+	Publish(ctx context.Context, eventType EventType, data any) error
+}
+
+// Webhook receives the events of its event types as signed POST requests
+type Webhook struct {
+	ID         int64       `json:"id"`
+	URL        string      `json:"url"`
+	EventTypes []EventType `json:"event_types"`
+	// Secret signs the requests, it is only returned when the webhook is created
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryDead is a delivery that failed too often and is no longer retried
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// WebhookDelivery is an event waiting for, or done with, delivery to a webhook
+type WebhookDelivery struct {
+	ID            int64           `json:"id"`
+	WebhookID     int64           `json:"webhook_id"`
+	URL           string          `json:"url"`
+	Secret        string          `json:"-"`
+	EventID       string          `json:"event_id"`
+	EventType     EventType       `json:"event_type"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        DeliveryStatus  `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     string          `json:"last_error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// WebhookRepository defines the interface for webhook operations
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, webhook *Webhook) error
+	GetWebhooks(ctx context.Context) ([]Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) (bool, error)
+	// EnqueueEvent adds a pending delivery of the event for every webhook subscribed to its type
+	EnqueueEvent(ctx context.Context, event Event, payload []byte) error
+	// ClaimDueDeliveries returns up to limit pending deliveries due at now, and postpones them by lease
+	// so concurrent dispatchers skip them while they are delivered
+	ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]WebhookDelivery, error)
+	// UpdateDelivery stores the status, attempts, next attempt and last error of the delivery
+	UpdateDelivery(ctx context.Context, delivery *WebhookDelivery) error
+	GetDeliveriesByStatus(ctx context.Context, status DeliveryStatus) ([]WebhookDelivery, error)
+	// RetryDeadDelivery makes a dead delivery pending again at now and reports whether it was found
+	RetryDeadDelivery(ctx context.Context, id int64, now time.Time) (bool, error)
+	// DeleteDeliveries deletes the deliveries with the status last updated before updatedBefore
+	DeleteDeliveries(ctx context.Context, status DeliveryStatus, updatedBefore time.Time) (int64, error)
+}
+
+// WebhookService defines the interface for webhook business logic
+type WebhookService interface {
+	EventPublisher
+	CreateWebhook(ctx context.Context, url string, eventTypes []EventType) (*Webhook, error)
+	GetWebhooks(ctx context.Context) ([]Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	// GetDeadDeliveries returns the deliveries that are no longer retried
+	GetDeadDeliveries(ctx context.Context) ([]WebhookDelivery, error)
+	RetryDeadDelivery(ctx context.Context, id int64) error
+}
+
+type WebhookRequest struct {
+	URL        string      `json:"url"`
+	EventTypes []EventType `json:"event_types"`
+}
+
+type WebhookResponse struct {
+	Success bool     `json:"success"`
+	Message string   `json:"message"`
+	Webhook *Webhook `json:"webhook,omitempty"`
+}
+
+type WebhooksResponse struct {
+	Success  bool      `json:"success"`
+	Message  string    `json:"message"`
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+type WebhookDeliveriesResponse struct {
+	Success    bool              `json:"success"`
+	Message    string            `json:"message"`
+	Deliveries []WebhookDelivery `json:"deliveries"`
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"parking-lot/domain"
+)
+
+type AdminHandler struct {
+	layoutService      domain.LayoutService
+	maintenanceService domain.MaintenanceService
+}
+
+func NewAdminHandler(layoutService domain.LayoutService, maintenanceService domain.MaintenanceService) *AdminHandler {
+	return &AdminHandler{
+		layoutService:      layoutService,
+		maintenanceService: maintenanceService,
+	}
+}
+
+func (h *AdminHandler) ReconcileLayout(c echo.Context) error {
+	dryRun := false
+	if value := c.QueryParam("dry_run"); value != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			return domain.NewInvalidRequestError(domain.CodeInvalidRequest, "Invalid dry_run value. Must be 'true' or 'false'")
+		}
+	}
+
+	diff, err := h.layoutService.ReconcileLayout(c.Request().Context(), dryRun)
+	if err != nil {
+		return err
+	}
+
+	message := "Parking layout reconciled successfully"
+	if dryRun {
+		message = "Parking layout changes computed, nothing was applied"
+	}
+
+	return c.JSON(http.StatusOK, domain.ReconcileLayoutResponse{
+		Success: true,
+		Message: message,
+		DryRun:  dryRun,
+		Diff:    diff,
+	})
+}
+
+// DeactivateSpots closes a spot, a row or a whole floor for maintenance
+func (h *AdminHandler) DeactivateSpots(c echo.Context) error {
+	var req domain.MaintenanceRequest
+	if err := c.Bind(&req); err != nil {
+		return domain.NewInvalidRequestError(domain.CodeInvalidRequest, "Invalid request format")
+	}
+
+	if req.Floor < 1 {
+		return domain.NewValidationError(domain.CodeInvalidMaintenance, "Floor is required")
+	}
+
+	if req.Reason == "" {
+		return domain.NewValidationError(domain.CodeInvalidMaintenance, "Reason is required")
+	}
+
+	maintenances, err := h.maintenanceService.StartMaintenance(c.Request().Context(), req.SpotSelector, req.Reason, req.EndTime, req.Force)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, domain.MaintenanceResponse{
+		Success:      true,
+		Message:      fmt.Sprintf("%d parking spot(s) closed for maintenance", len(maintenances)),
+		Maintenances: maintenances,
+	})
+}
+
+// ReactivateSpots ends the maintenance of a spot, a row or a whole floor
+func (h *AdminHandler) ReactivateSpots(c echo.Context) error {
+	var req domain.SpotSelector
+	if err := c.Bind(&req); err != nil {
+		return domain.NewInvalidRequestError(domain.CodeInvalidRequest, "Invalid request format")
+	}
+
+	if req.Floor < 1 {
+		return domain.NewValidationError(domain.CodeInvalidMaintenance, "Floor is required")
+	}
+
+	ended, err := h.maintenanceService.EndMaintenance(c.Request().Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, domain.MaintenanceResponse{
+		Success: true,
+		Message: fmt.Sprintf("%d parking spot(s) reopened", ended),
+	})
+}
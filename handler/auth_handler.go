@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"parking-lot/domain"
+)
+
+type AuthHandler struct {
+	authService domain.AuthService
+}
+
+func NewAuthHandler(authService domain.AuthService) *AuthHandler {
+	return &AuthHandler{
+		authService: authService,
+	}
+}
+
+func (h *AuthHandler) CreateAPIKey(c echo.Context) error {
+	var req domain.APIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return domain.NewInvalidRequestError(domain.CodeInvalidRequest, "Invalid request format")
+	}
+
+	apiKey, key, err := h.authService.CreateAPIKey(c.Request().Context(), req.Name, req.Role)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, domain.APIKeyResponse{
+		Success: true,
+		Message: "API key created, store it now as it cannot be shown again",
+		APIKey:  apiKey,
+		Key:     key,
+	})
+}
+
+func (h *AuthHandler) GetAPIKeys(c echo.Context) error {
+	keys, err := h.authService.GetAPIKeys(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	if keys == nil {
+		keys = []domain.APIKey{}
+	}
+
+	return c.JSON(http.StatusOK, domain.APIKeysResponse{
+		Success: true,
+		Message: "API keys retrieved successfully",
+		APIKeys: keys,
+	})
+}
+
+func (h *AuthHandler) RevokeAPIKey(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return domain.NewInvalidRequestError(domain.CodeInvalidRequest, "Invalid API key ID")
+	}
+
+	err = h.authService.RevokeAPIKey(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, domain.APIKeyResponse{
+		Success: true,
+		Message: "API key revoked successfully",
+	})
+}
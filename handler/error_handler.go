@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"parking-lot/domain"
+)
+
+var statusByKind = map[domain.ErrorKind]int{
+	domain.KindInternal:          http.StatusInternalServerError,
+	domain.KindInvalidRequest:    http.StatusBadRequest,
+	domain.KindValidation:        http.StatusUnprocessableEntity,
+	domain.KindNotFound:          http.StatusNotFound,
+	domain.KindConflict:          http.StatusConflict,
+	domain.KindCapacityExhausted: http.StatusServiceUnavailable,
+	domain.KindTimeout:           http.StatusServiceUnavailable,
+}
+
+// ErrorHandler writes every error returned by a handler or middleware as a domain.ErrorResponse.
+// Domain errors keep their code, echo errors get a code derived from their status and any other
+// error is an internal error whose details are only logged.
+func ErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	status, response := errorResponse(err)
+	if response.ErrorCode == domain.CodeInternal {
+		log.Printf("Error handling %s %s: %v", c.Request().Method, c.Request().URL.Path, err)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(status)
+	} else {
+		err = c.JSON(status, response)
+	}
+	if err != nil {
+		log.Printf("Failed to write error response: %v", err)
+	}
+}
+
+func errorResponse(err error) (int, domain.ErrorResponse) {
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) {
+		message := err.Error()
+		if domainErr.Kind == domain.KindInternal {
+			message = "Internal server error"
+		}
+		return statusByKind[domainErr.Kind], domain.ErrorResponse{
+			Message:   message,
+			ErrorCode: domainErr.Code,
+		}
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code, domain.ErrorResponse{
+			Message:   fmt.Sprint(httpErr.Message),
+			ErrorCode: statusCode(httpErr.Code),
+		}
+	}
+
+	return http.StatusInternalServerError, domain.ErrorResponse{
+		Message:   "Internal server error",
+		ErrorCode: domain.CodeInternal,
+	}
+}
+
+// statusCode turns an HTTP status into an error code, e.g. 404 into not_found
+func statusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return domain.CodeInternal
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
@@ -0,0 +1,503 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+	"parking-lot/config"
+	"parking-lot/domain"
+)
+
+const (
+	maxGateMessageSize     = 64 << 10
+	maxGateSessionLength   = 100
+	maxPendingGateCommands = 32
+)
+
+// GateSocketHandler serves gates over a WebSocket with the protocol of domain.GateMessage. The sessions
+// are kept in memory, so a gate that reconnects to another instance only gets its unacknowledged results
+// again, which are stored like idempotent requests, and not its unacknowledged commands.
+type GateSocketHandler struct {
+	parkingService     domain.ParkingService
+	authService        domain.AuthService
+	idempotencyService domain.IdempotencyService
+	heartbeatInterval  time.Duration
+	requestTimeout     time.Duration
+	allowedOrigins     []string
+
+	mutex    sync.Mutex
+	sessions map[int64]*gateSession
+	conns    map[*gateConn]struct{}
+	closed   bool
+	// served counts the connections being served, for Close to wait on
+	served sync.WaitGroup
+}
+
+func NewGateSocketHandler(
+	parkingService domain.ParkingService,
+	authService domain.AuthService,
+	idempotencyService domain.IdempotencyService,
+	heartbeatInterval time.Duration,
+	requestTimeout time.Duration,
+	allowedOrigins []string,
+) *GateSocketHandler {
+	return &GateSocketHandler{
+		parkingService:     parkingService,
+		authService:        authService,
+		idempotencyService: idempotencyService,
+		heartbeatInterval:  heartbeatInterval,
+		requestTimeout:     requestTimeout,
+		allowedOrigins:     allowedOrigins,
+		sessions:           map[int64]*gateSession{},
+		conns:              map[*gateConn]struct{}{},
+	}
+}
+
+// gateConn is a gate connection, writes are serialized as the heartbeat writes concurrently
+type gateConn struct {
+	ws           *websocket.Conn
+	writeMutex   sync.Mutex
+	writeTimeout time.Duration
+}
+
+func (c *gateConn) send(msg domain.GateMessage) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	c.ws.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	return websocket.JSON.Send(c.ws, msg)
+}
+
+// gateSession is the state of a gate kept across its reconnects: the seq of the last command sent to it,
+// and the commands it did not acknowledge yet
+type gateSession struct {
+	mutex   sync.Mutex
+	id      string
+	lastSeq int64
+	pending []domain.GateMessage
+	conn    *gateConn
+}
+
+// attach makes conn the connection of the session and sends it the hello, followed by the commands after
+// lastAck. A new session ID starts the sequence anew, commands of the old session are dropped.
+func (s *gateSession) attach(conn *gateConn, sessionID string, lastAck int64, hello domain.GateMessage) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.conn != nil {
+		// the gate reconnected, its old connection is dead or about to be
+		s.conn.ws.Close()
+	}
+	if s.id != sessionID {
+		s.id = sessionID
+		s.lastSeq = 0
+		s.pending = nil
+	}
+	s.conn = conn
+	s.acknowledgeLocked(lastAck)
+
+	err := conn.send(hello)
+	if err != nil {
+		return err
+	}
+	for _, msg := range s.pending {
+		err = conn.send(msg)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *gateSession) detach(conn *gateConn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.conn == conn {
+		s.conn = nil
+	}
+}
+
+// acknowledge drops the commands up to seq, the gate received them
+func (s *gateSession) acknowledge(seq int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.acknowledgeLocked(seq)
+}
+
+func (s *gateSession) acknowledgeLocked(seq int64) {
+	s.pending = slices.DeleteFunc(s.pending, func(msg domain.GateMessage) bool {
+		return msg.Seq <= seq
+	})
+}
+
+// sendCommand numbers the command and sends it, it is sent again on every reconnect until acknowledged
+func (s *gateSession) sendCommand(msg domain.GateMessage) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lastSeq++
+	msg.Seq = s.lastSeq
+	s.pending = append(s.pending, msg)
+	if len(s.pending) > maxPendingGateCommands {
+		log.Printf("Dropped %s %d for session %s, the gate does not acknowledge commands\n", s.pending[0].Type, s.pending[0].Seq, s.id)
+		s.pending = s.pending[1:]
+	}
+
+	if s.conn == nil {
+		return
+	}
+	err := s.conn.send(msg)
+	if err != nil {
+		// the read loop ends on the closed connection, the command is sent again after a reconnect
+		s.conn.ws.Close()
+	}
+}
+
+// Serve upgrades the request to a gate socket
+func (h *GateSocketHandler) Serve(c echo.Context) error {
+	server := websocket.Server{
+		Handshake: h.checkOrigin,
+		Handler: func(ws *websocket.Conn) {
+			h.serve(c.Request().Context(), ws)
+		},
+	}
+	server.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
+
+// checkOrigin lets gates connect, which send no origin or their own host, and browsers from the CORS origins
+func (h *GateSocketHandler) checkOrigin(_ *websocket.Config, req *http.Request) error {
+	origin := req.Header.Get(echo.HeaderOrigin)
+	if origin == "" || slices.Contains(h.allowedOrigins, origin) || slices.Contains(h.allowedOrigins, "*") {
+		return nil
+	}
+	originURL, err := url.Parse(origin)
+	if err == nil && originURL.Host == req.Host {
+		return nil
+	}
+	return fmt.Errorf("origin %s is not allowed", origin)
+}
+
+// gateClient is an authenticated gate connection with the session it attached to
+type gateClient struct {
+	conn      *gateConn
+	session   *gateSession
+	sessionID string
+	gateID    int64
+	apiKey    *domain.APIKey
+}
+
+func (h *GateSocketHandler) serve(ctx context.Context, ws *websocket.Conn) {
+	ws.MaxPayloadBytes = maxGateMessageSize
+	conn := &gateConn{
+		ws:           ws,
+		writeTimeout: h.heartbeatInterval,
+	}
+	if !h.track(conn) {
+		return
+	}
+	defer h.untrack(conn)
+
+	client, err := h.hello(ctx, conn)
+	if err != nil {
+		conn.send(errorMessage(0, err))
+		return
+	}
+	defer client.session.detach(conn)
+
+	done := make(chan struct{})
+	defer close(done)
+	go h.heartbeat(conn, done)
+
+	for {
+		// the heartbeat makes the gate answer at least once per interval
+		ws.SetReadDeadline(time.Now().Add(2 * h.heartbeatInterval))
+
+		var msg domain.GateMessage
+		err := websocket.JSON.Receive(ws, &msg)
+		if err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				conn.send(errorMessage(0, domain.NewInvalidRequestError(domain.CodeInvalidRequest, "Invalid message format")))
+				continue
+			}
+			// closed, timed out or too large
+			return
+		}
+
+		switch msg.Type {
+		case domain.GateAck:
+			client.session.acknowledge(msg.Ack)
+		case domain.GatePing:
+			conn.send(domain.GateMessage{Type: domain.GatePong})
+		case domain.GatePong:
+			// every message extends the read deadline
+		case domain.GatePark, domain.GateUnpark:
+			h.handleRequest(ctx, client, msg)
+		default:
+			conn.send(errorMessage(msg.Seq, domain.NewValidationError(domain.CodeInvalidGateMessage, "Unexpected message type %q", msg.Type)))
+		}
+	}
+}
+
+// hello reads the hello of the gate, authenticates it and attaches the connection to the gate's session
+func (h *GateSocketHandler) hello(ctx context.Context, conn *gateConn) (*gateClient, error) {
+	conn.ws.SetReadDeadline(time.Now().Add(h.heartbeatInterval))
+
+	var msg domain.GateMessage
+	err := websocket.JSON.Receive(conn.ws, &msg)
+	if err != nil || msg.Type != domain.GateHello {
+		return nil, domain.NewValidationError(domain.CodeInvalidGateMessage, "Expected a hello message first")
+	}
+
+	ctx, cancel := h.withTimeout(ctx)
+	defer cancel()
+
+	apiKey, err := h.authService.Authenticate(ctx, msg.APIKey)
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == nil {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "Invalid or revoked API key")
+	}
+	if !slices.Contains([]domain.Role{domain.RoleAdmin, domain.RoleGate, domain.RoleAttendant}, apiKey.Role) {
+		return nil, echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("The %s role may not call this endpoint", apiKey.Role))
+	}
+
+	isGate := func(gate domain.Gate) bool { return gate.ID == msg.GateID }
+	if !slices.ContainsFunc(config.GetAppConfig().Parking.Gates, isGate) {
+		return nil, domain.NewNotFoundError(domain.CodeGateNotFound, "Gate %d not found", msg.GateID)
+	}
+	if msg.Session == "" || len(msg.Session) > maxGateSessionLength {
+		return nil, domain.NewValidationError(domain.CodeInvalidGateMessage, "Session is required and must be at most %d characters", maxGateSessionLength)
+	}
+
+	session := h.session(msg.GateID)
+	err = session.attach(conn, msg.Session, msg.LastAck, domain.GateMessage{
+		Type:             domain.GateHello,
+		GateID:           msg.GateID,
+		Session:          msg.Session,
+		HeartbeatSeconds: int(h.heartbeatInterval / time.Second),
+	})
+	if err != nil {
+		session.detach(conn)
+		return nil, err
+	}
+
+	return &gateClient{
+		conn:      conn,
+		session:   session,
+		sessionID: msg.Session,
+		gateID:    msg.GateID,
+		apiKey:    apiKey,
+	}, nil
+}
+
+// handleRequest parks or unparks at the client's gate and acks the message with the result. A message that
+// was handled before, because the gate did not get the ack, is acked with the stored result instead.
+func (h *GateSocketHandler) handleRequest(ctx context.Context, client *gateClient, msg domain.GateMessage) {
+	var payload any
+	switch {
+	case msg.Seq < 1:
+		client.conn.send(errorMessage(0, domain.NewValidationError(domain.CodeInvalidGateMessage, "Seq is required for %s", msg.Type)))
+		return
+	case msg.Type == domain.GatePark && msg.Park != nil:
+		msg.Park.GateID = client.gateID
+		payload = msg.Park
+	case msg.Type == domain.GateUnpark && msg.Unpark != nil:
+		msg.Unpark.GateID = client.gateID
+		payload = msg.Unpark
+	default:
+		client.conn.send(errorMessage(msg.Seq, domain.NewValidationError(domain.CodeInvalidGateMessage, "%s is required for a %s message", msg.Type, msg.Type)))
+		return
+	}
+
+	ctx, cancel := h.withTimeout(ctx)
+	defer cancel()
+
+	// the seq identifies the message within the session, like an idempotency key
+	key := fmt.Sprintf("gate-socket:%d:%s:%d", client.gateID, client.sessionID, msg.Seq)
+	body, _ := json.Marshal(payload)
+	hash := sha256.Sum256(append([]byte(string(msg.Type)+"\n"), body...))
+
+	stored, err := h.idempotencyService.StartRequest(ctx, client.apiKey.ID, key, hex.EncodeToString(hash[:]))
+	if err != nil {
+		_, response := errorResponse(err)
+		client.conn.send(ackMessage(msg.Seq, response))
+		return
+	}
+	if stored != nil {
+		replay := ackMessage(msg.Seq, json.RawMessage(stored.ResponseBody))
+		replay.Replayed = true
+		client.conn.send(replay)
+		return
+	}
+
+	status, result, commands := h.handlePayload(ctx, msg)
+
+	resultBody, _ := json.Marshal(result)
+	// the gate may be gone and the deadline passed, the outcome must be stored regardless
+	err = h.idempotencyService.FinishRequest(context.WithoutCancel(ctx), client.apiKey.ID, key, status, resultBody)
+	if err != nil {
+		log.Printf("Failed to finish gate socket request: %v", err)
+	}
+
+	client.conn.send(ackMessage(msg.Seq, json.RawMessage(resultBody)))
+	for _, command := range commands {
+		client.session.sendCommand(command)
+	}
+}
+
+// handlePayload parks or unparks and returns the status and response an HTTP request would get, with the
+// commands for the gate: what to display, and to open the barrier on success
+func (h *GateSocketHandler) handlePayload(ctx context.Context, msg domain.GateMessage) (int, any, []domain.GateMessage) {
+	var (
+		result any
+		text   string
+		err    error
+	)
+	if msg.Type == domain.GatePark {
+		var (
+			spot   *domain.ParkingSpot
+			ticket *domain.Ticket
+		)
+		spot, ticket, err = parkVehicle(ctx, h.parkingService, *msg.Park)
+		if err == nil {
+			result = domain.ParkResponse{Success: true, Message: "Vehicle parked successfully", ParkingSpot: spot, Ticket: ticket}
+			text = fmt.Sprintf("Please park on spot %d-%d-%d", spot.Floor, spot.Row, spot.Column)
+		}
+	} else {
+		var fee *domain.ParkingFee
+		fee, err = unparkVehicle(ctx, h.parkingService, *msg.Unpark)
+		if err == nil {
+			result = domain.UnparkResponse{Success: true, Message: "Vehicle unparked successfully", Fee: fee}
+			text = fmt.Sprintf("Parking fee %d, goodbye", fee.Amount)
+		}
+	}
+
+	if err != nil {
+		status, response := errorResponse(err)
+		if response.ErrorCode == domain.CodeInternal {
+			log.Printf("Error handling gate socket %s: %v", msg.Type, err)
+		}
+		return status, response, []domain.GateMessage{{Type: domain.GateDisplayMessage, Text: response.Message}}
+	}
+
+	return http.StatusOK, result, []domain.GateMessage{
+		{Type: domain.GateDisplayMessage, Text: text},
+		{Type: domain.GateOpenBarrier},
+	}
+}
+
+// heartbeat pings the gate every interval until done is closed
+func (h *GateSocketHandler) heartbeat(conn *gateConn, done <-chan struct{}) {
+	ticker := time.NewTicker(h.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		err := conn.send(domain.GateMessage{Type: domain.GatePing})
+		if err != nil {
+			conn.ws.Close()
+			return
+		}
+	}
+}
+
+// session returns the session of the gate, creating it on first use
+func (h *GateSocketHandler) session(gateID int64) *gateSession {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	session, ok := h.sessions[gateID]
+	if !ok {
+		session = &gateSession{}
+		h.sessions[gateID] = session
+	}
+	return session
+}
+
+// track registers the connection for Close, it reports false once the handler is closed
+func (h *GateSocketHandler) track(conn *gateConn) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.closed {
+		return false
+	}
+	h.conns[conn] = struct{}{}
+	h.served.Add(1)
+	return true
+}
+
+func (h *GateSocketHandler) untrack(conn *gateConn) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delete(h.conns, conn)
+	h.served.Done()
+}
+
+// Close disconnects every gate and waits for the messages being handled, gates reconnect to another instance
+func (h *GateSocketHandler) Close() {
+	h.mutex.Lock()
+	h.closed = true
+	for conn := range h.conns {
+		conn.ws.Close()
+	}
+	h.mutex.Unlock()
+
+	h.served.Wait()
+}
+
+// withTimeout bounds the database work of a message like RequestTimeout bounds a request
+func (h *GateSocketHandler) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if h.requestTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, h.requestTimeout)
+}
+
+func ackMessage(seq int64, result any) domain.GateMessage {
+	body, _ := json.Marshal(result)
+	return domain.GateMessage{
+		Type:   domain.GateAck,
+		Ack:    seq,
+		Result: body,
+	}
+}
+
+// errorMessage reports err like the error handler, seq is the message that caused it if known
+func errorMessage(seq int64, err error) domain.GateMessage {
+	_, response := errorResponse(err)
+	if response.ErrorCode == domain.CodeInternal {
+		log.Printf("Error handling gate socket message: %v", err)
+	}
+
+	body, _ := json.Marshal(response)
+	return domain.GateMessage{
+		Type:   domain.GateError,
+		Ack:    seq,
+		Result: body,
+	}
+}
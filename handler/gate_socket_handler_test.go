@@ -0,0 +1,362 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+	"parking-lot/config"
+	"parking-lot/domain"
+	"parking-lot/repository"
+	"parking-lot/service"
+)
+
+func TestGateSocketHello(t *testing.T) {
+	tests := []struct {
+		name string
+		// role is the role of the key sent in the hello, revoked a revoked gate key and empty an unknown key
+		role     domain.Role
+		revoked  bool
+		hello    domain.GateMessage
+		wantCode string
+	}{
+		{
+			name:  "opens a session of a gate key",
+			role:  domain.RoleGate,
+			hello: domain.GateMessage{Type: domain.GateHello, GateID: 1, Session: "boot-1"},
+		},
+		{
+			name:  "opens a session of an attendant key",
+			role:  domain.RoleAttendant,
+			hello: domain.GateMessage{Type: domain.GateHello, GateID: 1, Session: "boot-1"},
+		},
+		{
+			name:     "rejects a first message that is not a hello",
+			role:     domain.RoleGate,
+			hello:    domain.GateMessage{Type: domain.GatePark, Seq: 1, GateID: 1, Session: "boot-1"},
+			wantCode: domain.CodeInvalidGateMessage,
+		},
+		{
+			name:     "rejects an unknown key",
+			hello:    domain.GateMessage{Type: domain.GateHello, GateID: 1, Session: "boot-1"},
+			wantCode: "unauthorized",
+		},
+		{
+			name:     "rejects a revoked key",
+			role:     domain.RoleGate,
+			revoked:  true,
+			hello:    domain.GateMessage{Type: domain.GateHello, GateID: 1, Session: "boot-1"},
+			wantCode: "unauthorized",
+		},
+		{
+			name:     "rejects a reporting key",
+			role:     domain.RoleReporting,
+			hello:    domain.GateMessage{Type: domain.GateHello, GateID: 1, Session: "boot-1"},
+			wantCode: "forbidden",
+		},
+		{
+			name:     "rejects an unknown gate",
+			role:     domain.RoleGate,
+			hello:    domain.GateMessage{Type: domain.GateHello, GateID: 9, Session: "boot-1"},
+			wantCode: domain.CodeGateNotFound,
+		},
+		{
+			name:     "rejects a hello without a session",
+			role:     domain.RoleGate,
+			hello:    domain.GateMessage{Type: domain.GateHello, GateID: 1},
+			wantCode: domain.CodeInvalidGateMessage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newGateSocketServer(t, time.Minute)
+			tt.hello.APIKey = "pl_unknown"
+			if tt.role != "" {
+				tt.hello.APIKey = server.apiKey(t, tt.role, tt.revoked)
+			}
+
+			gate := server.dial(t)
+			gate.send(tt.hello)
+			reply := gate.receive()
+
+			if tt.wantCode != "" {
+				assertGateError(t, reply, 0, tt.wantCode)
+				gate.assertClosed()
+				return
+			}
+			if reply.Type != domain.GateHello || reply.GateID != tt.hello.GateID || reply.Session != tt.hello.Session || reply.HeartbeatSeconds != 60 {
+				t.Errorf("got reply %+v, want the hello of the session with a heartbeat of 60 seconds", reply)
+			}
+		})
+	}
+}
+
+func TestGateSocketRequests(t *testing.T) {
+	server := newGateSocketServer(t, time.Minute)
+	gate := server.dial(t)
+	gate.hello(server.apiKey(t, domain.RoleGate, false), "boot-1", 0)
+
+	park := domain.GateMessage{Type: domain.GatePark, Seq: 1, Park: &domain.ParkRequest{LicensePlate: "AB123", VehicleType: domain.Car}}
+	gate.send(park)
+	ack := gate.receive()
+	if ack.Type != domain.GateAck || ack.Ack != 1 || ack.Replayed {
+		t.Fatalf("got %+v, want the ack of seq 1", ack)
+	}
+	var parked domain.ParkResponse
+	err := json.Unmarshal(ack.Result, &parked)
+	if err != nil || !parked.Success || parked.Ticket == nil {
+		t.Fatalf("got result %s, want the vehicle parked", ack.Result)
+	}
+	gate.receiveCommand(domain.GateDisplayMessage, 1)
+	gate.receiveCommand(domain.GateOpenBarrier, 2)
+
+	// a duplicate seq is acked with the stored result, and sends no commands
+	gate.send(park)
+	replay := gate.receive()
+	if replay.Type != domain.GateAck || replay.Ack != 1 || !replay.Replayed || string(replay.Result) != string(ack.Result) {
+		t.Fatalf("got %+v, want the replayed ack of seq 1", replay)
+	}
+	gate.send(domain.GateMessage{Type: domain.GatePing})
+	if pong := gate.receive(); pong.Type != domain.GatePong {
+		t.Fatalf("got %+v, want a pong", pong)
+	}
+
+	// a duplicate seq of another message is rejected
+	gate.send(domain.GateMessage{Type: domain.GatePark, Seq: 1, Park: &domain.ParkRequest{LicensePlate: "CD456", VehicleType: domain.Car}})
+	assertGateResult(t, gate.receive(), 1, domain.CodeIdempotencyKeyReused)
+
+	// seqs out of order are handled as they come: seq 3 unparks the vehicle, seq 2 finds it gone
+	gate.send(domain.GateMessage{Type: domain.GateUnpark, Seq: 3, Unpark: &domain.UnparkRequest{LicensePlate: "AB123"}})
+	assertGateResult(t, gate.receive(), 3, "")
+	gate.receiveCommand(domain.GateDisplayMessage, 3)
+	gate.receiveCommand(domain.GateOpenBarrier, 4)
+	gate.send(domain.GateMessage{Type: domain.GateUnpark, Seq: 2, Unpark: &domain.UnparkRequest{LicensePlate: "AB123"}})
+	assertGateResult(t, gate.receive(), 2, domain.CodeVehicleNotParked)
+	gate.receiveCommand(domain.GateDisplayMessage, 5)
+
+	// messages without a seq or of an unknown type are errors, the connection stays open
+	gate.send(domain.GateMessage{Type: domain.GatePark, Park: &domain.ParkRequest{LicensePlate: "CD456", VehicleType: domain.Car}})
+	assertGateError(t, gate.receive(), 0, domain.CodeInvalidGateMessage)
+	gate.send(domain.GateMessage{Type: domain.GateOpenBarrier, Seq: 9})
+	assertGateError(t, gate.receive(), 9, domain.CodeInvalidGateMessage)
+
+	// a reconnect replaces the old connection and gets the commands after its last ack again
+	gate.send(domain.GateMessage{Type: domain.GateAck, Ack: 3})
+	reconnected := server.dial(t)
+	reconnected.hello(server.apiKey(t, domain.RoleGate, false), "boot-1", 4)
+	reconnected.receiveCommand(domain.GateDisplayMessage, 5)
+	gate.assertClosed()
+
+	// a new session drops the commands of the old one
+	restarted := server.dial(t)
+	restarted.hello(server.apiKey(t, domain.RoleGate, false), "boot-2", 0)
+	restarted.send(domain.GateMessage{Type: domain.GatePing})
+	if pong := restarted.receive(); pong.Type != domain.GatePong {
+		t.Errorf("got %+v, want a pong and no commands of session boot-1", pong)
+	}
+}
+
+func TestGateSocketHeartbeat(t *testing.T) {
+	const heartbeat = 100 * time.Millisecond
+
+	t.Run("keeps a gate answering the pings", func(t *testing.T) {
+		server := newGateSocketServer(t, heartbeat)
+		gate := server.dial(t)
+		gate.hello(server.apiKey(t, domain.RoleGate, false), "boot-1", 0)
+
+		for range 5 {
+			ping := gate.receive()
+			if ping.Type != domain.GatePing {
+				t.Fatalf("got %+v, want a ping", ping)
+			}
+			gate.send(domain.GateMessage{Type: domain.GatePong})
+		}
+	})
+
+	t.Run("disconnects a silent gate", func(t *testing.T) {
+		server := newGateSocketServer(t, heartbeat)
+		gate := server.dial(t)
+		gate.hello(server.apiKey(t, domain.RoleGate, false), "boot-1", 0)
+		start := time.Now()
+
+		// pings until the server gives up on the gate after two intervals, or the deadline fails the test
+		gate.ws.SetReadDeadline(start.Add(20 * heartbeat))
+		for {
+			var msg domain.GateMessage
+			err := websocket.JSON.Receive(gate.ws, &msg)
+			if err != nil {
+				break
+			}
+			if msg.Type != domain.GatePing {
+				t.Fatalf("got %+v, want a ping", msg)
+			}
+		}
+
+		if elapsed := time.Since(start); elapsed < 2*heartbeat || elapsed > 10*heartbeat {
+			t.Errorf("got disconnected after %v, want after about %v", elapsed, 2*heartbeat)
+		}
+	})
+}
+
+// gateSocketServer serves the gate socket with a memory storage of two car spots
+type gateSocketServer struct {
+	url         string
+	authService domain.AuthService
+}
+
+func newGateSocketServer(t *testing.T, heartbeatInterval time.Duration) *gateSocketServer {
+	t.Helper()
+
+	parkingRepo := repository.NewMemoryParkingRepository()
+	for column := 1; column <= 2; column++ {
+		err := parkingRepo.CreateSpot(context.Background(), &domain.ParkingSpot{
+			Floor:       1,
+			Row:         1,
+			Column:      column,
+			VehicleType: domain.Car,
+			SizeClass:   config.GetAppConfig().VehicleTypes.DefaultSizeClass(domain.Car),
+			IsActive:    true,
+		})
+		if err != nil {
+			t.Fatalf("error creating spot: %v", err)
+		}
+	}
+	parkingService := service.NewParkingService(
+		parkingRepo,
+		repository.NewMemoryVehicleRepository(parkingRepo),
+		repository.NewMemoryReservationRepository(parkingRepo),
+		service.NewSpotAllocator(domain.AllocationFirstFit),
+		repository.NewMemoryTransactor(),
+		service.NewWebhookService(repository.NewMemoryWebhookRepository()),
+		closedEventStream{},
+	)
+	authService := service.NewAuthService(repository.NewMemoryAPIKeyRepository(), "")
+	idempotencyService := service.NewIdempotencyService(repository.NewMemoryIdempotencyRepository(), time.Hour, time.Second)
+
+	gateSocketHandler := NewGateSocketHandler(parkingService, authService, idempotencyService, heartbeatInterval, time.Second, nil)
+	e := echo.New()
+	e.GET("/gates/ws", gateSocketHandler.Serve)
+	httpServer := httptest.NewServer(e)
+	t.Cleanup(func() {
+		gateSocketHandler.Close()
+		httpServer.Close()
+	})
+
+	return &gateSocketServer{
+		url:         httpServer.URL,
+		authService: authService,
+	}
+}
+
+// apiKey creates a key of the role, revoked if asked to
+func (s *gateSocketServer) apiKey(t *testing.T, role domain.Role, revoked bool) string {
+	t.Helper()
+
+	ctx := context.Background()
+	apiKey, key, err := s.authService.CreateAPIKey(ctx, string(role), role)
+	if err != nil {
+		t.Fatalf("CreateAPIKey() error = %v", err)
+	}
+	if revoked {
+		err = s.authService.RevokeAPIKey(ctx, apiKey.ID)
+		if err != nil {
+			t.Fatalf("RevokeAPIKey() error = %v", err)
+		}
+	}
+	return key
+}
+
+func (s *gateSocketServer) dial(t *testing.T) *testGate {
+	t.Helper()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(s.url, "http")+"/gates/ws", "", s.url)
+	if err != nil {
+		t.Fatalf("error connecting to the gate socket: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return &testGate{t: t, ws: ws}
+}
+
+// testGate is the gate end of a gate socket, failing the test on unexpected messages
+type testGate struct {
+	t  *testing.T
+	ws *websocket.Conn
+}
+
+func (g *testGate) send(msg domain.GateMessage) {
+	g.t.Helper()
+
+	err := websocket.JSON.Send(g.ws, msg)
+	if err != nil {
+		g.t.Fatalf("error sending %s: %v", msg.Type, err)
+	}
+}
+
+func (g *testGate) receive() domain.GateMessage {
+	g.t.Helper()
+
+	g.ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg domain.GateMessage
+	err := websocket.JSON.Receive(g.ws, &msg)
+	if err != nil {
+		g.t.Fatalf("error receiving a message: %v", err)
+	}
+	return msg
+}
+
+// hello opens the session with the key of a gate at gate 1
+func (g *testGate) hello(apiKey, session string, lastAck int64) {
+	g.t.Helper()
+
+	g.send(domain.GateMessage{Type: domain.GateHello, APIKey: apiKey, GateID: 1, Session: session, LastAck: lastAck})
+	if reply := g.receive(); reply.Type != domain.GateHello {
+		g.t.Fatalf("got %+v, want a hello", reply)
+	}
+}
+
+func (g *testGate) receiveCommand(commandType domain.GateMessageType, seq int64) {
+	g.t.Helper()
+
+	if msg := g.receive(); msg.Type != commandType || msg.Seq != seq {
+		g.t.Fatalf("got %+v, want %s with seq %d", msg, commandType, seq)
+	}
+}
+
+// assertClosed checks the server closed the connection
+func (g *testGate) assertClosed() {
+	g.t.Helper()
+
+	g.ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg domain.GateMessage
+	err := websocket.JSON.Receive(g.ws, &msg)
+	if err == nil {
+		g.t.Errorf("got %+v, want the connection closed", msg)
+	}
+}
+
+// assertGateResult checks msg acks seq with a result of the error code, or a successful result if code is empty
+func assertGateResult(t *testing.T, msg domain.GateMessage, seq int64, code string) {
+	t.Helper()
+
+	var response domain.ErrorResponse
+	err := json.Unmarshal(msg.Result, &response)
+	if msg.Type != domain.GateAck || msg.Ack != seq || msg.Replayed || err != nil || response.ErrorCode != code {
+		t.Fatalf("got %+v with result %s, want the ack of seq %d with %q", msg, msg.Result, seq, code)
+	}
+}
+
+func assertGateError(t *testing.T, msg domain.GateMessage, seq int64, code string) {
+	t.Helper()
+
+	var response domain.ErrorResponse
+	err := json.Unmarshal(msg.Result, &response)
+	if msg.Type != domain.GateError || msg.Ack != seq || err != nil || response.ErrorCode != code {
+		t.Errorf("got %+v with result %s, want an error of seq %d with %s", msg, msg.Result, seq, code)
+	}
+}
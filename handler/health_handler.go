@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"parking-lot/domain"
+)
+
+type HealthHandler struct {
+	healthService domain.HealthService
+}
+
+func NewHealthHandler(healthService domain.HealthService) *HealthHandler {
+	return &HealthHandler{
+		healthService: healthService,
+	}
+}
+
+// Healthz reports that the process is alive and serving requests
+func (h *HealthHandler) Healthz(c echo.Context) error {
+	return c.JSON(http.StatusOK, domain.HealthResponse{
+		Success: true,
+		Message: "OK",
+	})
+}
+
+// Readyz reports whether the application can serve traffic, with 503 while any readiness check fails
+func (h *HealthHandler) Readyz(c echo.Context) error {
+	ready, checks := h.healthService.CheckReadiness(c.Request().Context())
+	if !ready {
+		return c.JSON(http.StatusServiceUnavailable, domain.HealthResponse{
+			Success:   false,
+			Message:   "Not ready",
+			ErrorCode: domain.CodeNotReady,
+			Checks:    checks,
+		})
+	}
+
+	return c.JSON(http.StatusOK, domain.HealthResponse{
+		Success: true,
+		Message: "Ready",
+		Checks:  checks,
+	})
+}
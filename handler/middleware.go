@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"parking-lot/domain"
+	"parking-lot/metrics"
+)
+
+// apiKeyContextKey holds the authenticated *domain.APIKey of a request
+const apiKeyContextKey = "api_key"
+
+// Authenticate requires an API key as a bearer token and stores it on the context for RequireRole
+func Authenticate(authService domain.AuthService) echo.MiddlewareFunc {
+	return middleware.KeyAuthWithConfig(middleware.KeyAuthConfig{
+		Validator: func(key string, c echo.Context) (bool, error) {
+			apiKey, err := authService.Authenticate(c.Request().Context(), key)
+			if err != nil {
+				return false, err
+			}
+			if apiKey == nil {
+				return false, echo.NewHTTPError(http.StatusUnauthorized, "Invalid or revoked API key")
+			}
+
+			c.Set(apiKeyContextKey, apiKey)
+			return true, nil
+		},
+		ErrorHandler: func(err error, c echo.Context) error {
+			var missing *middleware.ErrKeyAuthMissing
+			if errors.As(err, &missing) {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Missing API key, send it as a bearer token")
+			}
+			return err
+		},
+	})
+}
+
+// accessTokenParam carries the API key of an event stream opened by a browser, whose EventSource cannot set headers
+const accessTokenParam = "access_token"
+
+// AuthenticateStream is Authenticate for the event stream, which also accepts the API key in the access_token
+// query parameter. Keys in URLs end up in access logs and browser history, so only reporting keys, which may
+// not change anything, are accepted there.
+func AuthenticateStream(authService domain.AuthService) echo.MiddlewareFunc {
+	bearer := Authenticate(authService)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withBearer := bearer(next)
+		return func(c echo.Context) error {
+			key := c.QueryParam(accessTokenParam)
+			if key == "" || c.Request().Header.Get(echo.HeaderAuthorization) != "" {
+				return withBearer(c)
+			}
+
+			apiKey, err := authService.Authenticate(c.Request().Context(), key)
+			if err != nil {
+				return err
+			}
+			if apiKey == nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or revoked API key")
+			}
+			if apiKey.Role != domain.RoleReporting {
+				return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("The %s role must send its key as a bearer token, %s only takes %s keys", apiKey.Role, accessTokenParam, domain.RoleReporting))
+			}
+
+			c.Set(apiKeyContextKey, apiKey)
+			return next(c)
+		}
+	}
+}
+
+// RequireRole lets requests through whose API key has one of the roles, admin keys are always let through.
+// It must run after Authenticate.
+func RequireRole(roles ...domain.Role) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			apiKey := APIKey(c)
+			if apiKey == nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Missing API key, send it as a bearer token")
+			}
+			if apiKey.Role == domain.RoleAdmin || slices.Contains(roles, apiKey.Role) {
+				return next(c)
+			}
+			return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("The %s role may not call this endpoint", apiKey.Role))
+		}
+	}
+}
+
+// APIKey returns the API key that authenticated the request, or nil outside of Authenticate
+func APIKey(c echo.Context) *domain.APIKey {
+	apiKey, _ := c.Get(apiKeyContextKey).(*domain.APIKey)
+	return apiKey
+}
+
+// Metrics records the latency of every request by method, route and status code
+func Metrics() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+
+			err := next(c)
+			if err != nil {
+				// write the error response now to know its status code
+				c.Error(err)
+			}
+
+			// label by route pattern rather than the path, so IDs and plates do not create new series
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			status := strconv.Itoa(c.Response().Status)
+			metrics.RequestDuration.WithLabelValues(c.Request().Method, route, status).Observe(time.Since(start).Seconds())
+
+			return err
+		}
+	}
+}
+
+// RequestTimeout cancels the context of a request after timeout, aborting its database queries.
+// A timeout of 0 leaves requests without a deadline, as do the requests the skipper selects.
+func RequestTimeout(timeout time.Duration, skipper middleware.Skipper) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if timeout <= 0 || skipper(c) {
+				return next(c)
+			}
+
+			ctx, cancel := context.WithTimeout(c.Request().Context(), timeout)
+			defer cancel()
+
+			c.SetRequest(c.Request().WithContext(ctx))
+			err := next(c)
+			// drivers report a cancelled query in their own words, the deadline tells what happened
+			if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return domain.NewTimeoutError(domain.CodeRequestTimeout, "Request timed out after %s", timeout)
+			}
+			return err
+		}
+	}
+}
+
+const (
+	// HeaderIdempotencyKey carries the key a client reuses for every retry of a request
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed marks a response replayed for a retried request
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// Idempotency replays the stored response when a request is retried with the same Idempotency-Key header,
+// so a gate retrying after a timeout does not park or unpark twice. Keys are scoped to the API key of the
+// request and requests without the header are not affected. It must run after Authenticate.
+func Idempotency(idempotencyService domain.IdempotencyService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(HeaderIdempotencyKey)
+			if key == "" {
+				return next(c)
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				return domain.NewInvalidRequestError(domain.CodeInvalidRequest, "%s must be at most %d characters", HeaderIdempotencyKey, maxIdempotencyKeyLength)
+			}
+
+			var apiKeyID int64
+			if apiKey := APIKey(c); apiKey != nil {
+				apiKeyID = apiKey.ID
+			}
+
+			body, err := io.ReadAll(c.Request().Body)
+			if err != nil {
+				return domain.NewInvalidRequestError(domain.CodeInvalidRequest, "Invalid request format")
+			}
+			c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+			ctx := c.Request().Context()
+			stored, err := idempotencyService.StartRequest(ctx, apiKeyID, key, requestHash(c.Request(), body))
+			if err != nil {
+				return err
+			}
+			if stored != nil {
+				c.Response().Header().Set(HeaderIdempotentReplayed, "true")
+				return c.JSONBlob(stored.StatusCode, stored.ResponseBody)
+			}
+
+			recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+
+			// a panic answers with a server error as well, release the key so retries are not stuck behind it
+			defer func() {
+				if r := recover(); r != nil {
+					releaseErr := idempotencyService.FinishRequest(context.WithoutCancel(ctx), apiKeyID, key, http.StatusInternalServerError, nil)
+					if releaseErr != nil {
+						log.Printf("Failed to release idempotency key: %v", releaseErr)
+					}
+					panic(r)
+				}
+			}()
+
+			var (
+				status       int
+				responseBody []byte
+			)
+			err = next(c)
+			if err != nil {
+				// the error response is written by the error handler later, store what it will write
+				var response domain.ErrorResponse
+				status, response = errorResponse(err)
+				responseBody, _ = json.Marshal(response)
+			} else {
+				status, responseBody = c.Response().Status, recorder.body.Bytes()
+			}
+
+			// the request context may be past its deadline, the outcome must be stored regardless
+			finishErr := idempotencyService.FinishRequest(context.WithoutCancel(ctx), apiKeyID, key, status, responseBody)
+			if finishErr != nil {
+				log.Printf("Failed to finish idempotent request: %v", finishErr)
+			}
+
+			return err
+		}
+	}
+}
+
+// requestHash identifies a request by its method, path and body
+func requestHash(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the response body written through it
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
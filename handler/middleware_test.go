@@ -0,0 +1,334 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"parking-lot/domain"
+	"parking-lot/repository"
+	"parking-lot/service"
+)
+
+func TestRequireRole(t *testing.T) {
+	ctx := context.Background()
+	authService := service.NewAuthService(repository.NewMemoryAPIKeyRepository(), "admin-token")
+	keys := map[domain.Role]string{}
+	for _, role := range domain.Roles {
+		_, key, err := authService.CreateAPIKey(ctx, string(role), role)
+		if err != nil {
+			t.Fatalf("CreateAPIKey() error = %v", err)
+		}
+		keys[role] = key
+	}
+	revoked, revokedKey, err := authService.CreateAPIKey(ctx, "revoked", domain.RoleGate)
+	if err != nil {
+		t.Fatalf("CreateAPIKey() error = %v", err)
+	}
+	err = authService.RevokeAPIKey(ctx, revoked.ID)
+	if err != nil {
+		t.Fatalf("RevokeAPIKey() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		key        string
+		wantStatus int
+	}{
+		{
+			name:       "rejects a request without a key",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "rejects an unknown key",
+			key:        "pl_unknown",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "rejects a revoked key",
+			key:        revokedKey,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "accepts a key of an allowed role",
+			key:        keys[domain.RoleGate],
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "accepts a key of another allowed role",
+			key:        keys[domain.RoleAttendant],
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "rejects a key of a role that is not allowed",
+			key:        keys[domain.RoleReporting],
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "accepts an admin key for every endpoint",
+			key:        keys[domain.RoleAdmin],
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "accepts the admin token",
+			key:        "admin-token",
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.HTTPErrorHandler = ErrorHandler
+			api := e.Group("", Authenticate(authService))
+			api.POST("/park", func(c echo.Context) error {
+				return c.JSON(http.StatusOK, map[string]domain.Role{"role": APIKey(c).Role})
+			}, RequireRole(domain.RoleGate, domain.RoleAttendant))
+
+			req := httptest.NewRequest(http.MethodPost, "/park", nil)
+			if tt.key != "" {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+tt.key)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %d with %s, want %d", rec.Code, rec.Body.String(), tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRequireRoleWithoutAuthentication(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	e.POST("/park", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, RequireRole(domain.RoleGate))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/park", nil))
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestIdempotency(t *testing.T) {
+	tests := []struct {
+		name string
+		// fail makes the first call of the handler fail, with "error", "panic" or "status"
+		fail     string
+		requests []idempotentRequest
+		// wantCalls is how often the handler runs
+		wantCalls int32
+	}{
+		{
+			name: "replays the response to a retry",
+			requests: []idempotentRequest{
+				{key: "k1", body: `{"license_plate":"AB123"}`, wantStatus: http.StatusCreated},
+				{key: "k1", body: `{"license_plate":"AB123"}`, wantStatus: http.StatusCreated, wantReplayed: true},
+			},
+			wantCalls: 1,
+		},
+		{
+			name: "runs requests with different keys",
+			requests: []idempotentRequest{
+				{key: "k1", body: `{"license_plate":"AB123"}`, wantStatus: http.StatusCreated},
+				{key: "k2", body: `{"license_plate":"AB123"}`, wantStatus: http.StatusCreated},
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "runs requests without a key",
+			requests: []idempotentRequest{
+				{body: `{"license_plate":"AB123"}`, wantStatus: http.StatusCreated},
+				{body: `{"license_plate":"AB123"}`, wantStatus: http.StatusCreated},
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "rejects a key reused for a different body",
+			requests: []idempotentRequest{
+				{key: "k1", body: `{"license_plate":"AB123"}`, wantStatus: http.StatusCreated},
+				{key: "k1", body: `{"license_plate":"CD456"}`, wantStatus: http.StatusUnprocessableEntity, wantCode: domain.CodeIdempotencyKeyReused},
+			},
+			wantCalls: 1,
+		},
+		{
+			name: "replays a client error",
+			fail: "status",
+			requests: []idempotentRequest{
+				{key: "k1", body: `{"license_plate":"AB123"}`, wantStatus: http.StatusConflict, wantCode: domain.CodeVehicleAlreadyParked},
+				{key: "k1", body: `{"license_plate":"AB123"}`, wantStatus: http.StatusConflict, wantCode: domain.CodeVehicleAlreadyParked, wantReplayed: true},
+			},
+			wantCalls: 1,
+		},
+		{
+			name: "releases the key of a server error",
+			fail: "error",
+			requests: []idempotentRequest{
+				{key: "k1", body: `{"license_plate":"AB123"}`, wantStatus: http.StatusInternalServerError, wantCode: domain.CodeInternal},
+				{key: "k1", body: `{"license_plate":"AB123"}`, wantStatus: http.StatusCreated},
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "releases the key of a panic",
+			fail: "panic",
+			requests: []idempotentRequest{
+				{key: "k1", body: `{"license_plate":"AB123"}`, wantStatus: http.StatusInternalServerError},
+				{key: "k1", body: `{"license_plate":"AB123"}`, wantStatus: http.StatusCreated},
+			},
+			wantCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			e := newIdempotentServer(service.NewIdempotencyService(repository.NewMemoryIdempotencyRepository(), time.Hour, time.Second),
+				func(c echo.Context) error {
+					if calls.Add(1) == 1 {
+						switch tt.fail {
+						case "error":
+							return errors.New("database is gone")
+						case "panic":
+							panic("handler bug")
+						case "status":
+							return domain.ErrVehicleAlreadyParked
+						}
+					}
+					return c.JSON(http.StatusCreated, map[string]int32{"call": calls.Load()})
+				})
+
+			var first string
+			for i, request := range tt.requests {
+				body := strings.TrimSpace(request.send(t, e).Body.String())
+				if i == 0 {
+					first = body
+				} else if request.wantReplayed && body != first {
+					t.Errorf("got replayed body %s, want %s", body, first)
+				}
+			}
+
+			if calls.Load() != tt.wantCalls {
+				t.Errorf("got %d handler calls, want %d", calls.Load(), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	idempotencyRepo := repository.NewMemoryIdempotencyRepository()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	e := newIdempotentServer(service.NewIdempotencyService(idempotencyRepo, time.Hour, time.Second), func(c echo.Context) error {
+		close(started)
+		<-release
+		return c.JSON(http.StatusCreated, map[string]bool{"parked": true})
+	})
+
+	first := httptest.NewRequest(http.MethodPost, "/park", strings.NewReader(`{"license_plate":"AB123"}`))
+	first.Header.Set(HeaderIdempotencyKey, "k1")
+	firstRec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.ServeHTTP(firstRec, first)
+	}()
+	<-started
+
+	retry := idempotentRequest{key: "k1", body: `{"license_plate":"AB123"}`, wantStatus: http.StatusConflict, wantCode: domain.CodeIdempotencyInProgress}
+	retry.send(t, e)
+
+	close(release)
+	<-done
+	if firstRec.Code != http.StatusCreated {
+		t.Errorf("got status %d for the first request, want %d", firstRec.Code, http.StatusCreated)
+	}
+}
+
+func TestIdempotencyTakesOverAbandonedKey(t *testing.T) {
+	idempotencyRepo := repository.NewMemoryIdempotencyRepository()
+	idempotencyService := service.NewIdempotencyService(idempotencyRepo, time.Hour, time.Second)
+	var calls atomic.Int32
+	e := newIdempotentServer(idempotencyService, func(c echo.Context) error {
+		calls.Add(1)
+		return c.JSON(http.StatusCreated, map[string]bool{"parked": true})
+	})
+
+	// a first request that reserved the key and never finished, e.g. on an instance that stopped
+	request := idempotentRequest{key: "k1", body: `{"license_plate":"AB123"}`, wantStatus: http.StatusCreated}
+	hash := requestHash(httptest.NewRequest(http.MethodPost, "/park", nil), []byte(request.body))
+	past := time.Now().Add(-time.Minute)
+	_, err := idempotencyRepo.ReserveIdempotencyKey(context.Background(), &domain.IdempotencyRecord{
+		Key:            request.key,
+		RequestHash:    hash,
+		CreatedAt:      past,
+		ExpiresAt:      past.Add(time.Hour),
+		LeaseExpiresAt: past.Add(time.Second),
+	}, past)
+	if err != nil {
+		t.Fatalf("error reserving idempotency key: %v", err)
+	}
+
+	request.send(t, e)
+	if calls.Load() != 1 {
+		t.Errorf("got %d handler calls, want the retry to take over the key", calls.Load())
+	}
+}
+
+// idempotentRequest is a POST /park with an optional Idempotency-Key and the response it must get
+type idempotentRequest struct {
+	key          string
+	body         string
+	wantStatus   int
+	wantCode     string
+	wantReplayed bool
+}
+
+func (r idempotentRequest) send(t *testing.T, e *echo.Echo) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/park", strings.NewReader(r.body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if r.key != "" {
+		req.Header.Set(HeaderIdempotencyKey, r.key)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != r.wantStatus {
+		t.Fatalf("got status %d with %s, want %d", rec.Code, rec.Body.String(), r.wantStatus)
+	}
+	if replayed := rec.Header().Get(HeaderIdempotentReplayed) == "true"; replayed != r.wantReplayed {
+		t.Errorf("got replayed %v, want %v", replayed, r.wantReplayed)
+	}
+	if r.wantCode != "" {
+		var response domain.ErrorResponse
+		err := json.Unmarshal(rec.Body.Bytes(), &response)
+		if err != nil || response.ErrorCode != r.wantCode {
+			t.Errorf("got body %s, want error code %s", rec.Body.String(), r.wantCode)
+		}
+	}
+	return rec
+}
+
+// newIdempotentServer serves POST /park with the handler behind the Idempotency middleware, recovering
+// from panics like the server does
+func newIdempotentServer(idempotencyService domain.IdempotencyService, park echo.HandlerFunc) *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	e.Use(middleware.RecoverWithConfig(middleware.RecoverConfig{DisablePrintStack: true}))
+	e.POST("/park", park, Idempotency(idempotencyService))
+	return e
+}
@@ -1,134 +1,167 @@
 package handler
 
 import (
+	"context"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"parking-lot/config"
 	"parking-lot/domain"
 )
 
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
 type ParkingHandler struct {
-	parkingService domain.ParkingService
+	parkingService     domain.ParkingService
+	maintenanceService domain.MaintenanceService
 }
 
-func NewParkingHandler(parkingService domain.ParkingService) *ParkingHandler {
+func NewParkingHandler(parkingService domain.ParkingService, maintenanceService domain.MaintenanceService) *ParkingHandler {
 	return &ParkingHandler{
-		parkingService: parkingService,
+		parkingService:     parkingService,
+		maintenanceService: maintenanceService,
 	}
 }
 
 func (h *ParkingHandler) ParkVehicle(c echo.Context) error {
 	var req domain.ParkRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, domain.ParkResponse{
-			Success: false,
-			Message: "Invalid request format",
-		})
+		return domain.NewInvalidRequestError(domain.CodeInvalidRequest, "Invalid request format")
 	}
 
-	if req.LicensePlate == "" {
-		return c.JSON(http.StatusBadRequest, domain.ParkResponse{
-			Success: false,
-			Message: "License plate is required",
-		})
-	}
-
-	if req.VehicleType != domain.Motorcycle && req.VehicleType != domain.Bicycle && req.VehicleType != domain.Car {
-		return c.JSON(http.StatusBadRequest, domain.ParkResponse{
-			Success: false,
-			Message: "Invalid vehicle type. Must be 'motorcycle', 'bicycle', or 'car'",
-		})
-	}
-
-	spot, err := h.parkingService.ParkVehicle(req.LicensePlate, req.VehicleType)
+	spot, ticket, err := parkVehicle(c.Request().Context(), h.parkingService, req)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, domain.ParkResponse{
-			Success: false,
-			Message: err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, domain.ParkResponse{
 		Success:     true,
 		Message:     "Vehicle parked successfully",
 		ParkingSpot: spot,
+		Ticket:      ticket,
 	})
 }
 
 func (h *ParkingHandler) UnparkVehicle(c echo.Context) error {
 	var req domain.UnparkRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, domain.UnparkResponse{
-			Success: false,
-			Message: "Invalid request format",
-		})
-	}
-
-	if req.LicensePlate == "" {
-		return c.JSON(http.StatusBadRequest, domain.UnparkResponse{
-			Success: false,
-			Message: "License plate is required",
-		})
+		return domain.NewInvalidRequestError(domain.CodeInvalidRequest, "Invalid request format")
 	}
 
-	err := h.parkingService.UnparkVehicle(req.LicensePlate)
+	fee, err := unparkVehicle(c.Request().Context(), h.parkingService, req)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, domain.UnparkResponse{
-			Success: false,
-			Message: err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, domain.UnparkResponse{
 		Success: true,
 		Message: "Vehicle unparked successfully",
+		Fee:     fee,
+	})
+}
+
+// parkVehicle validates the request and parks the vehicle, for both the HTTP API and the gate socket
+func parkVehicle(ctx context.Context, parkingService domain.ParkingService, req domain.ParkRequest) (*domain.ParkingSpot, *domain.Ticket, error) {
+	vehicleTypes := config.GetAppConfig().VehicleTypes
+	if !vehicleTypes.IsValid(req.VehicleType) {
+		return nil, nil, domain.NewValidationError(domain.CodeInvalidVehicleType, "Invalid vehicle type. Must be %v", vehicleTypes)
+	}
+
+	if req.SizeClass != "" && !req.SizeClass.IsValid() {
+		return nil, nil, domain.NewValidationError(domain.CodeInvalidSizeClass, "Invalid size class. Must be 'compact', 'standard', or 'large'")
+	}
+
+	if req.LicensePlate == "" && vehicleTypes.RequiresLicensePlate(req.VehicleType) {
+		return nil, nil, domain.NewValidationError(domain.CodeLicensePlateRequired, "License plate is required")
+	}
+
+	return parkingService.ParkVehicle(ctx, req.LicensePlate, req.VehicleType, req.SizeClass, req.GateID)
+}
+
+// unparkVehicle validates the request and unparks the vehicle, for both the HTTP API and the gate socket
+func unparkVehicle(ctx context.Context, parkingService domain.ParkingService, req domain.UnparkRequest) (*domain.ParkingFee, error) {
+	if req.LostTicket {
+		if req.LicensePlate == "" {
+			return nil, domain.NewValidationError(domain.CodeLicensePlateRequired, "License plate is required for a lost ticket")
+		}
+		return parkingService.UnparkLostTicket(ctx, req.LicensePlate, req.GateID)
+	}
+
+	if req.TicketCode == "" && req.LicensePlate == "" {
+		return nil, domain.NewValidationError(domain.CodeTicketOrPlateRequired, "Ticket code or license plate is required")
+	}
+	return parkingService.UnparkVehicle(ctx, domain.ParkingLookup{
+		TicketCode:   req.TicketCode,
+		LicensePlate: req.LicensePlate,
+	}, req.GateID)
+}
+
+func (h *ParkingHandler) QuoteFee(c echo.Context) error {
+	lookup := domain.ParkingLookup{
+		TicketCode:   c.QueryParam("ticket_code"),
+		LicensePlate: c.QueryParam("license_plate"),
+	}
+	if lookup.TicketCode == "" && lookup.LicensePlate == "" {
+		return domain.NewValidationError(domain.CodeTicketOrPlateRequired, "Ticket code or license plate is required")
+	}
+
+	fee, err := h.parkingService.QuoteFee(c.Request().Context(), lookup)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, domain.QuoteResponse{
+		Success: true,
+		Message: "Fee calculated successfully",
+		Fee:     fee,
 	})
 }
 
 func (h *ParkingHandler) GetAvailableSpots(c echo.Context) error {
-	spots, err := h.parkingService.GetAllAvailableSpots()
+	spots, err := h.parkingService.GetAllAvailableSpots(c.Request().Context())
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, domain.AvailableSpotsResponse{
-			Success: false,
-			Message: err.Error(),
-		})
+		return err
 	}
 
-	// group spots by vehicle type
-	parkingConfig := config.GetAppConfig().Parking
-	spotMap := map[domain.VehicleType][]domain.ParkingSpot{}
+	maintenances, err := h.maintenanceService.GetActiveMaintenances(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	// group spots by vehicle type, listing every vehicle type even without available spots
+	spotMap := domain.ParkingSpotByVehicle{}
+	for _, vehicleType := range config.GetAppConfig().VehicleTypes.Types() {
+		spotMap[vehicleType] = []domain.ParkingSpot{}
+	}
 	for _, spot := range spots {
-		vehicleType := parkingConfig.FloorVehicleMap[spot.Floor]
-		spotMap[vehicleType] = append(spotMap[vehicleType], spot)
+		spotMap[spot.VehicleType] = append(spotMap[spot.VehicleType], spot)
 	}
 
 	return c.JSON(http.StatusOK, domain.AvailableSpotsResponse{
-		Success: true,
-		Message: "Available spots retrieved successfully",
-		ParkingSpots: domain.ParkingSpotByVehicle{
-			Car:        spotMap[domain.Car],
-			Motorcycle: spotMap[domain.Motorcycle],
-			Bicycle:    spotMap[domain.Bicycle],
-		},
+		Success:      true,
+		Message:      "Available spots retrieved successfully",
+		ParkingSpots: spotMap,
+		Maintenance:  maintenances,
 	})
 }
 
 func (h *ParkingHandler) SearchVehicle(c echo.Context) error {
-	licensePlate := c.QueryParam("license_plate")
-	if licensePlate == "" {
-		return c.JSON(http.StatusBadRequest, domain.SearchResponse{
-			Success: false,
-			Message: "License plate is required",
-		})
+	lookup := domain.ParkingLookup{
+		TicketCode:   c.QueryParam("ticket_code"),
+		LicensePlate: c.QueryParam("license_plate"),
+	}
+	if lookup.TicketCode == "" && lookup.LicensePlate == "" {
+		return domain.NewValidationError(domain.CodeTicketOrPlateRequired, "Ticket code or license plate is required")
 	}
 
-	spot, isParked, err := h.parkingService.SearchVehicle(licensePlate)
+	spot, isParked, err := h.parkingService.SearchVehicle(c.Request().Context(), lookup)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, domain.SearchResponse{
-			Success: false,
-			Message: err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, domain.SearchResponse{
@@ -138,3 +171,81 @@ func (h *ParkingHandler) SearchVehicle(c echo.Context) error {
 		IsParked:    isParked,
 	})
 }
+
+func (h *ParkingHandler) GetVehicleHistory(c echo.Context) error {
+	filter := domain.HistoryFilter{Limit: defaultHistoryLimit}
+
+	if value := c.QueryParam("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxHistoryLimit {
+			return domain.NewValidationError(domain.CodeInvalidHistoryFilter, "Invalid limit. Must be between 1 and %d", maxHistoryLimit)
+		}
+		filter.Limit = limit
+	}
+
+	if value := c.QueryParam("from"); value != "" {
+		from, err := parseHistoryTime(value, false)
+		if err != nil {
+			return domain.NewValidationError(domain.CodeInvalidHistoryFilter, "Invalid from. Must be a date (2006-01-02) or an RFC 3339 time")
+		}
+		filter.From = &from
+	}
+
+	if value := c.QueryParam("to"); value != "" {
+		to, err := parseHistoryTime(value, true)
+		if err != nil {
+			return domain.NewValidationError(domain.CodeInvalidHistoryFilter, "Invalid to. Must be a date (2006-01-02) or an RFC 3339 time")
+		}
+		filter.To = &to
+	}
+
+	if value := c.QueryParam("cursor"); value != "" {
+		cursor, err := domain.ParseHistoryCursor(value)
+		if err != nil {
+			return domain.NewValidationError(domain.CodeInvalidHistoryFilter, "Invalid cursor")
+		}
+		filter.After = cursor
+	}
+
+	history, err := h.parkingService.GetVehicleHistory(c.Request().Context(), c.Param("plate"), filter)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, domain.VehicleHistoryResponse{
+		Success:    true,
+		Message:    "Parking history retrieved successfully",
+		History:    history.Entries,
+		NextCursor: history.NextCursor,
+	})
+}
+
+func (h *ParkingHandler) GetGates(c echo.Context) error {
+	return c.JSON(http.StatusOK, domain.GatesResponse{
+		Success: true,
+		Message: "Gates retrieved successfully",
+		Gates:   config.GetAppConfig().Parking.Gates,
+	})
+}
+
+func (h *ParkingHandler) GetVehicleTypes(c echo.Context) error {
+	return c.JSON(http.StatusOK, domain.VehicleTypesResponse{
+		Success:      true,
+		Message:      "Vehicle types retrieved successfully",
+		VehicleTypes: config.GetAppConfig().VehicleTypes.Definitions(),
+	})
+}
+
+// parseHistoryTime parses an RFC 3339 time or a date in the server's time zone. A date used as the end
+// of a range includes the whole day.
+func parseHistoryTime(value string, endOfRange bool) (time.Time, error) {
+	date, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return time.Parse(time.RFC3339, value)
+	}
+
+	if endOfRange {
+		return date.AddDate(0, 0, 1), nil
+	}
+	return date, nil
+}
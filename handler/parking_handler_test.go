@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"parking-lot/domain"
+)
+
+func TestGetVehicleHistoryFilter(t *testing.T) {
+	cursor := domain.HistoryCursor{EntryTime: time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC), RecordID: 42}
+	jan1 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local)
+	jan2 := time.Date(2025, 1, 2, 0, 0, 0, 0, time.Local)
+	noon := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantFilter domain.HistoryFilter
+		wantCode   string
+	}{
+		{
+			name:       "defaults to the first page of 20 stays",
+			wantFilter: domain.HistoryFilter{Limit: 20},
+		},
+		{
+			name:       "takes the limit",
+			query:      "limit=100",
+			wantFilter: domain.HistoryFilter{Limit: 100},
+		},
+		{
+			name:     "rejects a limit of 0",
+			query:    "limit=0",
+			wantCode: domain.CodeInvalidHistoryFilter,
+		},
+		{
+			name:     "rejects a limit above the maximum",
+			query:    "limit=101",
+			wantCode: domain.CodeInvalidHistoryFilter,
+		},
+		{
+			name:       "ranges from the start of the from date to the end of the to date",
+			query:      "from=2025-01-01&to=2025-01-01",
+			wantFilter: domain.HistoryFilter{From: &jan1, To: &jan2, Limit: 20},
+		},
+		{
+			name:       "takes RFC 3339 times",
+			query:      "from=2025-01-01T12:00:00Z",
+			wantFilter: domain.HistoryFilter{From: &noon, Limit: 20},
+		},
+		{
+			name:     "rejects an invalid from",
+			query:    "from=yesterday",
+			wantCode: domain.CodeInvalidHistoryFilter,
+		},
+		{
+			name:     "rejects an invalid to",
+			query:    "to=2025-13-01",
+			wantCode: domain.CodeInvalidHistoryFilter,
+		},
+		{
+			name:       "resumes after the cursor",
+			query:      "cursor=" + cursor.String(),
+			wantFilter: domain.HistoryFilter{After: &cursor, Limit: 20},
+		},
+		{
+			name:     "rejects an invalid cursor",
+			query:    "cursor=not-a-cursor",
+			wantCode: domain.CodeInvalidHistoryFilter,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parkingService := &historyParkingService{nextCursor: "next"}
+			e := echo.New()
+			e.HTTPErrorHandler = ErrorHandler
+			e.GET("/vehicles/:plate/history", NewParkingHandler(parkingService, nil).GetVehicleHistory)
+
+			req := httptest.NewRequest(http.MethodGet, "/vehicles/AB123/history?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if tt.wantCode != "" {
+				var response domain.ErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &response)
+				if rec.Code != http.StatusUnprocessableEntity || err != nil || response.ErrorCode != tt.wantCode {
+					t.Errorf("got status %d with %s, want %s", rec.Code, rec.Body.String(), tt.wantCode)
+				}
+				if parkingService.filter != nil {
+					t.Errorf("got history looked up with %+v, want the request rejected", *parkingService.filter)
+				}
+				return
+			}
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d with %s, want %d", rec.Code, rec.Body.String(), http.StatusOK)
+			}
+			if parkingService.licensePlate != "AB123" || parkingService.filter == nil {
+				t.Fatalf("got history of %q with %v, want AB123", parkingService.licensePlate, parkingService.filter)
+			}
+			assertHistoryFilter(t, *parkingService.filter, tt.wantFilter)
+
+			var response domain.VehicleHistoryResponse
+			err := json.Unmarshal(rec.Body.Bytes(), &response)
+			if err != nil || response.NextCursor != "next" {
+				t.Errorf("got body %s, want the next cursor", rec.Body.String())
+			}
+		})
+	}
+}
+
+func assertHistoryFilter(t *testing.T, got, want domain.HistoryFilter) {
+	t.Helper()
+
+	sameTime := func(got, want *time.Time) bool {
+		return got == nil && want == nil || got != nil && want != nil && got.Equal(*want)
+	}
+	sameCursor := got.After == nil && want.After == nil ||
+		got.After != nil && want.After != nil && got.After.EntryTime.Equal(want.After.EntryTime) && got.After.RecordID == want.After.RecordID
+
+	if got.Limit != want.Limit || !sameTime(got.From, want.From) || !sameTime(got.To, want.To) || !sameCursor {
+		t.Errorf("got filter from %v to %v after %v limit %d, want from %v to %v after %v limit %d",
+			got.From, got.To, got.After, got.Limit, want.From, want.To, want.After, want.Limit)
+	}
+}
+
+// historyParkingService records the history lookup it gets, the other methods are not used
+type historyParkingService struct {
+	domain.ParkingService
+	nextCursor   string
+	licensePlate string
+	filter       *domain.HistoryFilter
+}
+
+func (s *historyParkingService) GetVehicleHistory(ctx context.Context, licensePlate string, filter domain.HistoryFilter) (*domain.ParkingHistory, error) {
+	s.licensePlate = licensePlate
+	s.filter = &filter
+	return &domain.ParkingHistory{NextCursor: s.nextCursor}, nil
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"parking-lot/config"
+	"parking-lot/domain"
+)
+
+type ReservationHandler struct {
+	reservationService domain.ReservationService
+}
+
+func NewReservationHandler(reservationService domain.ReservationService) *ReservationHandler {
+	return &ReservationHandler{
+		reservationService: reservationService,
+	}
+}
+
+func (h *ReservationHandler) CreateReservation(c echo.Context) error {
+	var req domain.ReservationRequest
+	if err := c.Bind(&req); err != nil {
+		return domain.NewInvalidRequestError(domain.CodeInvalidRequest, "Invalid request format")
+	}
+
+	if req.LicensePlate == "" {
+		return domain.NewValidationError(domain.CodeLicensePlateRequired, "License plate is required")
+	}
+
+	vehicleTypes := config.GetAppConfig().VehicleTypes
+	if !vehicleTypes.IsValid(req.VehicleType) {
+		return domain.NewValidationError(domain.CodeInvalidVehicleType, "Invalid vehicle type. Must be %v", vehicleTypes)
+	}
+
+	if req.StartTime.IsZero() || req.EndTime.IsZero() {
+		return domain.NewValidationError(domain.CodeInvalidReservation, "Start time and end time are required")
+	}
+
+	reservation, spot, err := h.reservationService.CreateReservation(c.Request().Context(), req.LicensePlate, req.VehicleType, req.StartTime, req.EndTime)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, domain.ReservationResponse{
+		Success:     true,
+		Message:     "Reservation created successfully",
+		Reservation: reservation,
+		ParkingSpot: spot,
+	})
+}
+
+func (h *ReservationHandler) GetReservations(c echo.Context) error {
+	licensePlate := c.QueryParam("license_plate")
+	if licensePlate == "" {
+		return domain.NewValidationError(domain.CodeLicensePlateRequired, "License plate is required")
+	}
+
+	reservations, err := h.reservationService.GetReservationsByLicensePlate(c.Request().Context(), licensePlate)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, domain.ReservationsResponse{
+		Success:      true,
+		Message:      "Reservations retrieved successfully",
+		Reservations: reservations,
+	})
+}
+
+func (h *ReservationHandler) GetReservation(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return domain.NewInvalidRequestError(domain.CodeInvalidRequest, "Invalid reservation ID")
+	}
+
+	reservation, err := h.reservationService.GetReservation(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, domain.ReservationResponse{
+		Success:     true,
+		Message:     "Reservation found",
+		Reservation: reservation,
+	})
+}
+
+func (h *ReservationHandler) CancelReservation(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return domain.NewInvalidRequestError(domain.CodeInvalidRequest, "Invalid reservation ID")
+	}
+
+	err = h.reservationService.CancelReservation(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, domain.ReservationResponse{
+		Success: true,
+		Message: "Reservation cancelled successfully",
+	})
+}
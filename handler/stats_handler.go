@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"parking-lot/domain"
+)
+
+type StatsHandler struct {
+	statsService domain.StatsService
+}
+
+func NewStatsHandler(statsService domain.StatsService) *StatsHandler {
+	return &StatsHandler{
+		statsService: statsService,
+	}
+}
+
+func (h *StatsHandler) GetOccupancy(c echo.Context) error {
+	stats, err := h.statsService.GetOccupancy(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, domain.OccupancyResponse{
+		Success: true,
+		Message: "Occupancy retrieved successfully",
+		Stats:   stats,
+	})
+}
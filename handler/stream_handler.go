@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"parking-lot/config"
+	"parking-lot/domain"
+)
+
+const headerLastEventID = "Last-Event-ID"
+
+type StreamHandler struct {
+	eventStream       domain.EventStream
+	heartbeatInterval time.Duration
+}
+
+func NewStreamHandler(eventStream domain.EventStream, heartbeatInterval time.Duration) *StreamHandler {
+	return &StreamHandler{
+		eventStream:       eventStream,
+		heartbeatInterval: heartbeatInterval,
+	}
+}
+
+// StreamEvents pushes spot and floor capacity events as server-sent events until the client disconnects
+func (h *StreamHandler) StreamEvents(c echo.Context) error {
+	filter, err := parseStreamFilter(c)
+	if err != nil {
+		return err
+	}
+
+	// EventSource sends the ID of the last event it received when it reconnects, other clients may not be
+	// able to set headers
+	lastEventID := c.Request().Header.Get(headerLastEventID)
+	if lastEventID == "" {
+		lastEventID = c.QueryParam("last_event_id")
+	}
+
+	replay, events, cancel := h.eventStream.Subscribe(filter, lastEventID)
+	defer cancel()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	// keep reverse proxies such as nginx from buffering the stream
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	for _, event := range replay {
+		err = writeStreamEvent(res, event)
+		if err != nil {
+			return nil
+		}
+	}
+	res.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				// the client fell behind or the server is stopping, it resumes from its last event ID
+				return nil
+			}
+			err = writeStreamEvent(res, event)
+		case <-heartbeat.C:
+			_, err = io.WriteString(res, ": heartbeat\n\n")
+		}
+		if err != nil {
+			// the client is gone
+			return nil
+		}
+		res.Flush()
+	}
+}
+
+func writeStreamEvent(w io.Writer, event domain.StreamEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// parseStreamFilter reads the floor and vehicle_type query parameters, each a comma-separated list that
+// may also be repeated
+func parseStreamFilter(c echo.Context) (domain.StreamFilter, error) {
+	var filter domain.StreamFilter
+
+	for _, value := range queryList(c, "floor") {
+		floor, err := strconv.Atoi(value)
+		if err != nil || floor < 1 {
+			return filter, domain.NewValidationError(domain.CodeInvalidStreamFilter, "Invalid floor %q. Must be a positive number", value)
+		}
+		filter.Floors = append(filter.Floors, floor)
+	}
+
+	vehicleTypes := config.GetAppConfig().VehicleTypes
+	for _, value := range queryList(c, "vehicle_type") {
+		vehicleType := domain.VehicleType(value)
+		if !vehicleTypes.IsValid(vehicleType) {
+			return filter, domain.NewValidationError(domain.CodeInvalidStreamFilter, "Invalid vehicle type %q. Must be %v", value, vehicleTypes)
+		}
+		filter.VehicleTypes = append(filter.VehicleTypes, vehicleType)
+	}
+
+	return filter, nil
+}
+
+// queryList returns the comma-separated values of every occurrence of the query parameter
+func queryList(c echo.Context, name string) []string {
+	var values []string
+	for _, param := range c.QueryParams()[name] {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"parking-lot/domain"
+	"parking-lot/repository"
+	"parking-lot/service"
+)
+
+func TestStreamEventsAuthentication(t *testing.T) {
+	ctx := context.Background()
+	authService := service.NewAuthService(repository.NewMemoryAPIKeyRepository(), "")
+	keys := map[domain.Role]string{}
+	for _, role := range []domain.Role{domain.RoleReporting, domain.RoleGate} {
+		_, key, err := authService.CreateAPIKey(ctx, string(role), role)
+		if err != nil {
+			t.Fatalf("CreateAPIKey() error = %v", err)
+		}
+		keys[role] = key
+	}
+	revoked, revokedKey, err := authService.CreateAPIKey(ctx, "revoked", domain.RoleReporting)
+	if err != nil {
+		t.Fatalf("CreateAPIKey() error = %v", err)
+	}
+	err = authService.RevokeAPIKey(ctx, revoked.ID)
+	if err != nil {
+		t.Fatalf("RevokeAPIKey() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		bearer      string
+		accessToken string
+		wantStatus  int
+	}{
+		{
+			name:       "rejects a stream without a key",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "accepts a key as a bearer token",
+			bearer:     keys[domain.RoleGate],
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:        "accepts a reporting key in the query",
+			accessToken: keys[domain.RoleReporting],
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "rejects other roles in the query",
+			accessToken: keys[domain.RoleGate],
+			wantStatus:  http.StatusForbidden,
+		},
+		{
+			name:        "rejects an unknown key in the query",
+			accessToken: "unknown",
+			wantStatus:  http.StatusUnauthorized,
+		},
+		{
+			name:        "rejects a revoked key in the query",
+			accessToken: revokedKey,
+			wantStatus:  http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.HTTPErrorHandler = ErrorHandler
+			e.GET("/events/stream", NewStreamHandler(closedEventStream{}, time.Minute).StreamEvents, AuthenticateStream(authService))
+
+			target := "/events/stream"
+			if tt.accessToken != "" {
+				target += "?access_token=" + tt.accessToken
+			}
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			if tt.bearer != "" {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+tt.bearer)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %d with %s, want %d", rec.Code, rec.Body.String(), tt.wantStatus)
+			}
+		})
+	}
+}
+
+// closedEventStream ends every subscription right away, so a stream answers without waiting for events
+type closedEventStream struct{}
+
+func (closedEventStream) SpotOccupied(ctx context.Context, spotID int64) {}
+
+func (closedEventStream) SpotFreed(ctx context.Context, spotID int64) {}
+
+func (closedEventStream) Subscribe(domain.StreamFilter, string) ([]domain.StreamEvent, <-chan domain.StreamEvent, func()) {
+	events := make(chan domain.StreamEvent)
+	close(events)
+	return nil, events, func() {}
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"parking-lot/domain"
+)
+
+type WebhookHandler struct {
+	webhookService domain.WebhookService
+}
+
+func NewWebhookHandler(webhookService domain.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+func (h *WebhookHandler) CreateWebhook(c echo.Context) error {
+	var req domain.WebhookRequest
+	if err := c.Bind(&req); err != nil {
+		return domain.NewInvalidRequestError(domain.CodeInvalidRequest, "Invalid request format")
+	}
+
+	webhook, err := h.webhookService.CreateWebhook(c.Request().Context(), req.URL, req.EventTypes)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, domain.WebhookResponse{
+		Success: true,
+		Message: "Webhook created, store its secret now as it cannot be shown again",
+		Webhook: webhook,
+	})
+}
+
+func (h *WebhookHandler) GetWebhooks(c echo.Context) error {
+	webhooks, err := h.webhookService.GetWebhooks(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	if webhooks == nil {
+		webhooks = []domain.Webhook{}
+	}
+
+	return c.JSON(http.StatusOK, domain.WebhooksResponse{
+		Success:  true,
+		Message:  "Webhooks retrieved successfully",
+		Webhooks: webhooks,
+	})
+}
+
+func (h *WebhookHandler) DeleteWebhook(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return domain.NewInvalidRequestError(domain.CodeInvalidRequest, "Invalid webhook ID")
+	}
+
+	err = h.webhookService.DeleteWebhook(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, domain.WebhookResponse{
+		Success: true,
+		Message: "Webhook deleted successfully",
+	})
+}
+
+func (h *WebhookHandler) GetDeadDeliveries(c echo.Context) error {
+	deliveries, err := h.webhookService.GetDeadDeliveries(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	if deliveries == nil {
+		deliveries = []domain.WebhookDelivery{}
+	}
+
+	return c.JSON(http.StatusOK, domain.WebhookDeliveriesResponse{
+		Success:    true,
+		Message:    "Dead deliveries retrieved successfully",
+		Deliveries: deliveries,
+	})
+}
+
+func (h *WebhookHandler) RetryDeadDelivery(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return domain.NewInvalidRequestError(domain.CodeInvalidRequest, "Invalid delivery ID")
+	}
+
+	err = h.webhookService.RetryDeadDelivery(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, domain.WebhookResponse{
+		Success: true,
+		Message: "Delivery scheduled for retry",
+	})
+}
@@ -1,13 +1,26 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"parking-lot/config"
+	"parking-lot/domain"
 	"parking-lot/handler"
+	"parking-lot/metrics"
 	"parking-lot/repository"
 	"parking-lot/service"
 )
@@ -16,32 +29,257 @@ func main() {
 	// Get application configuration
 	appConfig := config.GetAppConfig()
 
-	// Initialize database connection
-	db, err := config.InitDBConnection()
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+	// Initialize repositories for the configured storage driver
+	var (
+		parkingRepo     domain.ParkingRepository
+		vehicleRepo     domain.VehicleRepository
+		reservationRepo domain.ReservationRepository
+		maintenanceRepo domain.MaintenanceRepository
+		statsRepo       domain.StatsRepository
+		apiKeyRepo      domain.APIKeyRepository
+		idempotencyRepo domain.IdempotencyRepository
+		webhookRepo     domain.WebhookRepository
+		transactor      domain.Transactor
+		// db stays nil for the memory storage
+		db *sql.DB
+	)
+	switch appConfig.Storage.Driver {
+	case config.StorageDriverMemory:
+		parkingRepo = repository.NewMemoryParkingRepository()
+		vehicleRepo = repository.NewMemoryVehicleRepository(parkingRepo)
+		reservationRepo = repository.NewMemoryReservationRepository(parkingRepo)
+		maintenanceRepo = repository.NewMemoryMaintenanceRepository(parkingRepo)
+		statsRepo = repository.NewMemoryStatsRepository(parkingRepo)
+		apiKeyRepo = repository.NewMemoryAPIKeyRepository()
+		idempotencyRepo = repository.NewMemoryIdempotencyRepository()
+		webhookRepo = repository.NewMemoryWebhookRepository()
+		transactor = repository.NewMemoryTransactor()
+		log.Println("Using in-memory storage, data will be lost on restart")
+	default:
+		var err error
+		db, err = config.InitDBConnection()
+		if err != nil {
+			log.Fatalf("Failed to connect to database: %v", err)
+		}
+
+		// Refuse to start against a schema the code does not expect
+		err = config.CheckSchemaVersion(db)
+		if err != nil {
+			log.Fatalf("Failed to verify database schema: %v", err)
+		}
+
+		if appConfig.Storage.Driver == config.StorageDriverSQLite {
+			parkingRepo = repository.NewSQLiteParkingRepository(db)
+			reservationRepo = repository.NewSQLiteReservationRepository(db)
+			maintenanceRepo = repository.NewSQLiteMaintenanceRepository(db)
+			webhookRepo = repository.NewSQLiteWebhookRepository(db)
+		} else {
+			parkingRepo = repository.NewParkingRepository(db)
+			reservationRepo = repository.NewReservationRepository(db)
+			maintenanceRepo = repository.NewMaintenanceRepository(db)
+			webhookRepo = repository.NewWebhookRepository(db)
+		}
+		vehicleRepo = repository.NewVehicleRepository(db)
+		statsRepo = repository.NewStatsRepository(db)
+		apiKeyRepo = repository.NewAPIKeyRepository(db)
+		idempotencyRepo = repository.NewIdempotencyRepository(db)
+		transactor = repository.NewTransactor(db)
+	}
+
+	layoutService := service.NewLayoutService(parkingRepo)
+
+	// In-memory storage starts empty, create the configured spots
+	if appConfig.Storage.Driver == config.StorageDriverMemory {
+		_, err := layoutService.ReconcileLayout(context.Background(), false)
+		if err != nil {
+			log.Fatalf("Failed to initialize parking spots: %v", err)
+		}
 	}
-	defer db.Close()
 
-	parkingRepo := repository.NewParkingRepository(db)
-	vehicleRepo := repository.NewVehicleRepository(db)
+	webhookService := service.NewWebhookService(webhookRepo)
+	eventStream := service.NewEventStream(parkingRepo, statsRepo, appConfig.Stream)
+	parkingService := service.NewParkingService(
+		parkingRepo,
+		vehicleRepo,
+		reservationRepo,
+		service.NewSpotAllocator(appConfig.Parking.AllocationStrategy),
+		transactor,
+		webhookService,
+		eventStream,
+	)
+	reservationService := service.NewReservationService(reservationRepo)
+	maintenanceService := service.NewMaintenanceService(maintenanceRepo, transactor, webhookService)
+	parkingHandler := handler.NewParkingHandler(parkingService, maintenanceService)
+	reservationHandler := handler.NewReservationHandler(reservationService)
+	adminHandler := handler.NewAdminHandler(layoutService, maintenanceService)
+	statsService := service.NewStatsService(statsRepo)
+	statsHandler := handler.NewStatsHandler(statsService)
+	healthHandler := handler.NewHealthHandler(service.NewHealthService(db, parkingRepo))
+	authService := service.NewAuthService(apiKeyRepo, appConfig.Admin.APIToken)
+	authHandler := handler.NewAuthHandler(authService)
+	idempotencyService := service.NewIdempotencyService(idempotencyRepo, appConfig.Server.IdempotencyTTL, appConfig.Server.RequestTimeout)
+	webhookHandler := handler.NewWebhookHandler(webhookService)
+	streamHandler := handler.NewStreamHandler(eventStream, appConfig.Stream.HeartbeatInterval)
+	gateSocketHandler := handler.NewGateSocketHandler(
+		parkingService,
+		authService,
+		idempotencyService,
+		appConfig.GateSocket.HeartbeatInterval,
+		appConfig.Server.RequestTimeout,
+		appConfig.Server.CORSAllowedOrigins,
+	)
+
+	// Spot gauges are read from the stats service on every scrape
+	prometheus.MustRegister(metrics.NewOccupancyCollector(statsService))
+
+	// Stop on SIGINT or SIGTERM, e.g. when a deploy replaces this instance
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	parkingService := service.NewParkingService(parkingRepo, vehicleRepo)
-	parkingHandler := handler.NewParkingHandler(parkingService)
+	// Release reservations of vehicles that did not show up
+	expiryDone := make(chan struct{})
+	go func() {
+		defer close(expiryDone)
+		expireReservations(ctx, reservationService, appConfig.Reservation.ExpiryInterval)
+	}()
+
+	// Turn the parks and unparks of every instance into stream events, the subscribed streams end when it stops
+	streamDone := make(chan struct{})
+	go func() {
+		defer close(streamDone)
+		eventStream.Run(ctx)
+	}()
+
+	// Deliver the events in the outbox to the registered webhooks
+	dispatcherDone := make(chan struct{})
+	go func() {
+		defer close(dispatcherDone)
+		service.NewWebhookDispatcher(webhookRepo, appConfig.Webhook).Run(ctx)
+	}()
 
 	e := echo.New()
+	e.HTTPErrorHandler = handler.ErrorHandler
 
 	e.Use(middleware.Logger())
+	e.Use(handler.Metrics())
 	e.Use(middleware.Recover())
-	e.Use(middleware.CORS())
+	if len(appConfig.Server.CORSAllowedOrigins) > 0 {
+		e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+			AllowOrigins: appConfig.Server.CORSAllowedOrigins,
+			AllowHeaders: []string{echo.HeaderAuthorization, echo.HeaderContentType, handler.HeaderIdempotencyKey},
+			// lets browsers tell a replayed response from the first one
+			ExposeHeaders: []string{handler.HeaderIdempotentReplayed},
+		}))
+	}
+	// Streams and sockets stay open for as long as their client listens
+	longLivedRoutes := []string{"/events/stream", "/gates/ws"}
+	e.Use(handler.RequestTimeout(appConfig.Server.RequestTimeout, func(c echo.Context) bool {
+		return slices.Contains(longLivedRoutes, c.Path())
+	}))
+
+	// Probes
+	e.GET("/healthz", healthHandler.Healthz)
+	e.GET("/readyz", healthHandler.Readyz)
+	e.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
+
+	// Gates authenticate in the hello of the socket
+	e.GET("/gates/ws", gateSocketHandler.Serve)
+
+	// Browsers cannot set headers on an event stream, it also takes a reporting key in the URL
+	e.GET("/events/stream", streamHandler.StreamEvents, handler.AuthenticateStream(authService))
+
+	// Every other route requires an API key, reading is open to every role
+	api := e.Group("", handler.Authenticate(authService))
+	gateAccess := handler.RequireRole(domain.RoleGate, domain.RoleAttendant)
+	attendantAccess := handler.RequireRole(domain.RoleAttendant)
+	idempotent := handler.Idempotency(idempotencyService)
 
 	// Routes
-	e.POST("/park", parkingHandler.ParkVehicle)
-	e.POST("/unpark", parkingHandler.UnparkVehicle)
-	e.GET("/available", parkingHandler.GetAvailableSpots)
-	e.GET("/search", parkingHandler.SearchVehicle)
+	api.POST("/park", parkingHandler.ParkVehicle, gateAccess, idempotent)
+	api.POST("/unpark", parkingHandler.UnparkVehicle, gateAccess, idempotent)
+	api.GET("/available", parkingHandler.GetAvailableSpots)
+	api.GET("/search", parkingHandler.SearchVehicle)
+	api.GET("/quote", parkingHandler.QuoteFee)
+	api.GET("/gates", parkingHandler.GetGates)
+	api.GET("/vehicle-types", parkingHandler.GetVehicleTypes)
+	api.GET("/vehicles/:plate/history", parkingHandler.GetVehicleHistory)
+	api.GET("/stats/occupancy", statsHandler.GetOccupancy)
+
+	api.POST("/reservations", reservationHandler.CreateReservation, attendantAccess)
+	api.GET("/reservations", reservationHandler.GetReservations)
+	api.GET("/reservations/:id", reservationHandler.GetReservation)
+	api.DELETE("/reservations/:id", reservationHandler.CancelReservation, attendantAccess)
+
+	admin := api.Group("/admin", handler.RequireRole(domain.RoleAdmin))
+	admin.POST("/layout/reconcile", adminHandler.ReconcileLayout)
+	admin.POST("/spots/deactivate", adminHandler.DeactivateSpots)
+	admin.POST("/spots/reactivate", adminHandler.ReactivateSpots)
+	admin.POST("/api-keys", authHandler.CreateAPIKey)
+	admin.GET("/api-keys", authHandler.GetAPIKeys)
+	admin.DELETE("/api-keys/:id", authHandler.RevokeAPIKey)
+	admin.POST("/webhooks", webhookHandler.CreateWebhook)
+	admin.GET("/webhooks", webhookHandler.GetWebhooks)
+	admin.DELETE("/webhooks/:id", webhookHandler.DeleteWebhook)
+	admin.GET("/webhooks/deliveries/dead", webhookHandler.GetDeadDeliveries)
+	admin.POST("/webhooks/deliveries/:id/retry", webhookHandler.RetryDeadDelivery)
 
 	// Start server
 	port := appConfig.Server.Port
-	e.Logger.Fatal(e.Start(fmt.Sprintf(":%s", port)))
+	go func() {
+		err := e.Start(fmt.Sprintf(":%s", port))
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Printf("Shutting down, waiting up to %s for in-flight requests\n", appConfig.Server.ShutdownTimeout)
+
+	// Stop accepting connections and let in-flight requests finish before closing the database
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), appConfig.Server.ShutdownTimeout)
+	defer cancel()
+
+	err := e.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Printf("Failed to drain in-flight requests: %v\n", err)
+	}
+	// Sockets are not drained by the server, disconnect the gates once their messages are handled
+	gateSocketHandler.Close()
+	<-expiryDone
+	<-dispatcherDone
+	<-streamDone
+
+	if db != nil {
+		err = db.Close()
+		if err != nil {
+			log.Printf("Failed to close database: %v\n", err)
+		}
+	}
+
+	log.Println("Server stopped")
+}
+
+// expireReservations periodically releases reservations whose vehicle did not arrive within the grace period,
+// until ctx is done
+func expireReservations(ctx context.Context, reservationService domain.ReservationService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		expired, err := reservationService.ExpireNoShows(ctx)
+		if err != nil {
+			log.Printf("Failed to expire reservations: %v\n", err)
+			continue
+		}
+		if expired > 0 {
+			log.Printf("Released %d reservation(s) of vehicles that did not show up\n", expired)
+		}
+	}
 }
@@ -0,0 +1,82 @@
+// Package metrics defines the Prometheus metrics of the parking lot, registered with the default registry
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"parking-lot/domain"
+)
+
+// OutcomeSuccess is the outcome of an attempt that did not fail, failed attempts use their error code
+const OutcomeSuccess = "success"
+
+var (
+	ParkAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "parking_park_attempts_total",
+		Help: "Park attempts by vehicle type and outcome, the outcome is success or the error code.",
+	}, []string{"vehicle_type", "outcome"})
+
+	UnparkAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "parking_unpark_attempts_total",
+		Help: "Unpark attempts by vehicle type and outcome, the outcome is success or the error code.",
+	}, []string{"vehicle_type", "outcome"})
+
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "parking_http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	QueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "parking_db_query_duration_seconds",
+		Help:    "Database latency by repository operation, including transactions with several queries.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation"})
+
+	SpotClaimDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "parking_spot_claim_duration_seconds",
+		Help:    "Time to claim a spot for a parking vehicle, including waiting on concurrent claims and retries.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"outcome"})
+
+	SpotClaimRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "parking_spot_claim_retries_total",
+		Help: "Spot claims retried because a concurrent claim took every ranked candidate.",
+	})
+
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "parking_webhook_deliveries_total",
+		Help: "Webhook delivery attempts by event type and outcome (delivered, failed or dead).",
+	}, []string{"event_type", "outcome"})
+
+	StreamEventsDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "parking_stream_events_dropped_total",
+		Help: "Spot changes left out of the event stream because they could not be streamed.",
+	})
+)
+
+// Outcome returns the outcome label of an attempt failing with err, nil for success
+func Outcome(err error) string {
+	if err == nil {
+		return OutcomeSuccess
+	}
+
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) {
+		return domainErr.Code
+	}
+	return domain.CodeInternal
+}
+
+// ObserveQuery starts timing a repository operation, call the returned function when it is done:
+//
+//	defer metrics.ObserveQuery("get_spot_by_id")()
+func ObserveQuery(operation string) func() {
+	start := time.Now()
+	return func() {
+		QueryDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"parking-lot/domain"
+)
+
+// collectTimeout bounds the stats query of a scrape, which carries no request context
+const collectTimeout = 5 * time.Second
+
+var spotsDesc = prometheus.NewDesc(
+	"parking_spots",
+	"Parking spots by floor and state (occupied, reserved, inactive or free).",
+	[]string{"floor", "state"},
+	nil,
+)
+
+// occupancyCollector reads the spot counts per floor from the stats service on every scrape
+type occupancyCollector struct {
+	statsService domain.StatsService
+}
+
+// NewOccupancyCollector returns a collector exposing the spots per floor and state as gauges
+func NewOccupancyCollector(statsService domain.StatsService) prometheus.Collector {
+	return &occupancyCollector{
+		statsService: statsService,
+	}
+}
+
+func (c *occupancyCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- spotsDesc
+}
+
+func (c *occupancyCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	stats, err := c.statsService.GetOccupancy(ctx)
+	if err != nil {
+		log.Printf("Failed to collect occupancy metrics: %v", err)
+		ch <- prometheus.NewInvalidMetric(spotsDesc, err)
+		return
+	}
+
+	for _, floor := range stats.Floors {
+		label := strconv.Itoa(floor.Floor)
+		for state, count := range map[string]int64{
+			"occupied": floor.Occupied,
+			"reserved": floor.Reserved,
+			"inactive": floor.Inactive,
+			"free":     floor.Free,
+		} {
+			ch <- prometheus.MustNewConstMetric(spotsDesc, prometheus.GaugeValue, float64(count), label, state)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"parking-lot/domain"
+	"parking-lot/metrics"
+)
+
+type apiKeyRepo struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) domain.APIKeyRepository {
+	return &apiKeyRepo{
+		db: db,
+	}
+}
+
+func (r *apiKeyRepo) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	defer metrics.ObserveQuery("create_api_key")()
+
+	query := `
+		INSERT INTO api_keys (name, role, key_prefix, key_hash, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	now := time.Now()
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, key.Name, key.Role, key.Prefix, key.KeyHash, now).Scan(&key.ID)
+	if err != nil {
+		return err
+	}
+
+	key.CreatedAt = now
+
+	return nil
+}
+
+func (r *apiKeyRepo) GetAPIKeyByHash(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	defer metrics.ObserveQuery("get_api_key_by_hash")()
+
+	query := `
+		SELECT id, name, role, key_prefix, key_hash, created_at, revoked_at
+		FROM api_keys
+		WHERE key_hash = $1
+	`
+
+	key, err := scanAPIKey(conn(ctx, r.db).QueryRowContext(ctx, query, keyHash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (r *apiKeyRepo) GetAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	defer metrics.ObserveQuery("get_api_keys")()
+
+	query := `
+		SELECT id, name, role, key_prefix, key_hash, created_at, revoked_at
+		FROM api_keys
+		ORDER BY id
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []domain.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (r *apiKeyRepo) RevokeAPIKey(ctx context.Context, id int64, at time.Time) (bool, error) {
+	defer metrics.ObserveQuery("revoke_api_key")()
+
+	query := `
+		UPDATE api_keys
+		SET revoked_at = $2
+		WHERE id = $1 AND revoked_at IS NULL
+	`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, id, at)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// scanAPIKey reads a key from a row selected with the columns of GetAPIKeys
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (*domain.APIKey, error) {
+	var key domain.APIKey
+	err := row.Scan(
+		&key.ID,
+		&key.Name,
+		&key.Role,
+		&key.Prefix,
+		&key.KeyHash,
+		&key.CreatedAt,
+		&key.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
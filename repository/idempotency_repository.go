@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"parking-lot/domain"
+	"parking-lot/metrics"
+)
+
+// maxReserveAttempts bounds how often a key released between the insert and the select is inserted again
+const maxReserveAttempts = 3
+
+type idempotencyRepo struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) domain.IdempotencyRepository {
+	return &idempotencyRepo{
+		db: db,
+	}
+}
+
+func (r *idempotencyRepo) ReserveIdempotencyKey(ctx context.Context, record *domain.IdempotencyRecord, now time.Time) (*domain.IdempotencyRecord, error) {
+	defer metrics.ObserveQuery("reserve_idempotency_key")()
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= $1`, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// an unfinished record of the same request is taken over once its lease expired
+	insertQuery := `
+		INSERT INTO idempotency_keys (api_key_id, idempotency_key, request_hash, created_at, expires_at, lease_expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (api_key_id, idempotency_key) DO UPDATE
+		SET created_at = excluded.created_at, expires_at = excluded.expires_at, lease_expires_at = excluded.lease_expires_at
+		WHERE idempotency_keys.status_code IS NULL
+			AND idempotency_keys.request_hash = excluded.request_hash
+			AND idempotency_keys.lease_expires_at <= $7
+	`
+	selectQuery := `
+		SELECT request_hash, status_code, response_body, created_at, expires_at, lease_expires_at
+		FROM idempotency_keys
+		WHERE api_key_id = $1 AND idempotency_key = $2
+	`
+
+	// the existing record can be released between the insert and the select, then the insert is tried again
+	for range maxReserveAttempts {
+		result, err := conn(ctx, r.db).ExecContext(ctx, insertQuery, record.APIKeyID, record.Key, record.RequestHash, record.CreatedAt, record.ExpiresAt, record.LeaseExpiresAt, now)
+		if err != nil {
+			return nil, err
+		}
+
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if inserted > 0 {
+			return nil, nil
+		}
+
+		existing := domain.IdempotencyRecord{
+			APIKeyID: record.APIKeyID,
+			Key:      record.Key,
+		}
+		var statusCode sql.NullInt64
+		err = conn(ctx, r.db).QueryRowContext(ctx, selectQuery, record.APIKeyID, record.Key).Scan(
+			&existing.RequestHash,
+			&statusCode,
+			&existing.ResponseBody,
+			&existing.CreatedAt,
+			&existing.ExpiresAt,
+			&existing.LeaseExpiresAt,
+		)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		existing.StatusCode = int(statusCode.Int64)
+		return &existing, nil
+	}
+
+	return nil, fmt.Errorf("idempotency key %s was released %d times while reserving it", record.Key, maxReserveAttempts)
+}
+
+func (r *idempotencyRepo) CompleteIdempotencyKey(ctx context.Context, apiKeyID int64, key string, statusCode int, responseBody []byte) error {
+	defer metrics.ObserveQuery("complete_idempotency_key")()
+
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $3, response_body = $4
+		WHERE api_key_id = $1 AND idempotency_key = $2
+	`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, apiKeyID, key, statusCode, responseBody)
+	return err
+}
+
+func (r *idempotencyRepo) DeleteIdempotencyKey(ctx context.Context, apiKeyID int64, key string) error {
+	defer metrics.ObserveQuery("delete_idempotency_key")()
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM idempotency_keys WHERE api_key_id = $1 AND idempotency_key = $2`, apiKeyID, key)
+	return err
+}
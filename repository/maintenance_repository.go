@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"parking-lot/domain"
+	"parking-lot/metrics"
+)
+
+type maintenanceRepo struct {
+	db *sql.DB
+	// spotLockClause is appended to the spot selection in StartMaintenance to lock the selected rows
+	spotLockClause string
+}
+
+func NewMaintenanceRepository(db *sql.DB) domain.MaintenanceRepository {
+	return &maintenanceRepo{
+		db:             db,
+		spotLockClause: "FOR UPDATE",
+	}
+}
+
+// NewSQLiteMaintenanceRepository returns a maintenance repository backed by SQLite,
+// relying on BEGIN IMMEDIATE transactions instead of row locks like NewSQLiteParkingRepository
+func NewSQLiteMaintenanceRepository(db *sql.DB) domain.MaintenanceRepository {
+	return &maintenanceRepo{
+		db:             db,
+		spotLockClause: "",
+	}
+}
+
+// selectorCondition matches the spots ps selected by the floor, row and column given as $1, $2 and $3
+const selectorCondition = `ps.floor = $1 AND ($2 = 0 OR ps.row = $2) AND ($3 = 0 OR ps.column = $3)`
+
+func (r *maintenanceRepo) StartMaintenance(
+	ctx context.Context,
+	selector domain.SpotSelector,
+	reason string,
+	endTime *time.Time,
+	force bool,
+) ([]domain.SpotMaintenance, []domain.ParkingSpot, error) {
+	defer metrics.ObserveQuery("start_maintenance")()
+
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Lock the spots so no vehicle can be parked on them until the maintenance is stored
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`
+		SELECT ps.id, ps.floor, ps.row, ps.column, ps.vehicle_type, ps.size_class, ps.is_active, ps.created_at, ps.updated_at,
+			EXISTS (
+				SELECT 1
+				FROM parking_records pr
+				WHERE pr.parking_spot_id = ps.id AND pr.exit_time IS NULL
+			)
+		FROM parking_spots ps
+		WHERE ps.is_active = true AND %s
+		ORDER BY ps.floor, ps.row, ps.column
+		%s
+	`, selectorCondition, r.spotLockClause), selector.Floor, selector.Row, selector.Column)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var spots, occupied []domain.ParkingSpot
+	for rows.Next() {
+		var (
+			spot       domain.ParkingSpot
+			isOccupied bool
+		)
+		err = rows.Scan(
+			&spot.ID,
+			&spot.Floor,
+			&spot.Row,
+			&spot.Column,
+			&spot.VehicleType,
+			&spot.SizeClass,
+			&spot.IsActive,
+			&spot.CreatedAt,
+			&spot.UpdatedAt,
+			&isOccupied,
+		)
+		if err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		spots = append(spots, spot)
+		if isOccupied {
+			occupied = append(occupied, spot)
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(occupied) > 0 && !force {
+		tx.Rollback()
+		return nil, occupied, nil
+	}
+
+	// a new maintenance replaces the one the spot is under now, if any
+	now := time.Now()
+	var maintenances []domain.SpotMaintenance
+	for _, spot := range spots {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE spot_maintenances
+			SET end_time = $1, updated_at = $1
+			WHERE parking_spot_id = $2 AND (end_time IS NULL OR end_time > $1)
+		`, now, spot.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		maintenance := domain.SpotMaintenance{
+			ParkingSpotID: spot.ID,
+			ParkingSpot:   &spot,
+			Reason:        reason,
+			StartTime:     now,
+			EndTime:       endTime,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO spot_maintenances (parking_spot_id, reason, start_time, end_time, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id
+		`,
+			maintenance.ParkingSpotID,
+			maintenance.Reason,
+			maintenance.StartTime,
+			maintenance.EndTime,
+			now,
+			now,
+		).Scan(&maintenance.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		maintenances = append(maintenances, maintenance)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return maintenances, nil, nil
+}
+
+func (r *maintenanceRepo) EndMaintenance(ctx context.Context, selector domain.SpotSelector) (int64, error) {
+	defer metrics.ObserveQuery("end_maintenance")()
+
+	query := fmt.Sprintf(`
+		UPDATE spot_maintenances
+		SET end_time = $4, updated_at = $4
+		WHERE (end_time IS NULL OR end_time > $4)
+		AND parking_spot_id IN (
+			SELECT ps.id
+			FROM parking_spots ps
+			WHERE %s
+		)
+	`, selectorCondition)
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, selector.Floor, selector.Row, selector.Column, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *maintenanceRepo) GetActiveMaintenances(ctx context.Context) ([]domain.SpotMaintenance, error) {
+	defer metrics.ObserveQuery("get_active_maintenances")()
+
+	query := `
+		SELECT sm.id, sm.parking_spot_id, sm.reason, sm.start_time, sm.end_time, sm.created_at, sm.updated_at,
+			ps.id, ps.floor, ps.row, ps.column, ps.vehicle_type, ps.size_class, ps.is_active, ps.created_at, ps.updated_at
+		FROM spot_maintenances sm
+		JOIN parking_spots ps ON ps.id = sm.parking_spot_id
+		WHERE sm.start_time <= $1 AND (sm.end_time IS NULL OR sm.end_time > $1)
+		ORDER BY ps.floor, ps.row, ps.column
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var maintenances []domain.SpotMaintenance
+	for rows.Next() {
+		var (
+			maintenance domain.SpotMaintenance
+			spot        domain.ParkingSpot
+		)
+		err := rows.Scan(
+			&maintenance.ID,
+			&maintenance.ParkingSpotID,
+			&maintenance.Reason,
+			&maintenance.StartTime,
+			&maintenance.EndTime,
+			&maintenance.CreatedAt,
+			&maintenance.UpdatedAt,
+			&spot.ID,
+			&spot.Floor,
+			&spot.Row,
+			&spot.Column,
+			&spot.VehicleType,
+			&spot.SizeClass,
+			&spot.IsActive,
+			&spot.CreatedAt,
+			&spot.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		maintenance.ParkingSpot = &spot
+		maintenances = append(maintenances, maintenance)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return maintenances, nil
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"parking-lot/domain"
+)
+
+type memoryAPIKeyRepo struct {
+	keys      []domain.APIKey
+	nextKeyID int64
+	mutex     sync.RWMutex
+}
+
+// NewMemoryAPIKeyRepository returns an API key repository that keeps all keys in memory
+func NewMemoryAPIKeyRepository() domain.APIKeyRepository {
+	return &memoryAPIKeyRepo{
+		nextKeyID: 1,
+	}
+}
+
+func (r *memoryAPIKeyRepo) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key.ID = r.nextKeyID
+	key.CreatedAt = time.Now()
+	r.nextKeyID++
+	r.keys = append(r.keys, *key)
+
+	return nil
+}
+
+func (r *memoryAPIKeyRepo) GetAPIKeyByHash(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, key := range r.keys {
+		if key.KeyHash == keyHash {
+			return &key, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r *memoryAPIKeyRepo) GetAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	keys := make([]domain.APIKey, len(r.keys))
+	copy(keys, r.keys)
+	return keys, nil
+}
+
+func (r *memoryAPIKeyRepo) RevokeAPIKey(ctx context.Context, id int64, at time.Time) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := range r.keys {
+		if r.keys[i].ID == id && r.keys[i].RevokedAt == nil {
+			r.keys[i].RevokedAt = &at
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
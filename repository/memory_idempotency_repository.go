@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"parking-lot/domain"
+)
+
+type idempotencyKey struct {
+	apiKeyID int64
+	key      string
+}
+
+type memoryIdempotencyRepo struct {
+	records map[idempotencyKey]domain.IdempotencyRecord
+	mutex   sync.Mutex
+}
+
+// NewMemoryIdempotencyRepository returns an idempotency repository that keeps all records in memory
+func NewMemoryIdempotencyRepository() domain.IdempotencyRepository {
+	return &memoryIdempotencyRepo{
+		records: make(map[idempotencyKey]domain.IdempotencyRecord),
+	}
+}
+
+func (r *memoryIdempotencyRepo) ReserveIdempotencyKey(ctx context.Context, record *domain.IdempotencyRecord, now time.Time) (*domain.IdempotencyRecord, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for key, existing := range r.records {
+		if !existing.ExpiresAt.After(now) {
+			delete(r.records, key)
+		}
+	}
+
+	key := idempotencyKey{apiKeyID: record.APIKeyID, key: record.Key}
+	if existing, ok := r.records[key]; ok {
+		// an unfinished record of the same request is taken over once its lease expired
+		abandoned := existing.StatusCode == 0 && existing.RequestHash == record.RequestHash && !existing.LeaseExpiresAt.After(now)
+		if !abandoned {
+			return &existing, nil
+		}
+	}
+
+	r.records[key] = *record
+	return nil, nil
+}
+
+func (r *memoryIdempotencyRepo) CompleteIdempotencyKey(ctx context.Context, apiKeyID int64, key string, statusCode int, responseBody []byte) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	record, ok := r.records[idempotencyKey{apiKeyID: apiKeyID, key: key}]
+	if !ok {
+		return nil
+	}
+
+	record.StatusCode = statusCode
+	record.ResponseBody = responseBody
+	r.records[idempotencyKey{apiKeyID: apiKeyID, key: key}] = record
+
+	return nil
+}
+
+func (r *memoryIdempotencyRepo) DeleteIdempotencyKey(ctx context.Context, apiKeyID int64, key string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.records, idempotencyKey{apiKeyID: apiKeyID, key: key})
+	return nil
+}
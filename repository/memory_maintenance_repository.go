@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"parking-lot/domain"
+)
+
+// memoryMaintenanceRepo stores maintenances alongside the spots and records of a memory parking
+// repository, since closing spots and finding available spots need both
+type memoryMaintenanceRepo struct {
+	*memoryParkingRepo
+}
+
+// NewMemoryMaintenanceRepository returns a maintenance repository that keeps all data in memory,
+// sharing its store with the given repository created by NewMemoryParkingRepository
+func NewMemoryMaintenanceRepository(parkingRepo domain.ParkingRepository) domain.MaintenanceRepository {
+	return &memoryMaintenanceRepo{
+		memoryParkingRepo: parkingRepo.(*memoryParkingRepo),
+	}
+}
+
+func (r *memoryMaintenanceRepo) StartMaintenance(
+	ctx context.Context,
+	selector domain.SpotSelector,
+	reason string,
+	endTime *time.Time,
+	force bool,
+) ([]domain.SpotMaintenance, []domain.ParkingSpot, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	occupiedSpots := r.occupiedSpots()
+
+	var spots, occupied []domain.ParkingSpot
+	for _, spot := range r.spots {
+		if !spot.IsActive || !selector.Matches(spot) {
+			continue
+		}
+		spots = append(spots, spot)
+		if occupiedSpots[spot.ID] {
+			occupied = append(occupied, spot)
+		}
+	}
+	slices.SortFunc(spots, compareSpotPosition)
+	slices.SortFunc(occupied, compareSpotPosition)
+
+	if len(occupied) > 0 && !force {
+		return nil, occupied, nil
+	}
+
+	// a new maintenance replaces the one the spot is under now, if any
+	now := time.Now()
+	var maintenances []domain.SpotMaintenance
+	for _, spot := range spots {
+		r.endMaintenances(spot.ID, now)
+
+		maintenance := domain.SpotMaintenance{
+			ID:            r.nextMaintenanceID,
+			ParkingSpotID: spot.ID,
+			Reason:        reason,
+			StartTime:     now,
+			EndTime:       endTime,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		r.nextMaintenanceID++
+		r.maintenances = append(r.maintenances, maintenance)
+
+		maintenance.ParkingSpot = &spot
+		maintenances = append(maintenances, maintenance)
+	}
+
+	return maintenances, nil, nil
+}
+
+func (r *memoryMaintenanceRepo) EndMaintenance(ctx context.Context, selector domain.SpotSelector) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	var ended int64
+	for _, spot := range r.spots {
+		if selector.Matches(spot) {
+			ended += r.endMaintenances(spot.ID, now)
+		}
+	}
+
+	return ended, nil
+}
+
+func (r *memoryMaintenanceRepo) GetActiveMaintenances(ctx context.Context) ([]domain.SpotMaintenance, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	spots := make(map[int64]domain.ParkingSpot, len(r.spots))
+	for _, spot := range r.spots {
+		spots[spot.ID] = spot
+	}
+
+	now := time.Now()
+	var maintenances []domain.SpotMaintenance
+	for _, maintenance := range r.maintenances {
+		if maintenance.StartTime.After(now) || (maintenance.EndTime != nil && !maintenance.EndTime.After(now)) {
+			continue
+		}
+		spot := spots[maintenance.ParkingSpotID]
+		maintenance.ParkingSpot = &spot
+		maintenances = append(maintenances, maintenance)
+	}
+
+	slices.SortFunc(maintenances, func(a, b domain.SpotMaintenance) int {
+		return compareSpotPosition(*a.ParkingSpot, *b.ParkingSpot)
+	})
+
+	return maintenances, nil
+}
+
+// endMaintenances ends the maintenances of the spot that have not ended at the given time yet and
+// returns how many were ended. The caller must hold the mutex.
+func (r *memoryMaintenanceRepo) endMaintenances(spotID int64, at time.Time) int64 {
+	var ended int64
+	for i := range r.maintenances {
+		maintenance := &r.maintenances[i]
+		if maintenance.ParkingSpotID != spotID || (maintenance.EndTime != nil && !maintenance.EndTime.After(at)) {
+			continue
+		}
+		maintenance.EndTime = &at
+		maintenance.UpdatedAt = at
+		ended++
+	}
+	return ended
+}
@@ -0,0 +1,451 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"parking-lot/domain"
+)
+
+type memoryParkingRepo struct {
+	spots        []domain.ParkingSpot
+	records      []domain.ParkingRecord
+	nextRecordID int64
+	// vehicles are managed by the memory vehicle repository sharing this store, plates maps license
+	// plates to vehicle IDs and leaves out vehicles without a license plate
+	vehicles      map[int64]domain.Vehicle
+	plates        map[string]int64
+	nextVehicleID int64
+	// reservations are managed by the memory reservation repository sharing this store
+	reservations      []domain.Reservation
+	nextReservationID int64
+	// maintenances are managed by the memory maintenance repository sharing this store
+	maintenances      []domain.SpotMaintenance
+	nextMaintenanceID int64
+	mutex             *sync.RWMutex
+}
+
+// NewMemoryParkingRepository returns a parking repository that keeps all data in memory.
+// It starts without spots, use the layout service to create them.
+func NewMemoryParkingRepository() domain.ParkingRepository {
+	return &memoryParkingRepo{
+		nextRecordID:      1,
+		vehicles:          make(map[int64]domain.Vehicle),
+		plates:            make(map[string]int64),
+		nextVehicleID:     1,
+		nextReservationID: 1,
+		nextMaintenanceID: 1,
+		mutex:             &sync.RWMutex{},
+	}
+}
+
+func (r *memoryParkingRepo) GetAvailableSpots(ctx context.Context, filter domain.SpotFilter) ([]domain.ParkingSpot, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.availableSpots(filter), nil
+}
+
+func (r *memoryParkingRepo) GetSpotByID(ctx context.Context, id int64) (*domain.ParkingSpot, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, spot := range r.spots {
+		if spot.ID == id {
+			return &spot, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r *memoryParkingRepo) GetSpotByPosition(ctx context.Context, floor, row, column int) (*domain.ParkingSpot, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, spot := range r.spots {
+		if spot.Floor == floor && spot.Row == row && spot.Column == column {
+			return &spot, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r *memoryParkingRepo) UpdateSpotStatus(ctx context.Context, id int64, isActive bool) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := range r.spots {
+		if r.spots[i].ID == id {
+			r.spots[i].IsActive = isActive
+			r.spots[i].UpdatedAt = time.Now()
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryParkingRepo) UpdateSpotType(ctx context.Context, id int64, vehicleType domain.VehicleType, sizeClass domain.SizeClass) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := range r.spots {
+		if r.spots[i].ID == id {
+			r.spots[i].VehicleType = vehicleType
+			r.spots[i].SizeClass = sizeClass
+			r.spots[i].UpdatedAt = time.Now()
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryParkingRepo) CreateParkingRecord(ctx context.Context, record *domain.ParkingRecord) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.insertRecord(record)
+	return nil
+}
+
+func (r *memoryParkingRepo) UpdateParkingRecord(ctx context.Context, record *domain.ParkingRecord) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := range r.records {
+		if r.records[i].ID != record.ID {
+			continue
+		}
+		if !r.records[i].IsParked() {
+			// a concurrent request unparked the vehicle since the record was read
+			return domain.ErrVehicleNotParked
+		}
+
+		r.records[i].ExitTime = record.ExitTime
+		r.records[i].Fee = record.Fee
+		r.records[i].ExitGateID = record.ExitGateID
+		r.records[i].UpdatedAt = time.Now()
+		return nil
+	}
+
+	return domain.ErrVehicleNotParked
+}
+
+func (r *memoryParkingRepo) GetLastParkingRecordByVehicleID(ctx context.Context, vehicleID int64) (*domain.ParkingRecord, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var last *domain.ParkingRecord
+	for i := range r.records {
+		record := r.records[i]
+		if record.VehicleID != vehicleID {
+			continue
+		}
+		if last == nil || !record.EntryTime.Before(last.EntryTime) {
+			last = &record
+		}
+	}
+
+	return last, nil
+}
+
+func (r *memoryParkingRepo) GetParkingRecordsByVehicleID(ctx context.Context, vehicleID int64, filter domain.HistoryFilter) ([]domain.ParkingRecord, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var records []domain.ParkingRecord
+	for _, record := range r.records {
+		if record.VehicleID != vehicleID {
+			continue
+		}
+		if filter.From != nil && record.EntryTime.Before(*filter.From) {
+			continue
+		}
+		if filter.To != nil && !record.EntryTime.Before(*filter.To) {
+			continue
+		}
+		if filter.After != nil && compareHistoryPosition(record, filter.After.EntryTime, filter.After.RecordID) >= 0 {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	slices.SortFunc(records, func(a, b domain.ParkingRecord) int {
+		return -compareHistoryPosition(a, b.EntryTime, b.ID)
+	})
+
+	if len(records) > filter.Limit {
+		records = records[:filter.Limit]
+	}
+
+	return records, nil
+}
+
+// compareHistoryPosition orders a record against an entry time and record ID, oldest first
+func compareHistoryPosition(record domain.ParkingRecord, entryTime time.Time, id int64) int {
+	if c := record.EntryTime.Compare(entryTime); c != 0 {
+		return c
+	}
+	return cmp.Compare(record.ID, id)
+}
+
+func (r *memoryParkingRepo) GetSpotChanges(ctx context.Context, since time.Time) ([]domain.SpotChange, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var changes []domain.SpotChange
+	for _, record := range r.records {
+		if record.UpdatedAt.After(since) {
+			changes = append(changes, domain.SpotChange{
+				RecordID:  record.ID,
+				SpotID:    record.ParkingSpotID,
+				Freed:     !record.IsParked(),
+				ChangedAt: record.UpdatedAt,
+				TakenAt:   record.CreatedAt,
+			})
+		}
+	}
+	slices.SortFunc(changes, func(a, b domain.SpotChange) int {
+		return cmp.Or(a.ChangedAt.Compare(b.ChangedAt), cmp.Compare(a.RecordID, b.RecordID))
+	})
+
+	return changes, nil
+}
+
+func (r *memoryParkingRepo) GetParkingRecordByTicketCode(ctx context.Context, ticketCode string) (*domain.ParkingRecord, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, record := range r.records {
+		if record.TicketCode != "" && record.TicketCode == ticketCode {
+			return &record, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r *memoryParkingRepo) ClaimSpot(ctx context.Context, vehicle *domain.Vehicle, record *domain.ParkingRecord, candidateIDs []int64) (*domain.ParkingSpot, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.isParked(vehicle) {
+		return nil, domain.ErrVehicleAlreadyParked
+	}
+
+	available := make(map[int64]domain.ParkingSpot)
+	for _, spot := range r.availableSpots(domain.SpotFilter{}) {
+		available[spot.ID] = spot
+	}
+
+	for _, id := range candidateIDs {
+		spot, ok := available[id]
+		if !ok {
+			continue
+		}
+
+		err := r.insertVehicleIfNew(ctx, vehicle, record)
+		if err != nil {
+			return nil, err
+		}
+
+		record.ParkingSpotID = spot.ID
+		r.insertRecord(record)
+
+		return &spot, nil
+	}
+
+	return nil, nil
+}
+
+func (r *memoryParkingRepo) GetAllSpots(ctx context.Context) ([]domain.ParkingSpot, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	spots := slices.Clone(r.spots)
+	slices.SortFunc(spots, compareSpotPosition)
+
+	return spots, nil
+}
+
+func (r *memoryParkingRepo) CountActiveSpots(ctx context.Context) (int64, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var count int64
+	for _, spot := range r.spots {
+		if spot.IsActive {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (r *memoryParkingRepo) CreateSpot(ctx context.Context, spot *domain.ParkingSpot) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.spots {
+		if existing.Floor == spot.Floor && existing.Row == spot.Row && existing.Column == spot.Column {
+			return fmt.Errorf("parking spot %d-%d-%d already exists", spot.Floor, spot.Row, spot.Column)
+		}
+	}
+
+	now := time.Now()
+	spot.ID = int64(len(r.spots) + 1)
+	spot.CreatedAt = now
+	spot.UpdatedAt = now
+
+	r.spots = append(r.spots, *spot)
+
+	return nil
+}
+
+func (r *memoryParkingRepo) DeactivateSpotIfFree(ctx context.Context, id int64) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, record := range r.records {
+		if record.ParkingSpotID == id && record.IsParked() {
+			return false, nil
+		}
+	}
+
+	for i := range r.spots {
+		if r.spots[i].ID == id {
+			r.spots[i].IsActive = false
+			r.spots[i].UpdatedAt = time.Now()
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// availableSpots returns active spots without an open parking record, a reservation holding them now or
+// a maintenance closing them now, ordered by floor, row and column. The caller must hold the mutex.
+func (r *memoryParkingRepo) availableSpots(filter domain.SpotFilter) []domain.ParkingSpot {
+	occupied := r.occupiedSpots()
+
+	now := time.Now()
+	for _, reservation := range r.reservations {
+		if reservation.HoldsSpotAt(now) {
+			occupied[reservation.ParkingSpotID] = true
+		}
+	}
+	for spotID := range r.spotsUnderMaintenance(now, now) {
+		occupied[spotID] = true
+	}
+
+	var spots []domain.ParkingSpot
+	for _, spot := range r.spots {
+		if !spot.IsActive || occupied[spot.ID] || !matchesSpotFilter(spot, filter) {
+			continue
+		}
+		spots = append(spots, spot)
+	}
+
+	slices.SortFunc(spots, compareSpotPosition)
+
+	return spots
+}
+
+// occupiedSpots returns the IDs of spots with an open parking record. The caller must hold the mutex.
+func (r *memoryParkingRepo) occupiedSpots() map[int64]bool {
+	occupied := make(map[int64]bool)
+	for _, record := range r.records {
+		if record.IsParked() {
+			occupied[record.ParkingSpotID] = true
+		}
+	}
+	return occupied
+}
+
+// spotsUnderMaintenance returns the IDs of spots closed for maintenance at any time from start to end,
+// a single point in time when both are equal. The caller must hold the mutex.
+func (r *memoryParkingRepo) spotsUnderMaintenance(start, end time.Time) map[int64]bool {
+	closed := make(map[int64]bool)
+	for _, maintenance := range r.maintenances {
+		startsInTime := maintenance.StartTime.Before(end)
+		if start.Equal(end) {
+			startsInTime = !maintenance.StartTime.After(end)
+		}
+		endsInTime := maintenance.EndTime == nil || maintenance.EndTime.After(start)
+
+		if startsInTime && endsInTime {
+			closed[maintenance.ParkingSpotID] = true
+		}
+	}
+	return closed
+}
+
+// isParked reports whether the vehicle has an open parking record, or a new vehicle's license plate
+// was taken by a vehicle created concurrently. The caller must hold the mutex.
+func (r *memoryParkingRepo) isParked(vehicle *domain.Vehicle) bool {
+	if vehicle.ID == 0 {
+		_, ok := r.plates[vehicle.LicensePlate]
+		return vehicle.LicensePlate != "" && ok
+	}
+
+	for _, existing := range r.records {
+		if existing.VehicleID == vehicle.ID && existing.IsParked() {
+			return true
+		}
+	}
+	return false
+}
+
+// insertVehicleIfNew creates the vehicle if it has no ID yet and assigns the vehicle to the record.
+// The caller must hold the mutex.
+func (r *memoryParkingRepo) insertVehicleIfNew(ctx context.Context, vehicle *domain.Vehicle, record *domain.ParkingRecord) error {
+	if vehicle.ID == 0 {
+		if _, ok := r.plates[vehicle.LicensePlate]; ok {
+			// a concurrent request created the vehicle since it was looked up, it is parking it as well
+			return domain.ErrVehicleAlreadyParked
+		}
+
+		err := r.insertVehicle(vehicle)
+		if err != nil {
+			return err
+		}
+	}
+
+	record.VehicleID = vehicle.ID
+	return nil
+}
+
+// insertRecord assigns an ID and timestamps to the record and stores a copy of it.
+// The caller must hold the mutex.
+func (r *memoryParkingRepo) insertRecord(record *domain.ParkingRecord) {
+	now := time.Now()
+	record.ID = r.nextRecordID
+	record.CreatedAt = now
+	record.UpdatedAt = now
+	r.nextRecordID++
+
+	r.records = append(r.records, *record)
+}
+
+// matchesSpotFilter reports whether the spot has the filter's vehicle type and one of its size classes
+func matchesSpotFilter(spot domain.ParkingSpot, filter domain.SpotFilter) bool {
+	if filter.VehicleType != "" && spot.VehicleType != filter.VehicleType {
+		return false
+	}
+	return len(filter.SizeClasses) == 0 || slices.Contains(filter.SizeClasses, spot.SizeClass)
+}
+
+func compareSpotPosition(a, b domain.ParkingSpot) int {
+	if a.Floor != b.Floor {
+		return a.Floor - b.Floor
+	}
+	if a.Row != b.Row {
+		return a.Row - b.Row
+	}
+	return a.Column - b.Column
+}
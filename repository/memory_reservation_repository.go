@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"parking-lot/domain"
+)
+
+// memoryReservationRepo stores reservations alongside the spots and records of a memory parking
+// repository, since claiming a reservation and finding available spots need both
+type memoryReservationRepo struct {
+	*memoryParkingRepo
+}
+
+// NewMemoryReservationRepository returns a reservation repository that keeps all data in memory,
+// sharing its store with the given repository created by NewMemoryParkingRepository
+func NewMemoryReservationRepository(parkingRepo domain.ParkingRepository) domain.ReservationRepository {
+	return &memoryReservationRepo{
+		memoryParkingRepo: parkingRepo.(*memoryParkingRepo),
+	}
+}
+
+func (r *memoryReservationRepo) CreateReservation(ctx context.Context, reservation *domain.Reservation, filter domain.SpotFilter) (*domain.ParkingSpot, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	occupied := r.occupiedSpots()
+
+	// the spot must be free for as long as the reservation holds it, as must be those of other reservations
+	reserved := make(map[int64]bool)
+	for _, existing := range r.reservations {
+		if existing.Status == domain.ReservationActive && existing.HeldFrom.Before(reservation.EndTime) && existing.EndTime.After(reservation.HeldFrom) {
+			reserved[existing.ParkingSpotID] = true
+		}
+	}
+	for spotID := range r.spotsUnderMaintenance(reservation.HeldFrom, reservation.EndTime) {
+		reserved[spotID] = true
+	}
+
+	var spots []domain.ParkingSpot
+	for _, spot := range r.spots {
+		if !spot.IsActive || occupied[spot.ID] || reserved[spot.ID] || !matchesSpotFilter(spot, filter) {
+			continue
+		}
+		spots = append(spots, spot)
+	}
+
+	if len(spots) == 0 {
+		return nil, nil
+	}
+	slices.SortFunc(spots, compareSpotPosition)
+
+	now := time.Now()
+	reservation.ID = r.nextReservationID
+	reservation.ParkingSpotID = spots[0].ID
+	reservation.Status = domain.ReservationActive
+	reservation.CreatedAt = now
+	reservation.UpdatedAt = now
+	r.nextReservationID++
+
+	r.reservations = append(r.reservations, *reservation)
+
+	return &spots[0], nil
+}
+
+func (r *memoryReservationRepo) GetReservationByID(ctx context.Context, id int64) (*domain.Reservation, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, reservation := range r.reservations {
+		if reservation.ID == id {
+			return &reservation, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r *memoryReservationRepo) GetReservationsByLicensePlate(ctx context.Context, licensePlate string) ([]domain.Reservation, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var reservations []domain.Reservation
+	for _, reservation := range r.reservations {
+		if reservation.LicensePlate == licensePlate {
+			reservations = append(reservations, reservation)
+		}
+	}
+
+	slices.SortFunc(reservations, func(a, b domain.Reservation) int {
+		return b.StartTime.Compare(a.StartTime)
+	})
+
+	return reservations, nil
+}
+
+func (r *memoryReservationRepo) UpdateReservationStatus(ctx context.Context, id int64, status domain.ReservationStatus) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := range r.reservations {
+		if r.reservations[i].ID == id && r.reservations[i].Status == domain.ReservationActive {
+			r.reservations[i].Status = status
+			r.reservations[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+
+	return domain.ErrReservationNotActive
+}
+
+func (r *memoryReservationRepo) ClaimReservation(ctx context.Context, vehicle *domain.Vehicle, record *domain.ParkingRecord, reservation *domain.Reservation) (*domain.ParkingSpot, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	index := slices.IndexFunc(r.reservations, func(existing domain.Reservation) bool {
+		return existing.ID == reservation.ID
+	})
+	if index < 0 || r.reservations[index].Status != domain.ReservationActive {
+		// cancelled or expired since it was looked up, the vehicle parks like any other
+		return nil, domain.ErrSpotUnavailable
+	}
+
+	spotIndex := slices.IndexFunc(r.spots, func(spot domain.ParkingSpot) bool {
+		return spot.ID == reservation.ParkingSpotID
+	})
+	if spotIndex < 0 || !r.spots[spotIndex].IsActive || r.occupiedSpots()[reservation.ParkingSpotID] ||
+		r.spotsUnderMaintenance(record.EntryTime, record.EntryTime)[reservation.ParkingSpotID] {
+		return nil, domain.ErrSpotUnavailable
+	}
+
+	if r.isParked(vehicle) {
+		return nil, domain.ErrVehicleAlreadyParked
+	}
+
+	err := r.insertVehicleIfNew(ctx, vehicle, record)
+	if err != nil {
+		return nil, err
+	}
+
+	record.ParkingSpotID = reservation.ParkingSpotID
+	r.insertRecord(record)
+
+	r.reservations[index].Status = domain.ReservationClaimed
+	r.reservations[index].UpdatedAt = time.Now()
+	reservation.Status = domain.ReservationClaimed
+
+	spot := r.spots[spotIndex]
+	return &spot, nil
+}
+
+func (r *memoryReservationRepo) ExpireReservations(ctx context.Context, startedBefore time.Time) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var expired int64
+	for i := range r.reservations {
+		if r.reservations[i].Status == domain.ReservationActive && r.reservations[i].StartTime.Before(startedBefore) {
+			r.reservations[i].Status = domain.ReservationExpired
+			r.reservations[i].UpdatedAt = time.Now()
+			expired++
+		}
+	}
+
+	return expired, nil
+}
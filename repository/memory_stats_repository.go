@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"parking-lot/domain"
+)
+
+// memoryStatsRepo computes statistics from the store of a memory parking repository
+type memoryStatsRepo struct {
+	*memoryParkingRepo
+}
+
+// NewMemoryStatsRepository returns a stats repository reading the data of the given repository
+// created by NewMemoryParkingRepository
+func NewMemoryStatsRepository(parkingRepo domain.ParkingRepository) domain.StatsRepository {
+	return &memoryStatsRepo{
+		memoryParkingRepo: parkingRepo.(*memoryParkingRepo),
+	}
+}
+
+func (r *memoryStatsRepo) GetOccupancyCounts(ctx context.Context, at time.Time) ([]domain.OccupancyCount, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	occupied := r.occupiedSpots()
+	closed := r.spotsUnderMaintenance(at, at)
+	reserved := make(map[int64]bool)
+	for _, reservation := range r.reservations {
+		if reservation.HoldsSpotAt(at) {
+			reserved[reservation.ParkingSpotID] = true
+		}
+	}
+
+	type group struct {
+		floor       int
+		vehicleType domain.VehicleType
+	}
+	countsByGroup := make(map[group]*domain.OccupancyCount)
+	for _, spot := range r.spots {
+		key := group{spot.Floor, spot.VehicleType}
+		count, ok := countsByGroup[key]
+		if !ok {
+			count = &domain.OccupancyCount{Floor: spot.Floor, VehicleType: spot.VehicleType}
+			countsByGroup[key] = count
+		}
+
+		count.Total++
+		switch {
+		case occupied[spot.ID]:
+			count.Occupied++
+		case !spot.IsActive || closed[spot.ID]:
+			count.Inactive++
+		case reserved[spot.ID]:
+			count.Reserved++
+		default:
+			count.Free++
+		}
+	}
+
+	counts := make([]domain.OccupancyCount, 0, len(countsByGroup))
+	for _, count := range countsByGroup {
+		counts = append(counts, *count)
+	}
+	slices.SortFunc(counts, func(a, b domain.OccupancyCount) int {
+		if c := cmp.Compare(a.Floor, b.Floor); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.VehicleType, b.VehicleType)
+	})
+
+	return counts, nil
+}
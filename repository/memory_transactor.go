@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+
+	"parking-lot/domain"
+)
+
+type memoryTransactor struct{}
+
+// NewMemoryTransactor returns a transactor for the memory repositories. Every memory repository operation
+// is atomic on its own, operations run by the transactor are not rolled back when a later one fails.
+func NewMemoryTransactor() domain.Transactor {
+	return memoryTransactor{}
+}
+
+func (memoryTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
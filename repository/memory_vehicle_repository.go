@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"parking-lot/domain"
+)
+
+// memoryVehicleRepo stores vehicles alongside the spots and records of a memory parking repository,
+// since claiming a spot creates new vehicles together with their parking record
+type memoryVehicleRepo struct {
+	*memoryParkingRepo
+}
+
+// NewMemoryVehicleRepository returns a vehicle repository that keeps all data in memory,
+// sharing its store with the given repository created by NewMemoryParkingRepository
+func NewMemoryVehicleRepository(parkingRepo domain.ParkingRepository) domain.VehicleRepository {
+	return &memoryVehicleRepo{
+		memoryParkingRepo: parkingRepo.(*memoryParkingRepo),
+	}
+}
+
+func (r *memoryVehicleRepo) GetVehicleByID(ctx context.Context, id int64) (*domain.Vehicle, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	vehicle, ok := r.vehicles[id]
+	if !ok {
+		return nil, nil
+	}
+
+	return &vehicle, nil
+}
+
+func (r *memoryVehicleRepo) GetVehicleByLicensePlate(ctx context.Context, licensePlate string) (*domain.Vehicle, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	id, ok := r.plates[licensePlate]
+	if !ok {
+		return nil, nil
+	}
+
+	vehicle := r.vehicles[id]
+	return &vehicle, nil
+}
+
+func (r *memoryVehicleRepo) CreateVehicle(ctx context.Context, vehicle *domain.Vehicle) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.insertVehicle(vehicle)
+}
+
+// insertVehicle assigns an ID and timestamps to the vehicle and stores a copy of it.
+// The caller must hold the mutex.
+func (r *memoryParkingRepo) insertVehicle(vehicle *domain.Vehicle) error {
+	if vehicle.LicensePlate != "" {
+		if _, ok := r.plates[vehicle.LicensePlate]; ok {
+			return fmt.Errorf("vehicle with license plate %s already exists", vehicle.LicensePlate)
+		}
+	}
+
+	now := time.Now()
+	vehicle.ID = r.nextVehicleID
+	vehicle.CreatedAt = now
+	vehicle.UpdatedAt = now
+	r.nextVehicleID++
+
+	r.vehicles[vehicle.ID] = *vehicle
+	if vehicle.LicensePlate != "" {
+		r.plates[vehicle.LicensePlate] = vehicle.ID
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"parking-lot/domain"
+)
+
+type memoryWebhookRepo struct {
+	webhooks       []domain.Webhook
+	deliveries     []domain.WebhookDelivery
+	nextWebhookID  int64
+	nextDeliveryID int64
+	mutex          sync.Mutex
+}
+
+// NewMemoryWebhookRepository returns a webhook repository that keeps webhooks and their deliveries in memory
+func NewMemoryWebhookRepository() domain.WebhookRepository {
+	return &memoryWebhookRepo{
+		nextWebhookID:  1,
+		nextDeliveryID: 1,
+	}
+}
+
+func (r *memoryWebhookRepo) CreateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	webhook.ID = r.nextWebhookID
+	webhook.CreatedAt = time.Now()
+	r.nextWebhookID++
+	r.webhooks = append(r.webhooks, *webhook)
+
+	return nil
+}
+
+func (r *memoryWebhookRepo) GetWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return slices.Clone(r.webhooks), nil
+}
+
+func (r *memoryWebhookRepo) DeleteWebhook(ctx context.Context, id int64) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	found := false
+	r.webhooks = slices.DeleteFunc(r.webhooks, func(webhook domain.Webhook) bool {
+		found = found || webhook.ID == id
+		return webhook.ID == id
+	})
+	r.deliveries = slices.DeleteFunc(r.deliveries, func(delivery domain.WebhookDelivery) bool {
+		return delivery.WebhookID == id
+	})
+
+	return found, nil
+}
+
+func (r *memoryWebhookRepo) EnqueueEvent(ctx context.Context, event domain.Event, payload []byte) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, webhook := range r.webhooks {
+		if !slices.Contains(webhook.EventTypes, event.Type) {
+			continue
+		}
+
+		r.deliveries = append(r.deliveries, domain.WebhookDelivery{
+			ID:            r.nextDeliveryID,
+			WebhookID:     webhook.ID,
+			URL:           webhook.URL,
+			Secret:        webhook.Secret,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       payload,
+			Status:        domain.DeliveryPending,
+			NextAttemptAt: event.OccurredAt,
+			CreatedAt:     event.OccurredAt,
+			UpdatedAt:     event.OccurredAt,
+		})
+		r.nextDeliveryID++
+	}
+
+	return nil
+}
+
+func (r *memoryWebhookRepo) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.WebhookDelivery, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var deliveries []domain.WebhookDelivery
+	for i := range r.deliveries {
+		delivery := &r.deliveries[i]
+		if delivery.Status != domain.DeliveryPending || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+
+		delivery.NextAttemptAt = now.Add(lease)
+		deliveries = append(deliveries, *delivery)
+		if len(deliveries) == limit {
+			break
+		}
+	}
+
+	return deliveries, nil
+}
+
+func (r *memoryWebhookRepo) UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delivery.UpdatedAt = time.Now()
+	for i := range r.deliveries {
+		if r.deliveries[i].ID == delivery.ID {
+			r.deliveries[i] = *delivery
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryWebhookRepo) GetDeliveriesByStatus(ctx context.Context, status domain.DeliveryStatus) ([]domain.WebhookDelivery, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var deliveries []domain.WebhookDelivery
+	for _, delivery := range r.deliveries {
+		if delivery.Status == status {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+
+	return deliveries, nil
+}
+
+func (r *memoryWebhookRepo) RetryDeadDelivery(ctx context.Context, id int64, now time.Time) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := range r.deliveries {
+		delivery := &r.deliveries[i]
+		if delivery.ID == id && delivery.Status == domain.DeliveryDead {
+			delivery.Status = domain.DeliveryPending
+			delivery.Attempts = 0
+			delivery.NextAttemptAt = now
+			delivery.UpdatedAt = now
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *memoryWebhookRepo) DeleteDeliveries(ctx context.Context, status domain.DeliveryStatus, updatedBefore time.Time) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	count := len(r.deliveries)
+	r.deliveries = slices.DeleteFunc(r.deliveries, func(delivery domain.WebhookDelivery) bool {
+		return delivery.Status == status && delivery.UpdatedAt.Before(updatedBefore)
+	})
+
+	return int64(count - len(r.deliveries)), nil
+}
@@ -1,39 +1,51 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
 	"parking-lot/domain"
+	"parking-lot/metrics"
+)
+
+// maxClaimAttempts bounds how many times ClaimSpot retries when another
+// transaction wins the race for the same spot
+const maxClaimAttempts = 5
+
+const (
+	activeSpotIndex    = "parking_records_active_spot_idx"
+	activeVehicleIndex = "parking_records_active_vehicle_idx"
+	vehiclePlateIndex  = "vehicles_license_plate_key"
 )
 
 type parkingRepo struct {
-	db    *sql.DB
-	mutex *sync.RWMutex
+	db *sql.DB
+	// spotLockClause is appended to the spot selection in ClaimSpot to lock the chosen row
+	spotLockClause string
+	// isUniqueViolation reports whether err violates the given unique index
+	isUniqueViolation func(err error, index string) bool
 }
 
 func NewParkingRepository(db *sql.DB) domain.ParkingRepository {
 	return &parkingRepo{
-		db:    db,
-		mutex: &sync.RWMutex{},
+		db:                db,
+		spotLockClause:    "FOR UPDATE OF ps SKIP LOCKED",
+		isUniqueViolation: isPostgresUniqueViolation,
 	}
 }
 
-func (r *parkingRepo) GetAvailableSpots(floors ...int) ([]domain.ParkingSpot, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+func (r *parkingRepo) GetAvailableSpots(ctx context.Context, filter domain.SpotFilter) ([]domain.ParkingSpot, error) {
+	defer metrics.ObserveQuery("get_available_spots")()
 
-	floorWhere := ""
-	if len(floors) > 0 {
-		floorWhere = "AND ps.floor = ANY($1)"
-	}
+	filterWhere, filterArgs := spotFilter(filter, 2)
 
 	query := fmt.Sprintf(`
-		SELECT ps.id, ps.floor, ps.row, ps.column, ps.is_active, ps.created_at, ps.updated_at
+		SELECT ps.id, ps.floor, ps.row, ps.column, ps.vehicle_type, ps.size_class, ps.is_active, ps.created_at, ps.updated_at
 		FROM parking_spots ps
 		LEFT JOIN (
 			SELECT parking_spot_id
@@ -41,14 +53,13 @@ func (r *parkingRepo) GetAvailableSpots(floors ...int) ([]domain.ParkingSpot, er
 			WHERE exit_time IS NULL
 		) pr ON ps.id = pr.parking_spot_id
 		WHERE ps.is_active = true AND pr.parking_spot_id IS NULL %s
+		AND NOT EXISTS (%s)
+		AND NOT EXISTS (%s)
 		ORDER BY ps.floor, ps.row, ps.column
-	`, floorWhere)
+	`, filterWhere, reservedNowQuery, underMaintenanceQuery)
 
-	var args []any
-	if floors != nil && len(floors) > 0 {
-		args = append(args, pq.Array(floors))
-	}
-	rows, err := r.db.Query(query, args...)
+	args := append([]any{time.Now()}, filterArgs...)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -62,6 +73,8 @@ func (r *parkingRepo) GetAvailableSpots(floors ...int) ([]domain.ParkingSpot, er
 			&spot.Floor,
 			&spot.Row,
 			&spot.Column,
+			&spot.VehicleType,
+			&spot.SizeClass,
 			&spot.IsActive,
 			&spot.CreatedAt,
 			&spot.UpdatedAt,
@@ -79,22 +92,23 @@ func (r *parkingRepo) GetAvailableSpots(floors ...int) ([]domain.ParkingSpot, er
 	return spots, nil
 }
 
-func (r *parkingRepo) GetSpotByID(id int64) (*domain.ParkingSpot, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+func (r *parkingRepo) GetSpotByID(ctx context.Context, id int64) (*domain.ParkingSpot, error) {
+	defer metrics.ObserveQuery("get_spot_by_id")()
 
 	query := `
-		SELECT id, floor, row, "column", is_active, created_at, updated_at
+		SELECT id, floor, row, "column", vehicle_type, size_class, is_active, created_at, updated_at
 		FROM parking_spots
 		WHERE id = $1
 	`
 
 	var spot domain.ParkingSpot
-	err := r.db.QueryRow(query, id).Scan(
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
 		&spot.ID,
 		&spot.Floor,
 		&spot.Row,
 		&spot.Column,
+		&spot.VehicleType,
+		&spot.SizeClass,
 		&spot.IsActive,
 		&spot.CreatedAt,
 		&spot.UpdatedAt,
@@ -109,22 +123,23 @@ func (r *parkingRepo) GetSpotByID(id int64) (*domain.ParkingSpot, error) {
 	return &spot, nil
 }
 
-func (r *parkingRepo) GetSpotByPosition(floor, row, column int) (*domain.ParkingSpot, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+func (r *parkingRepo) GetSpotByPosition(ctx context.Context, floor, row, column int) (*domain.ParkingSpot, error) {
+	defer metrics.ObserveQuery("get_spot_by_position")()
 
 	query := `
-		SELECT id, floor, row, "column", is_active, created_at, updated_at
+		SELECT id, floor, row, "column", vehicle_type, size_class, is_active, created_at, updated_at
 		FROM parking_spots
 		WHERE floor = $1 AND row = $2 AND "column" = $3
 	`
 
 	var spot domain.ParkingSpot
-	err := r.db.QueryRow(query, floor, row, column).Scan(
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, floor, row, column).Scan(
 		&spot.ID,
 		&spot.Floor,
 		&spot.Row,
 		&spot.Column,
+		&spot.VehicleType,
+		&spot.SizeClass,
 		&spot.IsActive,
 		&spot.CreatedAt,
 		&spot.UpdatedAt,
@@ -139,9 +154,8 @@ func (r *parkingRepo) GetSpotByPosition(floor, row, column int) (*domain.Parking
 	return &spot, nil
 }
 
-func (r *parkingRepo) UpdateSpotStatus(id int64, isActive bool) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+func (r *parkingRepo) UpdateSpotStatus(ctx context.Context, id int64, isActive bool) error {
+	defer metrics.ObserveQuery("update_spot_status")()
 
 	query := `
 		UPDATE parking_spots
@@ -149,26 +163,40 @@ func (r *parkingRepo) UpdateSpotStatus(id int64, isActive bool) error {
 		WHERE id = $3
 	`
 
-	_, err := r.db.Exec(query, isActive, time.Now(), id)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, isActive, time.Now(), id)
 	return err
 }
 
-func (r *parkingRepo) CreateParkingRecord(record *domain.ParkingRecord) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+func (r *parkingRepo) UpdateSpotType(ctx context.Context, id int64, vehicleType domain.VehicleType, sizeClass domain.SizeClass) error {
+	defer metrics.ObserveQuery("update_spot_type")()
 
 	query := `
-		INSERT INTO parking_records (vehicle_id, parking_spot_id, entry_time, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5)
+		UPDATE parking_spots
+		SET vehicle_type = $1, size_class = $2, updated_at = $3
+		WHERE id = $4
+	`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, vehicleType, sizeClass, time.Now(), id)
+	return err
+}
+
+func (r *parkingRepo) CreateParkingRecord(ctx context.Context, record *domain.ParkingRecord) error {
+	defer metrics.ObserveQuery("create_parking_record")()
+
+	query := `
+		INSERT INTO parking_records (vehicle_id, parking_spot_id, entry_time, ticket_code, entry_gate_id, created_at, updated_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7)
 		RETURNING id
 	`
 
 	now := time.Now()
-	err := r.db.QueryRow(
+	err := conn(ctx, r.db).QueryRowContext(ctx,
 		query,
 		record.VehicleID,
 		record.ParkingSpotID,
 		record.EntryTime,
+		record.TicketCode,
+		record.EntryGateID,
 		now,
 		now,
 	).Scan(&record.ID)
@@ -176,26 +204,36 @@ func (r *parkingRepo) CreateParkingRecord(record *domain.ParkingRecord) error {
 	return err
 }
 
-func (r *parkingRepo) UpdateParkingRecord(record *domain.ParkingRecord) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+func (r *parkingRepo) UpdateParkingRecord(ctx context.Context, record *domain.ParkingRecord) error {
+	defer metrics.ObserveQuery("update_parking_record")()
 
 	query := `
 		UPDATE parking_records
-		SET exit_time = $1, updated_at = $2
-		WHERE id = $3
+		SET exit_time = $1, fee = $2, exit_gate_id = $3, updated_at = $4
+		WHERE id = $5 AND exit_time IS NULL
 	`
 
-	_, err := r.db.Exec(query, record.ExitTime, time.Now(), record.ID)
-	return err
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, record.ExitTime, record.Fee, record.ExitGateID, time.Now(), record.ID)
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		// a concurrent request unparked the vehicle since the record was read
+		return domain.ErrVehicleNotParked
+	}
+	return nil
 }
 
-func (r *parkingRepo) GetLastParkingRecordByVehicleID(vehicleID int64) (*domain.ParkingRecord, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+func (r *parkingRepo) GetLastParkingRecordByVehicleID(ctx context.Context, vehicleID int64) (*domain.ParkingRecord, error) {
+	defer metrics.ObserveQuery("get_last_parking_record_by_vehicle_id")()
 
 	query := `
-		SELECT id, vehicle_id, parking_spot_id, entry_time, exit_time, created_at, updated_at
+		SELECT id, vehicle_id, parking_spot_id, entry_time, exit_time, fee, COALESCE(ticket_code, ''), entry_gate_id, exit_gate_id, created_at, updated_at
 		FROM parking_records
 		WHERE vehicle_id = $1
 		ORDER BY entry_time DESC
@@ -203,12 +241,16 @@ func (r *parkingRepo) GetLastParkingRecordByVehicleID(vehicleID int64) (*domain.
 	`
 
 	var record domain.ParkingRecord
-	err := r.db.QueryRow(query, vehicleID).Scan(
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, vehicleID).Scan(
 		&record.ID,
 		&record.VehicleID,
 		&record.ParkingSpotID,
 		&record.EntryTime,
 		&record.ExitTime,
+		&record.Fee,
+		&record.TicketCode,
+		&record.EntryGateID,
+		&record.ExitGateID,
 		&record.CreatedAt,
 		&record.UpdatedAt,
 	)
@@ -221,3 +263,452 @@ func (r *parkingRepo) GetLastParkingRecordByVehicleID(vehicleID int64) (*domain.
 
 	return &record, nil
 }
+
+func (r *parkingRepo) GetParkingRecordByTicketCode(ctx context.Context, ticketCode string) (*domain.ParkingRecord, error) {
+	defer metrics.ObserveQuery("get_parking_record_by_ticket_code")()
+
+	query := `
+		SELECT id, vehicle_id, parking_spot_id, entry_time, exit_time, fee, COALESCE(ticket_code, ''), entry_gate_id, exit_gate_id, created_at, updated_at
+		FROM parking_records
+		WHERE ticket_code = $1
+	`
+
+	var record domain.ParkingRecord
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, ticketCode).Scan(
+		&record.ID,
+		&record.VehicleID,
+		&record.ParkingSpotID,
+		&record.EntryTime,
+		&record.ExitTime,
+		&record.Fee,
+		&record.TicketCode,
+		&record.EntryGateID,
+		&record.ExitGateID,
+		&record.CreatedAt,
+		&record.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+func (r *parkingRepo) GetSpotChanges(ctx context.Context, since time.Time) ([]domain.SpotChange, error) {
+	defer metrics.ObserveQuery("get_spot_changes")()
+
+	// a record is written when the vehicle parks and again when it leaves
+	query := `
+		SELECT id, parking_spot_id, exit_time IS NOT NULL, updated_at, created_at
+		FROM parking_records
+		WHERE updated_at > $1
+		ORDER BY updated_at, id
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []domain.SpotChange
+	for rows.Next() {
+		var change domain.SpotChange
+		err := rows.Scan(&change.RecordID, &change.SpotID, &change.Freed, &change.ChangedAt, &change.TakenAt)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+func (r *parkingRepo) GetParkingRecordsByVehicleID(ctx context.Context, vehicleID int64, filter domain.HistoryFilter) ([]domain.ParkingRecord, error) {
+	defer metrics.ObserveQuery("get_parking_records_by_vehicle_id")()
+
+	conditions, args := historyFilter(filter, 2)
+	query := fmt.Sprintf(`
+		SELECT id, vehicle_id, parking_spot_id, entry_time, exit_time, fee, COALESCE(ticket_code, ''), entry_gate_id, exit_gate_id, created_at, updated_at
+		FROM parking_records
+		WHERE vehicle_id = $1 %s
+		ORDER BY entry_time DESC, id DESC
+		LIMIT %d
+	`, conditions, filter.Limit)
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, append([]any{vehicleID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []domain.ParkingRecord
+	for rows.Next() {
+		var record domain.ParkingRecord
+		err := rows.Scan(
+			&record.ID,
+			&record.VehicleID,
+			&record.ParkingSpotID,
+			&record.EntryTime,
+			&record.ExitTime,
+			&record.Fee,
+			&record.TicketCode,
+			&record.EntryGateID,
+			&record.ExitGateID,
+			&record.CreatedAt,
+			&record.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func (r *parkingRepo) GetAllSpots(ctx context.Context) ([]domain.ParkingSpot, error) {
+	defer metrics.ObserveQuery("get_all_spots")()
+
+	query := `
+		SELECT id, floor, row, "column", vehicle_type, size_class, is_active, created_at, updated_at
+		FROM parking_spots
+		ORDER BY floor, row, "column"
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var spots []domain.ParkingSpot
+	for rows.Next() {
+		var spot domain.ParkingSpot
+		err := rows.Scan(
+			&spot.ID,
+			&spot.Floor,
+			&spot.Row,
+			&spot.Column,
+			&spot.VehicleType,
+			&spot.SizeClass,
+			&spot.IsActive,
+			&spot.CreatedAt,
+			&spot.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		spots = append(spots, spot)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return spots, nil
+}
+
+func (r *parkingRepo) CountActiveSpots(ctx context.Context) (int64, error) {
+	defer metrics.ObserveQuery("count_active_spots")()
+
+	var count int64
+	err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM parking_spots WHERE is_active = true`).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (r *parkingRepo) CreateSpot(ctx context.Context, spot *domain.ParkingSpot) error {
+	defer metrics.ObserveQuery("create_spot")()
+
+	query := `
+		INSERT INTO parking_spots (floor, row, "column", vehicle_type, size_class, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`
+
+	now := time.Now()
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		query,
+		spot.Floor,
+		spot.Row,
+		spot.Column,
+		spot.VehicleType,
+		spot.SizeClass,
+		spot.IsActive,
+		now,
+		now,
+	).Scan(&spot.ID)
+
+	if err != nil {
+		return err
+	}
+
+	spot.CreatedAt = now
+	spot.UpdatedAt = now
+
+	return nil
+}
+
+func (r *parkingRepo) DeactivateSpotIfFree(ctx context.Context, id int64) (bool, error) {
+	defer metrics.ObserveQuery("deactivate_spot_if_free")()
+
+	query := `
+		UPDATE parking_spots
+		SET is_active = false, updated_at = $1
+		WHERE id = $2
+		AND NOT EXISTS (
+			SELECT 1
+			FROM parking_records
+			WHERE parking_spot_id = $2 AND exit_time IS NULL
+		)
+	`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// ClaimSpot atomically claims the first of the candidate spots that is still free and writes the
+// parking record for it, creating the vehicle first if it is new. Spots are locked with SKIP LOCKED so
+// concurrent gates, even across replicas, never wait on or receive the same spot. It returns nil if none
+// of the candidates is available.
+func (r *parkingRepo) ClaimSpot(ctx context.Context, vehicle *domain.Vehicle, record *domain.ParkingRecord, candidateIDs []int64) (*domain.ParkingSpot, error) {
+	defer metrics.ObserveQuery("claim_spot")()
+
+	for attempt := 0; attempt < maxClaimAttempts; attempt++ {
+		spot, err := r.claimSpot(ctx, vehicle, record, candidateIDs)
+		if r.isUniqueViolation(err, activeSpotIndex) {
+			// another transaction parked on this spot between our snapshot and lock, try again
+			continue
+		}
+		if r.isUniqueViolation(err, activeVehicleIndex) || r.isUniqueViolation(err, vehiclePlateIndex) {
+			// a concurrent request parked the same vehicle, or created it when it was new
+			return nil, domain.ErrVehicleAlreadyParked
+		}
+		return spot, err
+	}
+
+	return nil, errors.New("could not claim a parking spot, too many concurrent requests")
+}
+
+func (r *parkingRepo) claimSpot(ctx context.Context, vehicle *domain.Vehicle, record *domain.ParkingRecord, candidateIDs []int64) (*domain.ParkingSpot, error) {
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return nil, err
+	}
+	isNewVehicle := vehicle.ID == 0
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			if isNewVehicle {
+				// the vehicle was rolled back with the record, it is created again on the next claim
+				vehicle.ID = 0
+			}
+		}
+	}()
+
+	query := fmt.Sprintf(`
+		SELECT ps.id, ps.floor, ps.row, ps.column, ps.vehicle_type, ps.size_class, ps.is_active, ps.created_at, ps.updated_at
+		FROM parking_spots ps
+		WHERE ps.id = $2 AND ps.is_active = true
+		AND NOT EXISTS (
+			SELECT 1
+			FROM parking_records pr
+			WHERE pr.parking_spot_id = ps.id AND pr.exit_time IS NULL
+		)
+		AND NOT EXISTS (%s)
+		AND NOT EXISTS (%s)
+		%s
+	`, reservedNowQuery, underMaintenanceQuery, r.spotLockClause)
+
+	// take the first candidate that is still free and not locked by another transaction
+	now := time.Now()
+	for _, id := range candidateIDs {
+		var spot domain.ParkingSpot
+		err = tx.QueryRowContext(ctx, query, now, id).Scan(
+			&spot.ID,
+			&spot.Floor,
+			&spot.Row,
+			&spot.Column,
+			&spot.VehicleType,
+			&spot.SizeClass,
+			&spot.IsActive,
+			&spot.CreatedAt,
+			&spot.UpdatedAt,
+		)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		err = insertVehicleIfNew(ctx, tx, vehicle, record)
+		if err != nil {
+			return nil, err
+		}
+
+		record.ParkingSpotID = spot.ID
+		err = insertParkingRecord(ctx, tx, record)
+		if err != nil {
+			return nil, err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return nil, err
+		}
+
+		return &spot, nil
+	}
+
+	// none of the candidates is free any more
+	tx.Rollback()
+	return nil, nil
+}
+
+// insertVehicleIfNew creates the vehicle if it has no ID yet and assigns the vehicle to the record
+func insertVehicleIfNew(ctx context.Context, tx *sqlTx, vehicle *domain.Vehicle, record *domain.ParkingRecord) error {
+	if vehicle.ID == 0 {
+		now := time.Now()
+		err := tx.QueryRowContext(ctx, insertVehicleQuery, vehicle.LicensePlate, vehicle.Type, now, now).Scan(&vehicle.ID)
+		if err != nil {
+			return err
+		}
+
+		vehicle.CreatedAt = now
+		vehicle.UpdatedAt = now
+	}
+
+	record.VehicleID = vehicle.ID
+	return nil
+}
+
+// insertParkingRecord stores a new parking record within the transaction
+func insertParkingRecord(ctx context.Context, tx *sqlTx, record *domain.ParkingRecord) error {
+	query := `
+		INSERT INTO parking_records (vehicle_id, parking_spot_id, entry_time, ticket_code, entry_gate_id, created_at, updated_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7)
+		RETURNING id
+	`
+
+	now := time.Now()
+	err := tx.QueryRowContext(ctx,
+		query,
+		record.VehicleID,
+		record.ParkingSpotID,
+		record.EntryTime,
+		record.TicketCode,
+		record.EntryGateID,
+		now,
+		now,
+	).Scan(&record.ID)
+
+	if err != nil {
+		return err
+	}
+
+	record.CreatedAt = now
+	record.UpdatedAt = now
+
+	return nil
+}
+
+// reservedNowQuery selects the active reservations holding spot ps at the time given as $1, which they do from
+// their hold, ahead of their start time
+const reservedNowQuery = `
+	SELECT 1
+	FROM reservations rs
+	WHERE rs.parking_spot_id = ps.id AND rs.status = 'active' AND rs.held_from <= $1 AND rs.end_time > $1
+`
+
+// underMaintenanceQuery selects the maintenances closing spot ps at the time given as $1
+const underMaintenanceQuery = `
+	SELECT 1
+	FROM spot_maintenances sm
+	WHERE sm.parking_spot_id = ps.id AND sm.start_time <= $1 AND (sm.end_time IS NULL OR sm.end_time > $1)
+`
+
+// spotFilter builds the "AND ps.vehicle_type = ... AND ps.size_class IN (...)" conditions for the filter
+// and their arguments, numbering the placeholders from firstArg. It returns an empty condition when the
+// filter matches any spot.
+func spotFilter(filter domain.SpotFilter, firstArg int) (string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+
+	if filter.VehicleType != "" {
+		args = append(args, filter.VehicleType)
+		conditions = append(conditions, fmt.Sprintf("AND ps.vehicle_type = $%d", firstArg))
+	}
+
+	if len(filter.SizeClasses) > 0 {
+		placeholders := make([]string, len(filter.SizeClasses))
+		for i, sizeClass := range filter.SizeClasses {
+			args = append(args, sizeClass)
+			placeholders[i] = fmt.Sprintf("$%d", firstArg+len(args)-1)
+		}
+		conditions = append(conditions, fmt.Sprintf("AND ps.size_class IN (%s)", strings.Join(placeholders, ", ")))
+	}
+
+	return strings.Join(conditions, " "), args
+}
+
+// historyFilter builds the entry time and cursor conditions for the filter and their arguments,
+// numbering the placeholders from firstArg
+func historyFilter(filter domain.HistoryFilter, firstArg int) (string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, fmt.Sprintf("AND entry_time >= $%d", firstArg+len(args)-1))
+	}
+
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, fmt.Sprintf("AND entry_time < $%d", firstArg+len(args)-1))
+	}
+
+	if filter.After != nil {
+		args = append(args, filter.After.EntryTime, filter.After.RecordID)
+		conditions = append(conditions, fmt.Sprintf("AND (entry_time, id) < ($%d, $%d)", firstArg+len(args)-2, firstArg+len(args)-1))
+	}
+
+	return strings.Join(conditions, " "), args
+}
+
+// isPostgresUniqueViolation reports whether err is a unique constraint violation on the given index
+func isPostgresUniqueViolation(err error, index string) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "23505" && pqErr.Constraint == index
+}
@@ -0,0 +1,390 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"parking-lot/domain"
+	"parking-lot/metrics"
+)
+
+type reservationRepo struct {
+	db *sql.DB
+	// spotLockClause is appended to the spot selection in CreateReservation to lock the chosen row
+	spotLockClause string
+	// reservationLockClause is appended to the reservation selection in ClaimReservation to lock it
+	reservationLockClause string
+	// isUniqueViolation reports whether err violates the given unique index
+	isUniqueViolation func(err error, index string) bool
+}
+
+func NewReservationRepository(db *sql.DB) domain.ReservationRepository {
+	return &reservationRepo{
+		db:                    db,
+		spotLockClause:        "FOR UPDATE OF ps SKIP LOCKED",
+		reservationLockClause: "FOR UPDATE",
+		isUniqueViolation:     isPostgresUniqueViolation,
+	}
+}
+
+// overlappingReservationQuery selects the active reservations of spot ps holding it during the window from $1
+// to $2, which they do from their hold, ahead of their start time
+const overlappingReservationQuery = `
+	SELECT 1
+	FROM reservations rs
+	WHERE rs.parking_spot_id = ps.id AND rs.status = 'active' AND rs.held_from < $2 AND rs.end_time > $1
+`
+
+// overlappingMaintenanceQuery selects the maintenances of spot ps overlapping the window from $1 to $2
+const overlappingMaintenanceQuery = `
+	SELECT 1
+	FROM spot_maintenances sm
+	WHERE sm.parking_spot_id = ps.id AND sm.start_time < $2 AND (sm.end_time IS NULL OR sm.end_time > $1)
+`
+
+func (r *reservationRepo) CreateReservation(ctx context.Context, reservation *domain.Reservation, filter domain.SpotFilter) (*domain.ParkingSpot, error) {
+	defer metrics.ObserveQuery("create_reservation")()
+
+	for attempt := 0; attempt < maxClaimAttempts; attempt++ {
+		spot, err := r.createReservation(ctx, reservation, filter)
+		if errors.Is(err, errReservationConflict) {
+			// another transaction reserved this spot between our snapshot and lock, try again
+			continue
+		}
+		return spot, err
+	}
+
+	return nil, errors.New("could not reserve a parking spot, too many concurrent requests")
+}
+
+var errReservationConflict = errors.New("overlapping reservation")
+
+func (r *reservationRepo) createReservation(ctx context.Context, reservation *domain.Reservation, filter domain.SpotFilter) (*domain.ParkingSpot, error) {
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	filterWhere, filterArgs := spotFilter(filter, 3)
+
+	query := fmt.Sprintf(`
+		SELECT ps.id, ps.floor, ps.row, ps.column, ps.vehicle_type, ps.size_class, ps.is_active, ps.created_at, ps.updated_at
+		FROM parking_spots ps
+		WHERE ps.is_active = true %s
+		AND NOT EXISTS (
+			SELECT 1
+			FROM parking_records pr
+			WHERE pr.parking_spot_id = ps.id AND pr.exit_time IS NULL
+		)
+		AND NOT EXISTS (%s)
+		AND NOT EXISTS (%s)
+		ORDER BY ps.floor, ps.row, ps.column
+		LIMIT 1
+		%s
+	`, filterWhere, overlappingReservationQuery, overlappingMaintenanceQuery, r.spotLockClause)
+
+	// the spot must be free for as long as the reservation holds it
+	args := append([]any{reservation.HeldFrom, reservation.EndTime}, filterArgs...)
+
+	var spot domain.ParkingSpot
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
+		&spot.ID,
+		&spot.Floor,
+		&spot.Row,
+		&spot.Column,
+		&spot.VehicleType,
+		&spot.SizeClass,
+		&spot.IsActive,
+		&spot.CreatedAt,
+		&spot.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// The spot is locked now, check again with a fresh snapshot in case a reservation
+	// for it was committed while we were waiting for the lock
+	var overlapping bool
+	err = tx.QueryRowContext(ctx,
+		fmt.Sprintf(`SELECT EXISTS (%s) FROM parking_spots ps WHERE ps.id = $3`, overlappingReservationQuery),
+		reservation.HeldFrom, reservation.EndTime, spot.ID,
+	).Scan(&overlapping)
+	if err != nil {
+		return nil, err
+	}
+	if overlapping {
+		err = errReservationConflict
+		return nil, err
+	}
+
+	now := time.Now()
+	reservation.ParkingSpotID = spot.ID
+	reservation.Status = domain.ReservationActive
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO reservations (parking_spot_id, license_plate, vehicle_type, start_time, end_time, held_from, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`,
+		reservation.ParkingSpotID,
+		reservation.LicensePlate,
+		reservation.VehicleType,
+		reservation.StartTime,
+		reservation.EndTime,
+		reservation.HeldFrom,
+		reservation.Status,
+		now,
+		now,
+	).Scan(&reservation.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	reservation.CreatedAt = now
+	reservation.UpdatedAt = now
+
+	return &spot, nil
+}
+
+func (r *reservationRepo) GetReservationByID(ctx context.Context, id int64) (*domain.Reservation, error) {
+	defer metrics.ObserveQuery("get_reservation_by_id")()
+
+	query := `
+		SELECT id, parking_spot_id, license_plate, vehicle_type, start_time, end_time, held_from, status, created_at, updated_at
+		FROM reservations
+		WHERE id = $1
+	`
+
+	var reservation domain.Reservation
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
+		&reservation.ID,
+		&reservation.ParkingSpotID,
+		&reservation.LicensePlate,
+		&reservation.VehicleType,
+		&reservation.StartTime,
+		&reservation.EndTime,
+		&reservation.HeldFrom,
+		&reservation.Status,
+		&reservation.CreatedAt,
+		&reservation.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &reservation, nil
+}
+
+func (r *reservationRepo) GetReservationsByLicensePlate(ctx context.Context, licensePlate string) ([]domain.Reservation, error) {
+	defer metrics.ObserveQuery("get_reservations_by_license_plate")()
+
+	query := `
+		SELECT id, parking_spot_id, license_plate, vehicle_type, start_time, end_time, held_from, status, created_at, updated_at
+		FROM reservations
+		WHERE license_plate = $1
+		ORDER BY start_time DESC
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, licensePlate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reservations []domain.Reservation
+	for rows.Next() {
+		var reservation domain.Reservation
+		err := rows.Scan(
+			&reservation.ID,
+			&reservation.ParkingSpotID,
+			&reservation.LicensePlate,
+			&reservation.VehicleType,
+			&reservation.StartTime,
+			&reservation.EndTime,
+			&reservation.HeldFrom,
+			&reservation.Status,
+			&reservation.CreatedAt,
+			&reservation.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, reservation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reservations, nil
+}
+
+func (r *reservationRepo) UpdateReservationStatus(ctx context.Context, id int64, status domain.ReservationStatus) error {
+	defer metrics.ObserveQuery("update_reservation_status")()
+
+	query := `
+		UPDATE reservations
+		SET status = $1, updated_at = $2
+		WHERE id = $3 AND status = $4
+	`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, status, time.Now(), id, domain.ReservationActive)
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		// claimed, cancelled or expired since the reservation was read
+		return domain.ErrReservationNotActive
+	}
+	return nil
+}
+
+func (r *reservationRepo) ClaimReservation(ctx context.Context, vehicle *domain.Vehicle, record *domain.ParkingRecord, reservation *domain.Reservation) (*domain.ParkingSpot, error) {
+	defer metrics.ObserveQuery("claim_reservation")()
+
+	spot, err := r.claimReservation(ctx, vehicle, record, reservation)
+	if r.isUniqueViolation(err, activeSpotIndex) {
+		return nil, domain.ErrSpotUnavailable
+	}
+	if r.isUniqueViolation(err, activeVehicleIndex) || r.isUniqueViolation(err, vehiclePlateIndex) {
+		return nil, domain.ErrVehicleAlreadyParked
+	}
+	return spot, err
+}
+
+func (r *reservationRepo) claimReservation(ctx context.Context, vehicle *domain.Vehicle, record *domain.ParkingRecord, reservation *domain.Reservation) (*domain.ParkingSpot, error) {
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return nil, err
+	}
+	isNewVehicle := vehicle.ID == 0
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			if isNewVehicle {
+				vehicle.ID = 0
+			}
+		}
+	}()
+
+	// Lock the reservation so it cannot be claimed, cancelled or expired concurrently
+	var status domain.ReservationStatus
+	err = tx.QueryRowContext(ctx,
+		fmt.Sprintf(`SELECT status FROM reservations WHERE id = $1 %s`, r.reservationLockClause),
+		reservation.ID,
+	).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && status != domain.ReservationActive) {
+		// cancelled or expired since it was looked up, the vehicle parks like any other
+		err = domain.ErrSpotUnavailable
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var spot domain.ParkingSpot
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, floor, row, "column", vehicle_type, size_class, is_active, created_at, updated_at
+		FROM parking_spots
+		WHERE id = $1
+	`, reservation.ParkingSpotID).Scan(
+		&spot.ID,
+		&spot.Floor,
+		&spot.Row,
+		&spot.Column,
+		&spot.VehicleType,
+		&spot.SizeClass,
+		&spot.IsActive,
+		&spot.CreatedAt,
+		&spot.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !spot.IsActive {
+		err = domain.ErrSpotUnavailable
+		return nil, err
+	}
+
+	var underMaintenance bool
+	err = tx.QueryRowContext(ctx,
+		fmt.Sprintf(`SELECT EXISTS (%s) FROM parking_spots ps WHERE ps.id = $2`, underMaintenanceQuery),
+		record.EntryTime, spot.ID,
+	).Scan(&underMaintenance)
+	if err != nil {
+		return nil, err
+	}
+	if underMaintenance {
+		err = domain.ErrSpotUnavailable
+		return nil, err
+	}
+
+	err = insertVehicleIfNew(ctx, tx, vehicle, record)
+	if err != nil {
+		return nil, err
+	}
+
+	record.ParkingSpotID = spot.ID
+	err = insertParkingRecord(ctx, tx, record)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE reservations
+		SET status = $1, updated_at = $2
+		WHERE id = $3
+	`, domain.ReservationClaimed, time.Now(), reservation.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	reservation.Status = domain.ReservationClaimed
+
+	return &spot, nil
+}
+
+func (r *reservationRepo) ExpireReservations(ctx context.Context, startedBefore time.Time) (int64, error) {
+	defer metrics.ObserveQuery("expire_reservations")()
+
+	query := `
+		UPDATE reservations
+		SET status = $1, updated_at = $2
+		WHERE status = $3 AND start_time < $4
+	`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, domain.ReservationExpired, time.Now(), domain.ReservationActive, startedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"parking-lot/domain"
+)
+
+// sqliteUniqueColumns maps unique index names to the column SQLite reports in constraint errors,
+// since SQLite does not include the index name in the error message
+var sqliteUniqueColumns = map[string]string{
+	activeSpotIndex:    "parking_records.parking_spot_id",
+	activeVehicleIndex: "parking_records.vehicle_id",
+	vehiclePlateIndex:  "vehicles.license_plate",
+}
+
+// NewSQLiteParkingRepository returns a parking repository backed by SQLite.
+// SQLite has no row level locks, so the connection must begin transactions with
+// BEGIN IMMEDIATE (see config.InitDBConnection) to serialize spot claims.
+func NewSQLiteParkingRepository(db *sql.DB) domain.ParkingRepository {
+	return &parkingRepo{
+		db:                db,
+		spotLockClause:    "",
+		isUniqueViolation: isSQLiteUniqueViolation,
+	}
+}
+
+// isSQLiteUniqueViolation reports whether err is a unique constraint violation on the given index
+func isSQLiteUniqueViolation(err error, index string) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE && strings.Contains(sqliteErr.Error(), sqliteUniqueColumns[index])
+}
+
+// NewSQLiteReservationRepository returns a reservation repository backed by SQLite,
+// relying on BEGIN IMMEDIATE transactions instead of row locks like NewSQLiteParkingRepository
+func NewSQLiteReservationRepository(db *sql.DB) domain.ReservationRepository {
+	return &reservationRepo{
+		db:                    db,
+		spotLockClause:        "",
+		reservationLockClause: "",
+		isUniqueViolation:     isSQLiteUniqueViolation,
+	}
+}
+
+// NewSQLiteWebhookRepository returns a webhook repository backed by SQLite, relying on
+// BEGIN IMMEDIATE transactions instead of row locks like NewSQLiteParkingRepository
+func NewSQLiteWebhookRepository(db *sql.DB) domain.WebhookRepository {
+	return &webhookRepo{
+		db:                 db,
+		deliveryLockClause: "",
+	}
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"parking-lot/domain"
+	"parking-lot/metrics"
+)
+
+type statsRepo struct {
+	db *sql.DB
+}
+
+func NewStatsRepository(db *sql.DB) domain.StatsRepository {
+	return &statsRepo{
+		db: db,
+	}
+}
+
+func (r *statsRepo) GetOccupancyCounts(ctx context.Context, at time.Time) ([]domain.OccupancyCount, error) {
+	defer metrics.ObserveQuery("get_occupancy_counts")()
+
+	// Each spot gets exactly one state, an occupied spot counts as occupied even when it is inactive
+	query := fmt.Sprintf(`
+		SELECT floor, vehicle_type,
+			COUNT(*),
+			SUM(CASE WHEN state = 'occupied' THEN 1 ELSE 0 END),
+			SUM(CASE WHEN state = 'reserved' THEN 1 ELSE 0 END),
+			SUM(CASE WHEN state = 'inactive' THEN 1 ELSE 0 END),
+			SUM(CASE WHEN state = 'free' THEN 1 ELSE 0 END)
+		FROM (
+			SELECT ps.floor, ps.vehicle_type,
+				CASE
+					WHEN EXISTS (SELECT 1 FROM parking_records pr WHERE pr.parking_spot_id = ps.id AND pr.exit_time IS NULL) THEN 'occupied'
+					WHEN ps.is_active = false OR EXISTS (%s) THEN 'inactive'
+					WHEN EXISTS (%s) THEN 'reserved'
+					ELSE 'free'
+				END AS state
+			FROM parking_spots ps
+		) spots
+		GROUP BY floor, vehicle_type
+		ORDER BY floor, vehicle_type
+	`, underMaintenanceQuery, reservedNowQuery)
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []domain.OccupancyCount
+	for rows.Next() {
+		var count domain.OccupancyCount
+		err := rows.Scan(
+			&count.Floor,
+			&count.VehicleType,
+			&count.Total,
+			&count.Occupied,
+			&count.Reserved,
+			&count.Inactive,
+			&count.Free,
+		)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
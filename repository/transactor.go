@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+
+	"parking-lot/domain"
+)
+
+// txKey holds the *sql.Tx of a transaction started by the transactor on a context
+type txKey struct{}
+
+type transactor struct {
+	db *sql.DB
+}
+
+// NewTransactor returns a transactor running functions in a database transaction. The repositories
+// created for the same database run their queries in that transaction when given its context.
+func NewTransactor(db *sql.DB) domain.Transactor {
+	return &transactor{
+		db: db,
+	}
+}
+
+func (t *transactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		// already within a transaction, the outermost one commits
+		return fn(ctx)
+	}
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+
+	err = fn(context.WithValue(ctx, txKey{}, tx))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// queryer runs queries on the database or in a transaction
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// conn returns the transaction of the context if there is one, and the database otherwise
+func conn(ctx context.Context, db *sql.DB) queryer {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// sqlTx is a transaction of a single repository operation. Within a transaction of the transactor it is
+// a savepoint, so the operation can still be rolled back and retried on its own.
+type sqlTx struct {
+	*sql.Tx
+	savepoint string
+	// done is set once the savepoint is released or rolled back, as postgres aborts the whole
+	// transaction on a statement for an unknown savepoint
+	done bool
+}
+
+var savepointCounter atomic.Int64
+
+// beginTx begins a transaction, or a savepoint when the context is within a transaction of the transactor
+func beginTx(ctx context.Context, db *sql.DB) (*sqlTx, error) {
+	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
+	if !ok {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &sqlTx{Tx: tx}, nil
+	}
+
+	savepoint := fmt.Sprintf("sp_%d", savepointCounter.Add(1))
+	_, err := tx.ExecContext(ctx, "SAVEPOINT "+savepoint)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{Tx: tx, savepoint: savepoint}, nil
+}
+
+func (tx *sqlTx) Commit() error {
+	if tx.savepoint == "" {
+		return tx.Tx.Commit()
+	}
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+	_, err := tx.Exec("RELEASE SAVEPOINT " + tx.savepoint)
+	return err
+}
+
+func (tx *sqlTx) Rollback() error {
+	if tx.savepoint == "" {
+		return tx.Tx.Rollback()
+	}
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+	_, err := tx.Exec("ROLLBACK TO SAVEPOINT " + tx.savepoint)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("RELEASE SAVEPOINT " + tx.savepoint)
+	return err
+}
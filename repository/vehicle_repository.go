@@ -1,38 +1,36 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"sync"
 	"time"
 
 	"parking-lot/domain"
+	"parking-lot/metrics"
 )
 
 type vehicleRepo struct {
-	db    *sql.DB
-	mutex *sync.RWMutex
+	db *sql.DB
 }
 
 func NewVehicleRepository(db *sql.DB) domain.VehicleRepository {
 	return &vehicleRepo{
-		db:    db,
-		mutex: &sync.RWMutex{},
+		db: db,
 	}
 }
 
-func (r *vehicleRepo) GetVehicleByLicensePlate(licensePlate string) (*domain.Vehicle, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+func (r *vehicleRepo) GetVehicleByID(ctx context.Context, id int64) (*domain.Vehicle, error) {
+	defer metrics.ObserveQuery("get_vehicle_by_id")()
 
 	query := `
-		SELECT id, license_plate, type, created_at, updated_at
+		SELECT id, COALESCE(license_plate, ''), type, created_at, updated_at
 		FROM vehicles
-		WHERE license_plate = $1
+		WHERE id = $1
 	`
 
 	var vehicle domain.Vehicle
-	err := r.db.QueryRow(query, licensePlate).Scan(
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
 		&vehicle.ID,
 		&vehicle.LicensePlate,
 		&vehicle.Type,
@@ -49,19 +47,39 @@ func (r *vehicleRepo) GetVehicleByLicensePlate(licensePlate string) (*domain.Veh
 	return &vehicle, nil
 }
 
-func (r *vehicleRepo) CreateVehicle(vehicle *domain.Vehicle) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+func (r *vehicleRepo) GetVehicleByLicensePlate(ctx context.Context, licensePlate string) (*domain.Vehicle, error) {
+	defer metrics.ObserveQuery("get_vehicle_by_license_plate")()
 
 	query := `
-		INSERT INTO vehicles (license_plate, type, created_at, updated_at)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id
+		SELECT id, COALESCE(license_plate, ''), type, created_at, updated_at
+		FROM vehicles
+		WHERE license_plate = $1
 	`
 
+	var vehicle domain.Vehicle
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, licensePlate).Scan(
+		&vehicle.ID,
+		&vehicle.LicensePlate,
+		&vehicle.Type,
+		&vehicle.CreatedAt,
+		&vehicle.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &vehicle, nil
+}
+
+func (r *vehicleRepo) CreateVehicle(ctx context.Context, vehicle *domain.Vehicle) error {
+	defer metrics.ObserveQuery("create_vehicle")()
+
 	now := time.Now()
-	err := r.db.QueryRow(
-		query,
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		insertVehicleQuery,
 		vehicle.LicensePlate,
 		vehicle.Type,
 		now,
@@ -77,3 +95,9 @@ func (r *vehicleRepo) CreateVehicle(vehicle *domain.Vehicle) error {
 
 	return nil
 }
+
+const insertVehicleQuery = `
+	INSERT INTO vehicles (license_plate, type, created_at, updated_at)
+	VALUES (NULLIF($1, ''), $2, $3, $4)
+	RETURNING id
+`
@@ -0,0 +1,289 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"parking-lot/domain"
+	"parking-lot/metrics"
+)
+
+type webhookRepo struct {
+	db *sql.DB
+	// deliveryLockClause locks the claimed deliveries, so concurrent dispatchers skip them
+	deliveryLockClause string
+}
+
+func NewWebhookRepository(db *sql.DB) domain.WebhookRepository {
+	return &webhookRepo{
+		db:                 db,
+		deliveryLockClause: "FOR UPDATE OF o SKIP LOCKED",
+	}
+}
+
+func (r *webhookRepo) CreateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	defer metrics.ObserveQuery("create_webhook")()
+
+	query := `
+		INSERT INTO webhooks (url, event_types, secret, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+
+	now := time.Now()
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, webhook.URL, joinEventTypes(webhook.EventTypes), webhook.Secret, now).Scan(&webhook.ID)
+	if err != nil {
+		return err
+	}
+
+	webhook.CreatedAt = now
+
+	return nil
+}
+
+func (r *webhookRepo) GetWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	defer metrics.ObserveQuery("get_webhooks")()
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `SELECT id, url, event_types, secret, created_at FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []domain.Webhook
+	for rows.Next() {
+		var (
+			webhook    domain.Webhook
+			eventTypes string
+		)
+		err := rows.Scan(&webhook.ID, &webhook.URL, &eventTypes, &webhook.Secret, &webhook.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		webhook.EventTypes = splitEventTypes(eventTypes)
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (r *webhookRepo) DeleteWebhook(ctx context.Context, id int64) (bool, error) {
+	defer metrics.ObserveQuery("delete_webhook")()
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (r *webhookRepo) EnqueueEvent(ctx context.Context, event domain.Event, payload []byte) error {
+	webhooks, err := r.GetWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer metrics.ObserveQuery("enqueue_event")()
+
+	query := `
+		INSERT INTO webhook_outbox (webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, 0, $6, $6, $6)
+	`
+
+	for _, webhook := range webhooks {
+		if !slices.Contains(webhook.EventTypes, event.Type) {
+			continue
+		}
+
+		_, err = conn(ctx, r.db).ExecContext(ctx, query, webhook.ID, event.ID, event.Type, string(payload), domain.DeliveryPending, event.OccurredAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *webhookRepo) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.WebhookDelivery, error) {
+	defer metrics.ObserveQuery("claim_due_deliveries")()
+
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`
+		%s
+		WHERE o.status = $1 AND o.next_attempt_at <= $2
+		ORDER BY o.next_attempt_at
+		LIMIT $3
+		%s
+	`, selectDeliveryQuery, r.deliveryLockClause)
+
+	rows, err := tx.QueryContext(ctx, query, domain.DeliveryPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := scanDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// postpone the deliveries until the lease ends, they are retried then if this dispatcher stops
+	for i := range deliveries {
+		deliveries[i].NextAttemptAt = now.Add(lease)
+		_, err = tx.ExecContext(ctx, `UPDATE webhook_outbox SET next_attempt_at = $1 WHERE id = $2`, deliveries[i].NextAttemptAt, deliveries[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (r *webhookRepo) UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	defer metrics.ObserveQuery("update_delivery")()
+
+	query := `
+		UPDATE webhook_outbox
+		SET status = $1, attempts = $2, next_attempt_at = $3, last_error = NULLIF($4, ''), updated_at = $5
+		WHERE id = $6
+	`
+
+	now := time.Now()
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.LastError, now, delivery.ID)
+	if err != nil {
+		return err
+	}
+
+	delivery.UpdatedAt = now
+
+	return nil
+}
+
+func (r *webhookRepo) GetDeliveriesByStatus(ctx context.Context, status domain.DeliveryStatus) ([]domain.WebhookDelivery, error) {
+	defer metrics.ObserveQuery("get_deliveries_by_status")()
+
+	query := selectDeliveryQuery + `
+		WHERE o.status = $1
+		ORDER BY o.id
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, status)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanDeliveries(rows)
+}
+
+func (r *webhookRepo) RetryDeadDelivery(ctx context.Context, id int64, now time.Time) (bool, error) {
+	defer metrics.ObserveQuery("retry_dead_delivery")()
+
+	query := `
+		UPDATE webhook_outbox
+		SET status = $1, attempts = 0, next_attempt_at = $2, updated_at = $2
+		WHERE id = $3 AND status = $4
+	`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, domain.DeliveryPending, now, id, domain.DeliveryDead)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (r *webhookRepo) DeleteDeliveries(ctx context.Context, status domain.DeliveryStatus, updatedBefore time.Time) (int64, error) {
+	defer metrics.ObserveQuery("delete_deliveries")()
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM webhook_outbox WHERE status = $1 AND updated_at < $2`, status, updatedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// selectDeliveryQuery selects deliveries as o with the URL and secret of their webhook, for scanDeliveries
+const selectDeliveryQuery = `
+	SELECT o.id, o.webhook_id, w.url, w.secret, o.event_id, o.event_type, o.payload, o.status, o.attempts,
+		o.next_attempt_at, COALESCE(o.last_error, ''), o.created_at, o.updated_at
+	FROM webhook_outbox o
+	JOIN webhooks w ON w.id = o.webhook_id
+`
+
+func scanDeliveries(rows *sql.Rows) ([]domain.WebhookDelivery, error) {
+	defer rows.Close()
+
+	var deliveries []domain.WebhookDelivery
+	for rows.Next() {
+		var (
+			delivery domain.WebhookDelivery
+			payload  []byte
+		)
+		err := rows.Scan(
+			&delivery.ID,
+			&delivery.WebhookID,
+			&delivery.URL,
+			&delivery.Secret,
+			&delivery.EventID,
+			&delivery.EventType,
+			&payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.NextAttemptAt,
+			&delivery.LastError,
+			&delivery.CreatedAt,
+			&delivery.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		delivery.Payload = payload
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func joinEventTypes(eventTypes []domain.EventType) string {
+	values := make([]string, len(eventTypes))
+	for i, eventType := range eventTypes {
+		values[i] = string(eventType)
+	}
+	return strings.Join(values, ",")
+}
+
+func splitEventTypes(value string) []domain.EventType {
+	var eventTypes []domain.EventType
+	for _, eventType := range strings.Split(value, ",") {
+		if eventType != "" {
+			eventTypes = append(eventTypes, domain.EventType(eventType))
+		}
+	}
+	return eventTypes
+}
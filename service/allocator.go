@@ -0,0 +1,121 @@
+package service
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"slices"
+
+	"parking-lot/domain"
+)
+
+// floorDistance is how many rows or columns moving one floor is worth when measuring distance
+const floorDistance = 10
+
+// NewSpotAllocator returns the allocator for the strategy, falling back to first fit for unknown strategies
+func NewSpotAllocator(strategy domain.AllocationStrategy) domain.SpotAllocator {
+	switch strategy {
+	case domain.AllocationNearestToGate:
+		return nearestToGateAllocator{}
+	case domain.AllocationBalanceFloors:
+		return balanceFloorsAllocator{}
+	case domain.AllocationHighestFloorFirst:
+		return highestFloorFirstAllocator{}
+	case domain.AllocationRandom:
+		return randomAllocator{}
+	default:
+		return firstFitAllocator{}
+	}
+}
+
+// firstFitAllocator prefers the lowest floor, row and column
+type firstFitAllocator struct{}
+
+func (firstFitAllocator) Rank(candidates []domain.ParkingSpot, _ domain.AllocationRequest) []domain.ParkingSpot {
+	spots := slices.Clone(candidates)
+	slices.SortStableFunc(spots, comparePosition)
+	return spots
+}
+
+// nearestToGateAllocator prefers the spots closest to where the vehicle enters,
+// the first row and column of the ground floor when unknown
+type nearestToGateAllocator struct{}
+
+func (nearestToGateAllocator) Rank(candidates []domain.ParkingSpot, request domain.AllocationRequest) []domain.ParkingSpot {
+	entrance := domain.Position{Floor: 1, Row: 1, Column: 1}
+	if request.Entrance != nil {
+		entrance = *request.Entrance
+	}
+
+	spots := slices.Clone(candidates)
+	slices.SortStableFunc(spots, func(a, b domain.ParkingSpot) int {
+		return cmp.Or(
+			cmp.Compare(distance(a, entrance), distance(b, entrance)),
+			comparePosition(a, b),
+		)
+	})
+	return spots
+}
+
+// balanceFloorsAllocator prefers the floor with the most available spots, spreading vehicles across floors
+type balanceFloorsAllocator struct{}
+
+func (balanceFloorsAllocator) Rank(candidates []domain.ParkingSpot, _ domain.AllocationRequest) []domain.ParkingSpot {
+	available := make(map[int]int)
+	for _, spot := range candidates {
+		available[spot.Floor]++
+	}
+
+	spots := slices.Clone(candidates)
+	slices.SortStableFunc(spots, func(a, b domain.ParkingSpot) int {
+		return cmp.Or(
+			cmp.Compare(available[b.Floor], available[a.Floor]),
+			comparePosition(a, b),
+		)
+	})
+	return spots
+}
+
+// highestFloorFirstAllocator fills the highest floor first, keeping lower floors free for short stays
+type highestFloorFirstAllocator struct{}
+
+func (highestFloorFirstAllocator) Rank(candidates []domain.ParkingSpot, _ domain.AllocationRequest) []domain.ParkingSpot {
+	spots := slices.Clone(candidates)
+	slices.SortStableFunc(spots, func(a, b domain.ParkingSpot) int {
+		return cmp.Or(
+			cmp.Compare(b.Floor, a.Floor),
+			comparePosition(a, b),
+		)
+	})
+	return spots
+}
+
+// randomAllocator picks spots at random, spreading wear evenly
+type randomAllocator struct{}
+
+func (randomAllocator) Rank(candidates []domain.ParkingSpot, _ domain.AllocationRequest) []domain.ParkingSpot {
+	spots := slices.Clone(candidates)
+	rand.Shuffle(len(spots), func(i, j int) {
+		spots[i], spots[j] = spots[j], spots[i]
+	})
+	return spots
+}
+
+func comparePosition(a, b domain.ParkingSpot) int {
+	return cmp.Or(
+		cmp.Compare(a.Floor, b.Floor),
+		cmp.Compare(a.Row, b.Row),
+		cmp.Compare(a.Column, b.Column),
+	)
+}
+
+// distance returns the walking distance between the spot and the position, in rows and columns
+func distance(spot domain.ParkingSpot, position domain.Position) int {
+	return abs(spot.Floor-position.Floor)*floorDistance + abs(spot.Row-position.Row) + abs(spot.Column-position.Column)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"parking-lot/domain"
+)
+
+// allocatorCandidates are available spots on three floors, out of order
+var allocatorCandidates = []domain.ParkingSpot{
+	{ID: 1, Floor: 2, Row: 1, Column: 1},
+	{ID: 2, Floor: 1, Row: 2, Column: 3},
+	{ID: 3, Floor: 1, Row: 1, Column: 2},
+	{ID: 4, Floor: 3, Row: 1, Column: 1},
+	{ID: 5, Floor: 2, Row: 1, Column: 2},
+	{ID: 6, Floor: 2, Row: 2, Column: 1},
+}
+
+func TestSpotAllocatorRank(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy domain.AllocationStrategy
+		entrance *domain.Position
+		wantIDs  []int64
+	}{
+		{
+			name:     "first fit prefers the lowest floor, row and column",
+			strategy: domain.AllocationFirstFit,
+			wantIDs:  []int64{3, 2, 1, 5, 6, 4},
+		},
+		{
+			name:     "nearest to gate prefers the spots closest to the entrance",
+			strategy: domain.AllocationNearestToGate,
+			entrance: &domain.Position{Floor: 2, Row: 1, Column: 3},
+			wantIDs:  []int64{5, 1, 6, 3, 2, 4},
+		},
+		{
+			name:     "nearest to gate measures from the ground floor without an entrance",
+			strategy: domain.AllocationNearestToGate,
+			wantIDs:  []int64{3, 2, 1, 5, 6, 4},
+		},
+		{
+			name:     "balanced floors prefers the floor with the most available spots",
+			strategy: domain.AllocationBalanceFloors,
+			wantIDs:  []int64{1, 5, 6, 3, 2, 4},
+		},
+		{
+			name:     "highest floor first fills the highest floor before the lower ones",
+			strategy: domain.AllocationHighestFloorFirst,
+			wantIDs:  []int64{4, 1, 5, 6, 3, 2},
+		},
+		{
+			name:     "unknown strategies fall back to first fit",
+			strategy: "closest_to_exit",
+			wantIDs:  []int64{3, 2, 1, 5, 6, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates := slices.Clone(allocatorCandidates)
+			ranked := NewSpotAllocator(tt.strategy).Rank(candidates, domain.AllocationRequest{
+				VehicleType: domain.Car,
+				Entrance:    tt.entrance,
+			})
+
+			if got := spotIDs(ranked); !slices.Equal(got, tt.wantIDs) {
+				t.Errorf("got spots %v, want %v", got, tt.wantIDs)
+			}
+			if !slices.Equal(candidates, allocatorCandidates) {
+				t.Errorf("Rank() reordered the candidates it was given")
+			}
+		})
+	}
+}
+
+func TestRandomAllocatorRank(t *testing.T) {
+	allocator := NewSpotAllocator(domain.AllocationRandom)
+	wantIDs := slices.Sorted(slices.Values(spotIDs(allocatorCandidates)))
+
+	orders := make(map[string]bool)
+	for range 50 {
+		ranked := allocator.Rank(allocatorCandidates, domain.AllocationRequest{VehicleType: domain.Car})
+
+		got := spotIDs(ranked)
+		orders[fmt.Sprint(got)] = true
+		slices.Sort(got)
+		if !slices.Equal(got, wantIDs) {
+			t.Fatalf("got spots %v, want every candidate once", got)
+		}
+	}
+
+	// 50 identical orders of six spots are all but impossible when shuffled
+	if len(orders) < 2 {
+		t.Errorf("got the same order in every ranking, want the spots shuffled")
+	}
+}
+
+func spotIDs(spots []domain.ParkingSpot) []int64 {
+	ids := make([]int64, 0, len(spots))
+	for _, spot := range spots {
+		ids = append(ids, spot.ID)
+	}
+	return ids
+}
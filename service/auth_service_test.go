@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"parking-lot/domain"
+	"parking-lot/repository"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		keyName  string
+		role     domain.Role
+		wantCode string
+	}{
+		{
+			name:    "creates a key of a role",
+			keyName: "gate 1",
+			role:    domain.RoleGate,
+		},
+		{
+			name:     "rejects a key without a name",
+			keyName:  "  ",
+			role:     domain.RoleGate,
+			wantCode: domain.CodeInvalidAPIKey,
+		},
+		{
+			name:     "rejects an unknown role",
+			keyName:  "gate 1",
+			role:     "superuser",
+			wantCode: domain.CodeInvalidAPIKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			authService := NewAuthService(repository.NewMemoryAPIKeyRepository(), "")
+
+			apiKey, key, err := authService.CreateAPIKey(ctx, tt.keyName, tt.role)
+			if tt.wantCode != "" {
+				assertErrorCode(t, err, tt.wantCode)
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateAPIKey() error = %v", err)
+			}
+
+			if !strings.HasPrefix(key, apiKey.Prefix) || apiKey.KeyHash == "" || strings.Contains(apiKey.KeyHash, key) {
+				t.Errorf("got key %+v for %s, want only its prefix kept in the clear", apiKey, key)
+			}
+
+			authenticated, err := authService.Authenticate(ctx, key)
+			if err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+			if authenticated == nil || authenticated.ID != apiKey.ID || authenticated.Role != tt.role {
+				t.Errorf("got key %+v authenticated, want key %d of role %s", authenticated, apiKey.ID, tt.role)
+			}
+		})
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	ctx := context.Background()
+	authService := NewAuthService(repository.NewMemoryAPIKeyRepository(), "")
+	apiKey, key, err := authService.CreateAPIKey(ctx, "gate 1", domain.RoleGate)
+	if err != nil {
+		t.Fatalf("CreateAPIKey() error = %v", err)
+	}
+
+	err = authService.RevokeAPIKey(ctx, apiKey.ID)
+	if err != nil {
+		t.Fatalf("RevokeAPIKey() error = %v", err)
+	}
+
+	authenticated, err := authService.Authenticate(ctx, key)
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if authenticated != nil {
+		t.Errorf("got revoked key %+v authenticated, want it rejected", authenticated)
+	}
+
+	keys, err := authService.GetAPIKeys(ctx)
+	if err != nil {
+		t.Fatalf("GetAPIKeys() error = %v", err)
+	}
+	if len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Errorf("got keys %+v, want the key listed as revoked", keys)
+	}
+
+	err = authService.RevokeAPIKey(ctx, apiKey.ID)
+	assertErrorCode(t, err, domain.CodeAPIKeyNotFound)
+}
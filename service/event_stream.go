@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"parking-lot/config"
+	"parking-lot/domain"
+	"parking-lot/metrics"
+)
+
+const (
+	// subscriberBufferSize is how many events a subscriber may lag behind before it is dropped
+	subscriberBufferSize = 64
+	// changeSettleWindow is how long after a parking record was written its change is still picked up, so
+	// transactions committing late and instances whose clocks are slightly apart do not go unnoticed
+	changeSettleWindow = 30 * time.Second
+)
+
+// changeKey identifies a streamed change, a parking record is streamed once when taken and once when freed
+type changeKey struct {
+	recordID int64
+	freed    bool
+}
+
+type subscriber struct {
+	filter domain.StreamFilter
+	events chan domain.StreamEvent
+}
+
+// EventStream polls the parking records for the spots taken and freed by every instance sharing the storage,
+// and keeps the latest events in memory for the subscribers of this instance
+type EventStream struct {
+	parkingRepo  domain.ParkingRepository
+	statsRepo    domain.StatsRepository
+	bufferSize   int
+	pollInterval time.Duration
+	// wake makes Run poll right away after a change made by this instance
+	wake chan struct{}
+
+	// polledAt and streamed belong to Run: every poll looks up the changes written since polledAt minus the
+	// settle window, streamed holds those of them that were streamed already
+	polledAt time.Time
+	streamed map[changeKey]time.Time
+
+	// streamID tells events of this process apart from those of an earlier one, a client resuming with
+	// the ID of another stream gets a reset event
+	streamID string
+
+	mutex       sync.Mutex
+	lastSeq     int64
+	buffer      []domain.StreamEvent
+	subscribers map[*subscriber]struct{}
+	stopped     bool
+}
+
+func NewEventStream(parkingRepo domain.ParkingRepository, statsRepo domain.StatsRepository, streamConfig config.StreamConfig) *EventStream {
+	return &EventStream{
+		parkingRepo:  parkingRepo,
+		statsRepo:    statsRepo,
+		bufferSize:   streamConfig.BufferSize,
+		pollInterval: streamConfig.PollInterval,
+		wake:         make(chan struct{}, 1),
+		polledAt:     time.Now(),
+		streamed:     map[changeKey]time.Time{},
+		streamID:     strconv.FormatInt(time.Now().UnixNano(), 36),
+		subscribers:  map[*subscriber]struct{}{},
+	}
+}
+
+func (s *EventStream) SpotOccupied(ctx context.Context, spotID int64) {
+	s.pollNow()
+}
+
+func (s *EventStream) SpotFreed(ctx context.Context, spotID int64) {
+	s.pollNow()
+}
+
+// pollNow makes Run poll right away rather than at its next tick. Parks and unparks never wait for the
+// stream, changes made while a poll is pending are picked up by that poll.
+func (s *EventStream) pollNow() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run streams the spot changes until ctx is done, then ends every subscription
+func (s *EventStream) Run(ctx context.Context) {
+	defer s.stop()
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+		s.poll(ctx)
+	}
+}
+
+// poll streams the changes written since the last poll, and those written before it but committed since.
+// The changes are turned into events one at a time, so the capacities are streamed in the order they were
+// counted.
+func (s *EventStream) poll(ctx context.Context) {
+	polledAt := time.Now()
+	since := s.polledAt.Add(-changeSettleWindow)
+	changes, err := s.parkingRepo.GetSpotChanges(ctx, since)
+	if err != nil {
+		// the next poll looks up the same changes again
+		log.Printf("Failed to poll spot changes: %v\n", err)
+		return
+	}
+
+	missed := false
+	for _, change := range changes {
+		if change.Freed && change.TakenAt.After(since) {
+			// the vehicle may have parked and left since the last poll, its record was only seen freed
+			taken := domain.SpotChange{
+				RecordID:  change.RecordID,
+				SpotID:    change.SpotID,
+				ChangedAt: change.TakenAt,
+				TakenAt:   change.TakenAt,
+			}
+			if !s.streamOnce(ctx, taken) {
+				missed = true
+			}
+		}
+		if !s.streamOnce(ctx, change) {
+			missed = true
+		}
+	}
+	if missed {
+		// subscribers missed changes, they reload the state they show
+		s.publish(domain.StreamEvent{
+			Type:       domain.StreamReset,
+			OccurredAt: polledAt,
+		})
+	}
+
+	// forget the changes the next poll no longer looks up
+	s.polledAt = polledAt
+	for key, changedAt := range s.streamed {
+		if !changedAt.After(polledAt.Add(-changeSettleWindow)) {
+			delete(s.streamed, key)
+		}
+	}
+}
+
+// streamOnce streams the change unless it was streamed already, and reports whether it was streamed
+func (s *EventStream) streamOnce(ctx context.Context, change domain.SpotChange) bool {
+	key := changeKey{recordID: change.RecordID, freed: change.Freed}
+	if _, ok := s.streamed[key]; ok {
+		return true
+	}
+	s.streamed[key] = change.ChangedAt
+
+	err := s.streamChange(ctx, change)
+	if err != nil {
+		metrics.StreamEventsDropped.Inc()
+		log.Printf("Failed to stream change of spot %d: %v\n", change.SpotID, err)
+		return false
+	}
+	return true
+}
+
+// streamChange publishes the event of the change, followed by the new capacity of the spot's vehicle type
+// on its floor
+func (s *EventStream) streamChange(ctx context.Context, change domain.SpotChange) error {
+	spot, err := s.parkingRepo.GetSpotByID(ctx, change.SpotID)
+	if err != nil {
+		return fmt.Errorf("error getting parking spot: %w", err)
+	}
+	if spot == nil {
+		return fmt.Errorf("parking spot %d not found", change.SpotID)
+	}
+
+	eventType := domain.StreamSpotOccupied
+	if change.Freed {
+		eventType = domain.StreamSpotFreed
+	}
+	s.publish(domain.StreamEvent{
+		Type:        eventType,
+		Floor:       spot.Floor,
+		VehicleType: spot.VehicleType,
+		OccurredAt:  change.ChangedAt,
+		ParkingSpot: spot,
+	})
+
+	now := time.Now()
+	counts, err := s.statsRepo.GetOccupancyCounts(ctx, now)
+	if err != nil {
+		return fmt.Errorf("error getting occupancy counts: %w", err)
+	}
+	for _, count := range counts {
+		if count.Floor == spot.Floor && count.VehicleType == spot.VehicleType {
+			s.publish(domain.StreamEvent{
+				Type:        domain.StreamFloorCapacityChanged,
+				Floor:       count.Floor,
+				VehicleType: count.VehicleType,
+				OccurredAt:  now,
+				Capacity:    &count.SpotCounts,
+			})
+			break
+		}
+	}
+
+	return nil
+}
+
+// publish numbers the event, buffers it and sends it to the matching subscribers. Subscribers that fell
+// behind are dropped, they can resume from the buffer.
+func (s *EventStream) publish(event domain.StreamEvent) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lastSeq++
+	event.ID = s.eventID(s.lastSeq)
+
+	s.buffer = append(s.buffer, event)
+	if len(s.buffer) > s.bufferSize {
+		s.buffer = s.buffer[len(s.buffer)-s.bufferSize:]
+	}
+
+	for sub := range s.subscribers {
+		if !sub.filter.Matches(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			delete(s.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+func (s *EventStream) Subscribe(filter domain.StreamFilter, lastEventID string) ([]domain.StreamEvent, <-chan domain.StreamEvent, func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sub := &subscriber{
+		filter: filter,
+		events: make(chan domain.StreamEvent, subscriberBufferSize),
+	}
+	if s.stopped {
+		close(sub.events)
+		return nil, sub.events, func() {}
+	}
+	s.subscribers[sub] = struct{}{}
+
+	cancel := func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		if _, ok := s.subscribers[sub]; ok {
+			delete(s.subscribers, sub)
+			close(sub.events)
+		}
+	}
+
+	if lastEventID == "" {
+		return nil, sub.events, cancel
+	}
+
+	return s.replay(filter, lastEventID), sub.events, cancel
+}
+
+// replay returns the buffered events after lastEventID that match the filter, or a reset event if some of
+// them are no longer buffered
+func (s *EventStream) replay(filter domain.StreamFilter, lastEventID string) []domain.StreamEvent {
+	streamID, value, _ := strings.Cut(lastEventID, "-")
+	lastSeq, err := strconv.ParseInt(value, 10, 64)
+
+	// the buffer holds the events from firstSeq up to s.lastSeq
+	firstSeq := s.lastSeq - int64(len(s.buffer)) + 1
+	if streamID != s.streamID || err != nil || lastSeq < firstSeq-1 || lastSeq > s.lastSeq {
+		return []domain.StreamEvent{{
+			ID:         s.eventID(s.lastSeq),
+			Type:       domain.StreamReset,
+			OccurredAt: time.Now(),
+		}}
+	}
+
+	var replay []domain.StreamEvent
+	for _, event := range s.buffer[lastSeq-firstSeq+1:] {
+		if filter.Matches(event) {
+			replay = append(replay, event)
+		}
+	}
+	return replay
+}
+
+// stop ends every subscription and refuses new ones
+func (s *EventStream) stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.stopped = true
+	for sub := range s.subscribers {
+		delete(s.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+func (s *EventStream) eventID(seq int64) string {
+	return s.streamID + "-" + strconv.FormatInt(seq, 10)
+}
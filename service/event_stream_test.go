@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"parking-lot/config"
+	"parking-lot/domain"
+)
+
+// TestEventStreamStreamsSharedChanges streams the parks and unparks of a parking service that does not notify
+// the stream, like another instance sharing the storage
+func TestEventStreamStreamsSharedChanges(t *testing.T) {
+	tests := []struct {
+		name string
+		// pollAfterPark lets the stream poll between the park and the unpark
+		pollAfterPark bool
+	}{
+		{
+			name:          "streams a park and an unpark",
+			pollAfterPark: true,
+		},
+		{
+			name: "streams a vehicle parking and leaving between two polls",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachStorage(t, func(t *testing.T, storage *testStorage) {
+				ctx := context.Background()
+				storage.createSpots(t, 1, domain.Car, 2)
+				repos := storage.repositories()
+				stream := NewEventStream(repos.parking, repos.stats, config.StreamConfig{BufferSize: 100, PollInterval: time.Hour})
+				_, events, cancel := stream.Subscribe(domain.StreamFilter{}, "")
+				t.Cleanup(cancel)
+
+				parkingService := storage.newParkingService()
+				ticket := mustPark(t, parkingService, "AB123")
+				if tt.pollAfterPark {
+					stream.poll(ctx)
+					assertStreamEvent(t, events, domain.StreamSpotOccupied, ticket.ParkingSpotID)
+					assertStreamEvent(t, events, domain.StreamFloorCapacityChanged, 0)
+				}
+
+				_, err := parkingService.UnparkVehicle(ctx, domain.ParkingLookup{TicketCode: ticket.Code}, 0)
+				if err != nil {
+					t.Fatalf("UnparkVehicle() error = %v", err)
+				}
+				stream.poll(ctx)
+				if !tt.pollAfterPark {
+					assertStreamEvent(t, events, domain.StreamSpotOccupied, ticket.ParkingSpotID)
+					assertStreamEvent(t, events, domain.StreamFloorCapacityChanged, 0)
+				}
+				assertStreamEvent(t, events, domain.StreamSpotFreed, ticket.ParkingSpotID)
+				capacity := assertStreamEvent(t, events, domain.StreamFloorCapacityChanged, 0).Capacity
+				if capacity == nil || capacity.Free != 2 {
+					t.Errorf("got capacity %+v, want 2 free spots", capacity)
+				}
+
+				// later polls see the same records again, they must not be streamed twice
+				stream.poll(ctx)
+				select {
+				case event := <-events:
+					t.Errorf("got %s event after the unpark, want none", event.Type)
+				default:
+				}
+			})
+		})
+	}
+}
+
+// assertStreamEvent takes the next event and checks its type and, unless 0, the ID of its spot
+func assertStreamEvent(t *testing.T, events <-chan domain.StreamEvent, eventType domain.StreamEventType, spotID int64) domain.StreamEvent {
+	t.Helper()
+
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatalf("got the stream closed, want a %s event", eventType)
+		}
+		if event.Type != eventType {
+			t.Fatalf("got %s event, want %s", event.Type, eventType)
+		}
+		if spotID != 0 && (event.ParkingSpot == nil || event.ParkingSpot.ID != spotID) {
+			t.Fatalf("got %s event of spot %+v, want spot %d", event.Type, event.ParkingSpot, spotID)
+		}
+		return event
+	default:
+		t.Fatalf("got no event, want a %s event", eventType)
+	}
+	return domain.StreamEvent{}
+}
+
+func TestEventStreamResetsAfterMissedChanges(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, storage *testStorage) {
+		ctx := context.Background()
+		storage.createSpots(t, 1, domain.Car, 1)
+		repos := storage.repositories()
+		stream := NewEventStream(&unreadableSpotsRepository{repos.parking}, repos.stats, config.StreamConfig{BufferSize: 100, PollInterval: time.Hour})
+		_, events, cancel := stream.Subscribe(domain.StreamFilter{}, "")
+		t.Cleanup(cancel)
+
+		mustPark(t, storage.newParkingService(), "AB123")
+		stream.poll(ctx)
+
+		assertStreamEvent(t, events, domain.StreamReset, 0)
+	})
+}
+
+// unreadableSpotsRepository fails to read spots, so their changes cannot be streamed
+type unreadableSpotsRepository struct {
+	domain.ParkingRepository
+}
+
+func (r *unreadableSpotsRepository) GetSpotByID(ctx context.Context, id int64) (*domain.ParkingSpot, error) {
+	return nil, errors.New("database is gone")
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"parking-lot/config"
+	"parking-lot/domain"
+)
+
+// pingTimeout bounds the database ping, so a hanging database fails readiness instead of the probe
+const pingTimeout = 2 * time.Second
+
+type healthService struct {
+	// db is nil for the memory storage
+	db          *sql.DB
+	parkingRepo domain.ParkingRepository
+}
+
+// NewHealthService returns a health service checking the database, its schema and the parking spots.
+// db is nil when the data is kept in memory, which skips the database checks.
+func NewHealthService(db *sql.DB, parkingRepo domain.ParkingRepository) domain.HealthService {
+	return &healthService{
+		db:          db,
+		parkingRepo: parkingRepo,
+	}
+}
+
+func (s *healthService) CheckReadiness(ctx context.Context) (bool, []domain.HealthCheck) {
+	type check struct {
+		name string
+		run  func(context.Context) error
+	}
+	checks := []check{{"spots", s.checkSpots}}
+	if s.db != nil {
+		// each check relies on the ones before it, later checks are skipped once one fails
+		checks = []check{{"database", s.checkDatabase}, {"schema", s.checkSchema}, {"spots", s.checkSpots}}
+	}
+
+	ready := true
+	results := make([]domain.HealthCheck, 0, len(checks))
+	for _, check := range checks {
+		result := domain.HealthCheck{Name: check.name, Healthy: true}
+		if !ready {
+			result.Healthy = false
+			result.Message = "skipped after a failed check"
+		} else if err := check.run(ctx); err != nil {
+			result.Healthy = false
+			result.Message = err.Error()
+		}
+
+		if !result.Healthy {
+			ready = false
+		}
+		results = append(results, result)
+	}
+
+	return ready, results
+}
+
+// checkDatabase fails when every pooled connection is in use or the database does not answer a ping
+func (s *healthService) checkDatabase(ctx context.Context) error {
+	stats := s.db.Stats()
+	if stats.MaxOpenConnections > 0 && stats.InUse >= stats.MaxOpenConnections {
+		return fmt.Errorf("connection pool exhausted, %d of %d connections in use", stats.InUse, stats.MaxOpenConnections)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	err := s.db.PingContext(ctx)
+	if err != nil {
+		return fmt.Errorf("database unreachable: %w", err)
+	}
+
+	return nil
+}
+
+func (s *healthService) checkSchema(context.Context) error {
+	return config.CheckSchemaVersion(s.db)
+}
+
+func (s *healthService) checkSpots(ctx context.Context) error {
+	count, err := s.parkingRepo.CountActiveSpots(ctx)
+	if err != nil {
+		return fmt.Errorf("error counting parking spots: %w", err)
+	}
+
+	if count == 0 {
+		return errors.New("no active parking spots, run the migrations to create them")
+	}
+
+	return nil
+}
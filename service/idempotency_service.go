@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"parking-lot/domain"
+)
+
+// idempotencyLeaseSlack is added to the request timeout for the lease of a request in progress, it covers
+// storing the response after the deadline
+const idempotencyLeaseSlack = 30 * time.Second
+
+type idempotencyService struct {
+	idempotencyRepo domain.IdempotencyRepository
+	ttl             time.Duration
+	lease           time.Duration
+}
+
+// NewIdempotencyService returns an idempotency service replaying responses for ttl after the first request.
+// A retry takes over the key of a first request that has not finished within the request timeout, without
+// a request timeout the key stays in progress for the ttl.
+func NewIdempotencyService(idempotencyRepo domain.IdempotencyRepository, ttl, requestTimeout time.Duration) domain.IdempotencyService {
+	lease := ttl
+	if requestTimeout > 0 {
+		lease = requestTimeout + idempotencyLeaseSlack
+	}
+
+	return &idempotencyService{
+		idempotencyRepo: idempotencyRepo,
+		ttl:             ttl,
+		lease:           lease,
+	}
+}
+
+func (s *idempotencyService) StartRequest(ctx context.Context, apiKeyID int64, key, requestHash string) (*domain.IdempotencyRecord, error) {
+	now := time.Now()
+	existing, err := s.idempotencyRepo.ReserveIdempotencyKey(ctx, &domain.IdempotencyRecord{
+		APIKeyID:       apiKeyID,
+		Key:            key,
+		RequestHash:    requestHash,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(s.ttl),
+		LeaseExpiresAt: now.Add(s.lease),
+	}, now)
+	if err != nil {
+		return nil, fmt.Errorf("error reserving idempotency key: %w", err)
+	}
+
+	if existing == nil {
+		return nil, nil
+	}
+	if existing.RequestHash != requestHash {
+		return nil, domain.NewValidationError(domain.CodeIdempotencyKeyReused, "idempotency key %q was already used for a different request", key)
+	}
+	if existing.StatusCode == 0 {
+		return nil, domain.NewConflictError(domain.CodeIdempotencyInProgress, "a request with idempotency key %q is still in progress, retry later", key)
+	}
+
+	return existing, nil
+}
+
+func (s *idempotencyService) FinishRequest(ctx context.Context, apiKeyID int64, key string, statusCode int, responseBody []byte) error {
+	// a failed request may succeed when retried, release the key instead of replaying the failure
+	if statusCode >= http.StatusInternalServerError {
+		err := s.idempotencyRepo.DeleteIdempotencyKey(ctx, apiKeyID, key)
+		if err != nil {
+			return fmt.Errorf("error releasing idempotency key: %w", err)
+		}
+		return nil
+	}
+
+	err := s.idempotencyRepo.CompleteIdempotencyKey(ctx, apiKeyID, key, statusCode, responseBody)
+	if err != nil {
+		return fmt.Errorf("error storing idempotent response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"parking-lot/config"
+	"parking-lot/domain"
+)
+
+type layoutService struct {
+	parkingRepo domain.ParkingRepository
+}
+
+func NewLayoutService(parkingRepo domain.ParkingRepository) domain.LayoutService {
+	return &layoutService{
+		parkingRepo: parkingRepo,
+	}
+}
+
+// ReconcileLayout brings the parking spots in line with the configured floors, rows and columns.
+// Missing spots are created, inactive spots that are back in the layout are reactivated, and spots
+// no longer in the layout are deactivated instead of deleted so their history is kept. Occupied spots
+// are never deactivated. Spots whose vehicle type or size class changed are retyped, a vehicle parked
+// on one keeps its spot. With dryRun set, the changes are computed but not applied.
+func (s *layoutService) ReconcileLayout(ctx context.Context, dryRun bool) (*domain.LayoutDiff, error) {
+	parkingConfig := config.GetAppConfig().Parking
+
+	spots, err := s.parkingRepo.GetAllSpots(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting parking spots: %w", err)
+	}
+
+	existing := make(map[[3]int]domain.ParkingSpot, len(spots))
+	for _, spot := range spots {
+		existing[[3]int{spot.Floor, spot.Row, spot.Column}] = spot
+	}
+
+	diff := &domain.LayoutDiff{}
+
+	// create or reactivate every spot of the configured layout
+	for f := 1; f <= parkingConfig.Floors; f++ {
+		for r := 1; r <= parkingConfig.Rows; r++ {
+			for c := 1; c <= parkingConfig.Columns; c++ {
+				position := [3]int{f, r, c}
+				spot, ok := existing[position]
+				delete(existing, position)
+
+				vehicleType, sizeClass := parkingConfig.SpotType(f, r, c)
+				if ok && (spot.VehicleType != vehicleType || spot.SizeClass != sizeClass) {
+					if !dryRun {
+						err = s.parkingRepo.UpdateSpotType(ctx, spot.ID, vehicleType, sizeClass)
+						if err != nil {
+							return nil, fmt.Errorf("error retyping parking spot %d-%d-%d: %w", f, r, c, err)
+						}
+					}
+					spot.VehicleType, spot.SizeClass = vehicleType, sizeClass
+					diff.Retyped = append(diff.Retyped, spot)
+				}
+
+				switch {
+				case !ok:
+					spot = domain.ParkingSpot{
+						Floor:       f,
+						Row:         r,
+						Column:      c,
+						VehicleType: vehicleType,
+						SizeClass:   sizeClass,
+						IsActive:    true,
+					}
+					if !dryRun {
+						err = s.parkingRepo.CreateSpot(ctx, &spot)
+						if err != nil {
+							return nil, fmt.Errorf("error creating parking spot %d-%d-%d: %w", f, r, c, err)
+						}
+					}
+					diff.Added = append(diff.Added, spot)
+				case !spot.IsActive:
+					if !dryRun {
+						err = s.parkingRepo.UpdateSpotStatus(ctx, spot.ID, true)
+						if err != nil {
+							return nil, fmt.Errorf("error reactivating parking spot %d-%d-%d: %w", f, r, c, err)
+						}
+					}
+					spot.IsActive = true
+					diff.Reactivated = append(diff.Reactivated, spot)
+				}
+			}
+		}
+	}
+
+	// in a dry run, free spots are the active ones without a parked vehicle
+	freeSpots := make(map[int64]bool)
+	if dryRun {
+		availableSpots, err := s.parkingRepo.GetAvailableSpots(ctx, domain.SpotFilter{})
+		if err != nil {
+			return nil, fmt.Errorf("error getting available spots: %w", err)
+		}
+		for _, spot := range availableSpots {
+			freeSpots[spot.ID] = true
+		}
+	}
+
+	// whatever is left is outside the layout, deactivate it unless a vehicle is parked there
+	for _, spot := range spots {
+		spot, ok := existing[[3]int{spot.Floor, spot.Row, spot.Column}]
+		if !ok || !spot.IsActive {
+			continue
+		}
+
+		deactivated := freeSpots[spot.ID]
+		if !dryRun {
+			deactivated, err = s.parkingRepo.DeactivateSpotIfFree(ctx, spot.ID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error deactivating parking spot %d-%d-%d: %w", spot.Floor, spot.Row, spot.Column, err)
+		}
+
+		if deactivated {
+			spot.IsActive = false
+			diff.Deactivated = append(diff.Deactivated, spot)
+		} else {
+			diff.Occupied = append(diff.Occupied, spot)
+		}
+	}
+
+	if !dryRun {
+		log.Printf(
+			"Reconciled parking layout (%d floors, %d rows, %d columns): %d added, %d reactivated, %d deactivated, %d retyped, %d occupied kept\n",
+			parkingConfig.Floors, parkingConfig.Rows, parkingConfig.Columns,
+			len(diff.Added), len(diff.Reactivated), len(diff.Deactivated), len(diff.Retyped), len(diff.Occupied),
+		)
+	}
+
+	return diff, nil
+}
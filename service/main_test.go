@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"parking-lot/config"
+	"parking-lot/domain"
+	"parking-lot/repository"
+)
+
+func TestMain(m *testing.M) {
+	// The configuration is read once per process, every test runs with these settings. The migrations
+	// are those of the configured driver, so a Postgres test database must be migrated beforehand.
+	os.Setenv("STORAGE_DRIVER", config.StorageDriverSQLite)
+	os.Setenv("VEHICLE_TYPES", "car,motorcycle")
+	os.Setenv("PARKING_SIZE_CLASS_FALLBACK", "false")
+	os.Setenv("PARKING_GATES", "3")
+	os.Setenv("PARKING_GATE_1_DIRECTION", string(domain.GateBoth))
+	os.Setenv("PARKING_GATE_2_DIRECTION", string(domain.GateEntry))
+	os.Setenv("PARKING_GATE_3_DIRECTION", string(domain.GateExit))
+
+	os.Exit(m.Run())
+}
+
+// testStorage is a database shared by every service created from it, like replicas sharing a database
+type testStorage struct {
+	driver string
+	// db is nil for the memory storage
+	db *sql.DB
+	// memory holds the repositories of the memory storage, which are its database
+	memory testRepositories
+}
+
+type testRepositories struct {
+	parking     domain.ParkingRepository
+	vehicle     domain.VehicleRepository
+	reservation domain.ReservationRepository
+	webhook     domain.WebhookRepository
+	stats       domain.StatsRepository
+	transactor  domain.Transactor
+}
+
+// forEachStorage runs the test on an empty memory and SQLite storage, and on the Postgres database at
+// TEST_DATABASE_URL if set, which is emptied first
+func forEachStorage(t *testing.T, test func(t *testing.T, storage *testStorage)) {
+	t.Run(config.StorageDriverMemory, func(t *testing.T) {
+		test(t, newMemoryStorage())
+	})
+
+	t.Run(config.StorageDriverSQLite, func(t *testing.T) {
+		test(t, &testStorage{
+			driver: config.StorageDriverSQLite,
+			db:     openSQLite(t),
+		})
+	})
+
+	t.Run(config.StorageDriverPostgres, func(t *testing.T) {
+		databaseURL := os.Getenv("TEST_DATABASE_URL")
+		if databaseURL == "" {
+			t.Skip("TEST_DATABASE_URL is not set")
+		}
+		test(t, &testStorage{
+			driver: config.StorageDriverPostgres,
+			db:     openPostgres(t, databaseURL),
+		})
+	})
+}
+
+// newMemoryStorage returns an empty memory storage
+func newMemoryStorage() *testStorage {
+	parkingRepo := repository.NewMemoryParkingRepository()
+	return &testStorage{
+		driver: config.StorageDriverMemory,
+		memory: testRepositories{
+			parking:     parkingRepo,
+			vehicle:     repository.NewMemoryVehicleRepository(parkingRepo),
+			reservation: repository.NewMemoryReservationRepository(parkingRepo),
+			webhook:     repository.NewMemoryWebhookRepository(),
+			stats:       repository.NewMemoryStatsRepository(parkingRepo),
+			transactor:  repository.NewMemoryTransactor(),
+		},
+	}
+}
+
+// openSQLite returns a migrated SQLite database in a temporary file, opened like config.InitDBConnection does
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+
+	connStr := fmt.Sprintf(
+		"file:%s?_txlock=immediate&_time_format=sqlite&_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)",
+		filepath.Join(t.TempDir(), "parking_lot.db"),
+	)
+	db, err := sql.Open("sqlite", connStr)
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = config.MigrateUp(db)
+	if err != nil {
+		t.Fatalf("error migrating database: %v", err)
+	}
+
+	return db
+}
+
+// openPostgres connects to the migrated database and removes its spots, vehicles, webhooks and everything
+// referencing them
+func openPostgres(t *testing.T, databaseURL string) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = config.CheckSchemaVersion(db)
+	if err != nil {
+		t.Fatalf("error checking schema, migrate the test database first: %v", err)
+	}
+
+	_, err = db.Exec(`TRUNCATE parking_spots, vehicles, webhooks, webhook_outbox RESTART IDENTITY CASCADE`)
+	if err != nil {
+		t.Fatalf("error emptying database: %v", err)
+	}
+
+	return db
+}
+
+// repositories returns new repositories on the storage's database, or the repositories of the memory storage
+func (s *testStorage) repositories() testRepositories {
+	switch s.driver {
+	case config.StorageDriverMemory:
+		return s.memory
+	case config.StorageDriverSQLite:
+		return testRepositories{
+			parking:     repository.NewSQLiteParkingRepository(s.db),
+			vehicle:     repository.NewVehicleRepository(s.db),
+			reservation: repository.NewSQLiteReservationRepository(s.db),
+			webhook:     repository.NewSQLiteWebhookRepository(s.db),
+			stats:       repository.NewStatsRepository(s.db),
+			transactor:  repository.NewTransactor(s.db),
+		}
+	default:
+		return testRepositories{
+			parking:     repository.NewParkingRepository(s.db),
+			vehicle:     repository.NewVehicleRepository(s.db),
+			reservation: repository.NewReservationRepository(s.db),
+			webhook:     repository.NewWebhookRepository(s.db),
+			stats:       repository.NewStatsRepository(s.db),
+			transactor:  repository.NewTransactor(s.db),
+		}
+	}
+}
+
+// newParkingService returns a parking service with its own repositories on the storage
+func (s *testStorage) newParkingService() domain.ParkingService {
+	repos := s.repositories()
+	return NewParkingService(
+		repos.parking,
+		repos.vehicle,
+		repos.reservation,
+		NewSpotAllocator(domain.AllocationFirstFit),
+		repos.transactor,
+		NewWebhookService(repos.webhook),
+		nopSpotNotifier{},
+	)
+}
+
+// createSpots creates count active spots for the vehicle type, of its default size class, in the first row
+// of the floor
+func (s *testStorage) createSpots(t *testing.T, floor int, vehicleType domain.VehicleType, count int) {
+	t.Helper()
+
+	parkingRepo := s.repositories().parking
+	for column := 1; column <= count; column++ {
+		err := parkingRepo.CreateSpot(context.Background(), &domain.ParkingSpot{
+			Floor:       floor,
+			Row:         1,
+			Column:      column,
+			VehicleType: vehicleType,
+			SizeClass:   config.GetAppConfig().VehicleTypes.DefaultSizeClass(vehicleType),
+			IsActive:    true,
+		})
+		if err != nil {
+			t.Fatalf("error creating spot: %v", err)
+		}
+	}
+}
+
+type nopSpotNotifier struct{}
+
+func (nopSpotNotifier) SpotOccupied(ctx context.Context, spotID int64) {}
+
+func (nopSpotNotifier) SpotFreed(ctx context.Context, spotID int64) {}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"parking-lot/domain"
+)
+
+type maintenanceService struct {
+	maintenanceRepo domain.MaintenanceRepository
+	transactor      domain.Transactor
+	events          domain.EventPublisher
+}
+
+func NewMaintenanceService(
+	maintenanceRepo domain.MaintenanceRepository,
+	transactor domain.Transactor,
+	events domain.EventPublisher,
+) domain.MaintenanceService {
+	return &maintenanceService{
+		maintenanceRepo: maintenanceRepo,
+		transactor:      transactor,
+		events:          events,
+	}
+}
+
+func (s *maintenanceService) StartMaintenance(
+	ctx context.Context,
+	selector domain.SpotSelector,
+	reason string,
+	endTime *time.Time,
+	force bool,
+) ([]domain.SpotMaintenance, error) {
+	err := validateSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	if endTime != nil && !endTime.After(time.Now()) {
+		return nil, domain.NewValidationError(domain.CodeInvalidMaintenance, "maintenance end time must be in the future")
+	}
+
+	var (
+		maintenances []domain.SpotMaintenance
+		occupied     []domain.ParkingSpot
+	)
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		maintenances, occupied, err = s.maintenanceRepo.StartMaintenance(ctx, selector, reason, endTime, force)
+		if err != nil {
+			return fmt.Errorf("error starting maintenance: %w", err)
+		}
+
+		for _, maintenance := range maintenances {
+			event := domain.SpotDisabledEvent{
+				Reason:    maintenance.Reason,
+				StartTime: maintenance.StartTime,
+				EndTime:   maintenance.EndTime,
+			}
+			if maintenance.ParkingSpot != nil {
+				event.ParkingSpot = *maintenance.ParkingSpot
+			}
+
+			err = s.events.Publish(ctx, domain.EventSpotDisabled, event)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(occupied) > 0 {
+		spotIDs := make([]string, len(occupied))
+		for i, spot := range occupied {
+			spotIDs[i] = fmt.Sprintf("%d-%d-%d", spot.Floor, spot.Row, spot.Column)
+		}
+		return nil, domain.NewConflictError(domain.CodeSpotOccupied, "vehicles are parked on %s, use force to close them anyway", strings.Join(spotIDs, ", "))
+	}
+
+	if len(maintenances) == 0 {
+		return nil, domain.NewNotFoundError(domain.CodeSpotNotFound, "no active parking spots found for %s", selector)
+	}
+
+	return maintenances, nil
+}
+
+func (s *maintenanceService) EndMaintenance(ctx context.Context, selector domain.SpotSelector) (int64, error) {
+	err := validateSelector(selector)
+	if err != nil {
+		return 0, err
+	}
+
+	ended, err := s.maintenanceRepo.EndMaintenance(ctx, selector)
+	if err != nil {
+		return 0, fmt.Errorf("error ending maintenance: %w", err)
+	}
+
+	return ended, nil
+}
+
+func (s *maintenanceService) GetActiveMaintenances(ctx context.Context) ([]domain.SpotMaintenance, error) {
+	maintenances, err := s.maintenanceRepo.GetActiveMaintenances(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting maintenances: %w", err)
+	}
+	return maintenances, nil
+}
+
+// validateSelector checks that the selector names a floor, and a row when it names a column
+func validateSelector(selector domain.SpotSelector) error {
+	if selector.Floor < 1 || selector.Row < 0 || selector.Column < 0 {
+		return domain.NewValidationError(domain.CodeInvalidMaintenance, "floor must be positive, row and column must not be negative")
+	}
+	if selector.Row == 0 && selector.Column != 0 {
+		return domain.NewValidationError(domain.CodeInvalidMaintenance, "row is required when column is given")
+	}
+	return nil
+}
@@ -1,169 +1,676 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/base32"
 	"errors"
 	"fmt"
-	"sync"
 	"time"
 
 	"parking-lot/config"
 	"parking-lot/domain"
+	"parking-lot/metrics"
 )
 
+const (
+	// maxClaimRounds bounds how often a park is retried after concurrent claims took every ranked candidate
+	maxClaimRounds = 4
+	// claimRetryBackoff is the wait before retrying a park, doubled on every further round
+	claimRetryBackoff = 10 * time.Millisecond
+)
+
+// errSpotsTaken is returned when concurrent claims took every ranked spot, the park is retried in a new
+// transaction once they had time to finish
+var errSpotsTaken = errors.New("concurrent claims took every available spot")
+
 type parkingService struct {
-	parkingRepo domain.ParkingRepository
-	vehicleRepo domain.VehicleRepository
-	mutex       *sync.Mutex
+	parkingRepo     domain.ParkingRepository
+	vehicleRepo     domain.VehicleRepository
+	reservationRepo domain.ReservationRepository
+	allocator       domain.SpotAllocator
+	transactor      domain.Transactor
+	events          domain.EventPublisher
+	notifier        domain.SpotNotifier
 }
 
 func NewParkingService(
 	parkingRepo domain.ParkingRepository,
 	vehicleRepo domain.VehicleRepository,
+	reservationRepo domain.ReservationRepository,
+	allocator domain.SpotAllocator,
+	transactor domain.Transactor,
+	events domain.EventPublisher,
+	notifier domain.SpotNotifier,
 ) domain.ParkingService {
 	return &parkingService{
-		parkingRepo: parkingRepo,
-		vehicleRepo: vehicleRepo,
-		mutex:       &sync.Mutex{},
+		parkingRepo:     parkingRepo,
+		vehicleRepo:     vehicleRepo,
+		reservationRepo: reservationRepo,
+		allocator:       allocator,
+		transactor:      transactor,
+		events:          events,
+		notifier:        notifier,
 	}
 }
 
-func (s *parkingService) ParkVehicle(licensePlate string, vehicleType domain.VehicleType) (*domain.ParkingSpot, error) {
-	// Check if the vehicle is already parked
-	vehicle, err := s.vehicleRepo.GetVehicleByLicensePlate(licensePlate)
+func (s *parkingService) ParkVehicle(
+	ctx context.Context,
+	licensePlate string,
+	vehicleType domain.VehicleType,
+	sizeClass domain.SizeClass,
+	gateID int64,
+) (*domain.ParkingSpot, *domain.Ticket, error) {
+	spot, ticket, err := s.parkVehicle(ctx, licensePlate, vehicleType, sizeClass, gateID)
+	metrics.ParkAttempts.WithLabelValues(vehicleTypeLabel(vehicleType), metrics.Outcome(err)).Inc()
+	return spot, ticket, err
+}
+
+func (s *parkingService) parkVehicle(
+	ctx context.Context,
+	licensePlate string,
+	vehicleType domain.VehicleType,
+	sizeClass domain.SizeClass,
+	gateID int64,
+) (*domain.ParkingSpot, *domain.Ticket, error) {
+	vehicleTypes := config.GetAppConfig().VehicleTypes
+	if !vehicleTypes.IsValid(vehicleType) {
+		return nil, nil, domain.NewValidationError(domain.CodeInvalidVehicleType, "invalid vehicle type %s, must be %v", vehicleType, vehicleTypes)
+	}
+
+	if licensePlate == "" && vehicleTypes.RequiresLicensePlate(vehicleType) {
+		return nil, nil, domain.NewValidationError(domain.CodeLicensePlateRequired, "license plate is required for vehicle type %s", vehicleType)
+	}
+
+	if sizeClass == "" {
+		sizeClass = vehicleTypes.DefaultSizeClass(vehicleType)
+	}
+
+	gate, err := findGate(gateID)
 	if err != nil {
-		return nil, fmt.Errorf("error getting vehicle: %w", err)
+		return nil, nil, err
+	}
+
+	if gate != nil && !gate.AllowsEntry() {
+		return nil, nil, domain.NewValidationError(domain.CodeGateDirection, "gate %d is exit only", gate.ID)
+	}
+
+	// Check if the vehicle is already parked, vehicles without a license plate are always new
+	var vehicle *domain.Vehicle
+	if licensePlate != "" {
+		vehicle, err = s.vehicleRepo.GetVehicleByLicensePlate(ctx, licensePlate)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error getting vehicle: %w", err)
+		}
 	}
 
-	// Create vehicle if it doesn't exist
 	if vehicle == nil {
+		// A new vehicle is created together with its parking record when a spot is claimed,
+		// so a park that fails or is interrupted leaves no vehicle behind
 		vehicle = &domain.Vehicle{
 			LicensePlate: licensePlate,
 			Type:         vehicleType,
 		}
-		err = s.vehicleRepo.CreateVehicle(vehicle)
+	} else {
+		lastRecord, err := s.parkingRepo.GetLastParkingRecordByVehicleID(ctx, vehicle.ID)
 		if err != nil {
-			return nil, fmt.Errorf("error creating vehicle: %w", err)
+			return nil, nil, fmt.Errorf("error getting last parking record: %w", err)
+		}
+
+		if lastRecord != nil && lastRecord.IsParked() {
+			spot, err := s.parkingRepo.GetSpotByID(ctx, lastRecord.ParkingSpotID)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error getting parking spot: %w", err)
+			}
+			return nil, nil, domain.NewConflictError(domain.CodeVehicleAlreadyParked, "vehicle is already parked at spot %d-%d-%d", spot.Floor, spot.Row, spot.Column)
+		}
+	}
+
+	ticketCode, err := generateTicketCode()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error generating ticket code: %w", err)
+	}
+
+	record := &domain.ParkingRecord{
+		EntryTime:  time.Now(),
+		TicketCode: ticketCode,
+	}
+
+	request := domain.AllocationRequest{
+		VehicleType: vehicleType,
+		SizeClass:   sizeClass,
+	}
+	if gate != nil {
+		record.EntryGateID = sql.NullInt64{
+			Int64: gate.ID,
+			Valid: true,
 		}
+		request.Entrance = &gate.Position
 	}
 
-	// Check if the vehicle is already parked
-	lastRecord, err := s.parkingRepo.GetLastParkingRecordByVehicleID(vehicle.ID)
+	spot, err := s.parkWithRetries(ctx, vehicle, vehicleType, record, request)
 	if err != nil {
-		return nil, fmt.Errorf("error getting last parking record: %w", err)
+		return nil, nil, err
+	}
+
+	if spot == nil {
+		return nil, nil, domain.NewCapacityExhaustedError(domain.CodeNoAvailableSpots, "no available parking spots")
+	}
+	s.notifier.SpotOccupied(ctx, spot.ID)
+
+	ticket := &domain.Ticket{
+		Code:          record.TicketCode,
+		LicensePlate:  vehicle.LicensePlate,
+		ParkingSpotID: spot.ID,
+		IssuedAt:      record.EntryTime,
+	}
+
+	return spot, ticket, nil
+}
+
+// publishParked publishes that the vehicle parked on the spot, and that the lot is full for its vehicle type
+// if it took the last free spot
+func (s *parkingService) publishParked(
+	ctx context.Context,
+	vehicle *domain.Vehicle,
+	vehicleType domain.VehicleType,
+	record *domain.ParkingRecord,
+	spot *domain.ParkingSpot,
+) error {
+	event := domain.VehicleParkedEvent{
+		LicensePlate: vehicle.LicensePlate,
+		VehicleType:  vehicleType,
+		TicketCode:   record.TicketCode,
+		ParkingSpot:  *spot,
+		EntryTime:    record.EntryTime,
+	}
+	if record.EntryGateID.Valid {
+		event.EntryGateID = &record.EntryGateID.Int64
+	}
+
+	err := s.events.Publish(ctx, domain.EventVehicleParked, event)
+	if err != nil {
+		return err
+	}
+
+	available, err := s.parkingRepo.GetAvailableSpots(ctx, domain.SpotFilter{VehicleType: vehicleType})
+	if err != nil {
+		return fmt.Errorf("error getting available spots: %w", err)
+	}
+	if len(available) > 0 {
+		return nil
 	}
 
-	if lastRecord.IsParked() {
-		spot, err := s.parkingRepo.GetSpotByID(lastRecord.ParkingSpotID)
+	return s.events.Publish(ctx, domain.EventLotFull, domain.LotFullEvent{VehicleType: vehicleType})
+}
+
+// parkWithRetries parks the vehicle in a transaction, and retries in a new transaction while concurrent claims
+// took every ranked spot. The wait happens between transactions, so it holds no connection or lock.
+// It returns nil if no spot is available.
+func (s *parkingService) parkWithRetries(
+	ctx context.Context,
+	vehicle *domain.Vehicle,
+	vehicleType domain.VehicleType,
+	record *domain.ParkingRecord,
+	request domain.AllocationRequest,
+) (spot *domain.ParkingSpot, err error) {
+	start := time.Now()
+	defer func() {
+		outcome := metrics.Outcome(err)
+		if err == nil && spot == nil {
+			outcome = domain.CodeNoAvailableSpots
+		}
+		metrics.SpotClaimDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+	}()
+
+	for round := range maxClaimRounds {
+		if round > 0 {
+			// spots locked by uncommitted claims are still listed as available, give those claims time to finish
+			metrics.SpotClaimRetries.Inc()
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(claimRetryBackoff << (round - 1)):
+			}
+		}
+
+		spot, err = s.park(ctx, vehicle, vehicleType, record, request, round == maxClaimRounds-1)
+		if !errors.Is(err, errSpotsTaken) {
+			return spot, err
+		}
+	}
+
+	return nil, err
+}
+
+// park parks the vehicle on its reserved spot or on the most preferred free spot in a single transaction,
+// so concurrent gates (even on other replicas) can never be assigned the same spot. The events are published
+// in the same transaction, so they are only sent if the vehicle parked. It returns errSpotsTaken if concurrent
+// claims took every ranked spot, unless it is the last round.
+func (s *parkingService) park(
+	ctx context.Context,
+	vehicle *domain.Vehicle,
+	vehicleType domain.VehicleType,
+	record *domain.ParkingRecord,
+	request domain.AllocationRequest,
+	lastRound bool,
+) (*domain.ParkingSpot, error) {
+	var spot *domain.ParkingSpot
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Park on the reserved spot if the vehicle has a reservation for now
+		var err error
+		spot, err = s.claimReservedSpot(ctx, vehicle, record, vehicleType)
 		if err != nil {
-			return nil, fmt.Errorf("error getting parking spot: %w", err)
+			return err
+		}
+
+		if spot == nil {
+			spot, err = s.claimSpot(ctx, vehicle, record, request, lastRound)
+		}
+		if err != nil {
+			if errors.Is(err, domain.ErrVehicleAlreadyParked) || errors.Is(err, errSpotsTaken) {
+				return err
+			}
+			return fmt.Errorf("error claiming parking spot: %w", err)
+		}
+
+		if spot == nil {
+			return nil
+		}
+		return s.publishParked(ctx, vehicle, vehicleType, record, spot)
+	})
+
+	return spot, err
+}
+
+// claimSpot claims a spot of the requested size class, or of a larger one if allowed and none is free.
+// It returns nil if no spot is available, and errSpotsTaken if concurrent claims took every ranked spot of
+// a size class. In the last round such a size class counts as full.
+func (s *parkingService) claimSpot(ctx context.Context, vehicle *domain.Vehicle, record *domain.ParkingRecord, request domain.AllocationRequest, lastRound bool) (*domain.ParkingSpot, error) {
+	for _, sizeClass := range allowedSizeClasses(request.SizeClass) {
+		spot, err := s.claimSpotOfSizeClass(ctx, vehicle, record, request, sizeClass)
+		if errors.Is(err, errSpotsTaken) && lastRound {
+			continue
+		}
+		if err != nil || spot != nil {
+			return spot, err
 		}
-		return nil, fmt.Errorf("vehicle is already parked at spot %d-%d-%d", spot.Floor, spot.Row, spot.Column)
 	}
 
-	// Use mutex to prevent race conditions when finding available spots
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	return nil, nil
+}
 
-	// get floors based on vehicle type
-	var floors []int
-	parkingConfig := config.GetAppConfig().Parking
-	for floor, t := range parkingConfig.FloorVehicleMap {
-		if t == vehicleType {
-			floors = append(floors, floor)
+// claimSpotOfSizeClass ranks the available spots of the size class with the configured allocator and
+// claims the first one that is still free. It returns nil if no such spot is available, and errSpotsTaken
+// if concurrent claims took every candidate.
+func (s *parkingService) claimSpotOfSizeClass(
+	ctx context.Context,
+	vehicle *domain.Vehicle,
+	record *domain.ParkingRecord,
+	request domain.AllocationRequest,
+	sizeClass domain.SizeClass,
+) (*domain.ParkingSpot, error) {
+	filter := domain.SpotFilter{
+		VehicleType: request.VehicleType,
+		SizeClasses: []domain.SizeClass{sizeClass},
+	}
+
+	candidates, err := s.parkingRepo.GetAvailableSpots(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	ranked := s.allocator.Rank(candidates, request)
+	candidateIDs := make([]int64, 0, len(ranked))
+	for _, spot := range ranked {
+		candidateIDs = append(candidateIDs, spot.ID)
+	}
+
+	spot, err := s.parkingRepo.ClaimSpot(ctx, vehicle, record, candidateIDs)
+	if err != nil || spot != nil {
+		return spot, err
+	}
+
+	// every candidate was taken by concurrent gates in the meantime
+	return nil, errSpotsTaken
+}
+
+// claimReservedSpot parks the vehicle on its reserved spot if it has a reservation that can be claimed now.
+// It returns nil if there is no such reservation or the reserved spot is unavailable.
+func (s *parkingService) claimReservedSpot(ctx context.Context, vehicle *domain.Vehicle, record *domain.ParkingRecord, vehicleType domain.VehicleType) (*domain.ParkingSpot, error) {
+	if vehicle.LicensePlate == "" {
+		return nil, nil
+	}
+
+	reservations, err := s.reservationRepo.GetReservationsByLicensePlate(ctx, vehicle.LicensePlate)
+	if err != nil {
+		return nil, fmt.Errorf("error getting reservations: %w", err)
+	}
+
+	gracePeriod := config.GetAppConfig().Reservation.GracePeriod
+	for _, reservation := range reservations {
+		if reservation.VehicleType != vehicleType || !reservation.CanBeClaimedAt(record.EntryTime, gracePeriod) {
+			continue
+		}
+
+		spot, err := s.reservationRepo.ClaimReservation(ctx, vehicle, record, &reservation)
+		if errors.Is(err, domain.ErrSpotUnavailable) {
+			// fall back to any available spot
+			return nil, nil
+		}
+		if errors.Is(err, domain.ErrVehicleAlreadyParked) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error claiming reservation: %w", err)
 		}
+		return spot, nil
 	}
 
-	// Get available spots based on floor(s)
-	availableSpots, err := s.parkingRepo.GetAvailableSpots(floors...)
+	return nil, nil
+}
+
+func (s *parkingService) UnparkVehicle(ctx context.Context, lookup domain.ParkingLookup, gateID int64) (*domain.ParkingFee, error) {
+	return s.unpark(ctx, lookup, gateID, 0)
+}
+
+func (s *parkingService) UnparkLostTicket(ctx context.Context, licensePlate string, gateID int64) (*domain.ParkingFee, error) {
+	penalty := config.GetAppConfig().Tariff.LostTicketPenalty
+	return s.unpark(ctx, domain.ParkingLookup{LicensePlate: licensePlate}, gateID, penalty)
+}
+
+// unpark closes the open parking record found by the lookup and charges the fee plus the given penalty
+func (s *parkingService) unpark(ctx context.Context, lookup domain.ParkingLookup, gateID int64, penalty int64) (_ *domain.ParkingFee, err error) {
+	var vehicleType domain.VehicleType
+	defer func() {
+		metrics.UnparkAttempts.WithLabelValues(vehicleTypeLabel(vehicleType), metrics.Outcome(err)).Inc()
+	}()
+
+	gate, err := findGate(gateID)
 	if err != nil {
-		return nil, fmt.Errorf("error getting available spots: %w", err)
+		return nil, err
 	}
 
-	if availableSpots == nil || len(availableSpots) == 0 {
-		return nil, errors.New("no available parking spots")
+	if gate != nil && !gate.AllowsExit() {
+		return nil, domain.NewValidationError(domain.CodeGateDirection, "gate %d is entry only", gate.ID)
 	}
 
-	record := &domain.ParkingRecord{
-		VehicleID:     vehicle.ID,
-		ParkingSpotID: availableSpots[0].ID,
-		EntryTime:     time.Now(),
+	// Get vehicle and its parking record
+	vehicle, record, err := s.getParkedVehicle(ctx, lookup)
+	if err != nil {
+		return nil, err
+	}
+	vehicleType = vehicle.Type
+
+	// Update parking record with exit time and fee
+	fee := s.calculateFee(vehicle, record, time.Now())
+	fee.Penalty = penalty
+	fee.Amount += penalty
+
+	record.ExitTime = sql.NullTime{
+		Time:  fee.ExitTime,
+		Valid: true,
+	}
+	record.Fee = sql.NullInt64{
+		Int64: fee.Amount,
+		Valid: true,
+	}
+	if gate != nil {
+		record.ExitGateID = sql.NullInt64{
+			Int64: gate.ID,
+			Valid: true,
+		}
+	}
+
+	event := domain.VehicleUnparkedEvent{
+		LicensePlate:  vehicle.LicensePlate,
+		VehicleType:   vehicle.Type,
+		TicketCode:    record.TicketCode,
+		ParkingSpotID: record.ParkingSpotID,
+		Fee:           *fee,
+	}
+	if record.ExitGateID.Valid {
+		event.ExitGateID = &record.ExitGateID.Int64
+	}
+
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		err := s.parkingRepo.UpdateParkingRecord(ctx, record)
+		if errors.Is(err, domain.ErrVehicleNotParked) {
+			return domain.NewConflictError(domain.CodeVehicleNotParked, "vehicle with %s is not parked", lookup)
+		}
+		if err != nil {
+			return fmt.Errorf("error updating parking record: %w", err)
+		}
+
+		return s.events.Publish(ctx, domain.EventVehicleUnparked, event)
+	})
+	if err != nil {
+		return nil, err
 	}
+	s.notifier.SpotFreed(ctx, record.ParkingSpotID)
 
-	err = s.parkingRepo.CreateParkingRecord(record)
+	return fee, nil
+}
+
+func (s *parkingService) QuoteFee(ctx context.Context, lookup domain.ParkingLookup) (*domain.ParkingFee, error) {
+	vehicle, record, err := s.getParkedVehicle(ctx, lookup)
 	if err != nil {
-		return nil, fmt.Errorf("error creating parking record: %w", err)
+		return nil, err
 	}
 
-	return &availableSpots[0], nil
+	return s.calculateFee(vehicle, record, time.Now()), nil
 }
 
-func (s *parkingService) UnparkVehicle(licensePlate string) error {
-	// Get vehicle
-	vehicle, err := s.vehicleRepo.GetVehicleByLicensePlate(licensePlate)
+func (s *parkingService) GetVehicleHistory(ctx context.Context, licensePlate string, filter domain.HistoryFilter) (*domain.ParkingHistory, error) {
+	vehicle, err := s.vehicleRepo.GetVehicleByLicensePlate(ctx, licensePlate)
 	if err != nil {
-		return fmt.Errorf("error getting vehicle: %w", err)
+		return nil, fmt.Errorf("error getting vehicle: %w", err)
 	}
 
 	if vehicle == nil {
-		return fmt.Errorf("vehicle with license plate %s not found", licensePlate)
+		return nil, domain.NewNotFoundError(domain.CodeVehicleNotFound, "vehicle with license plate %s not found", licensePlate)
 	}
 
-	// Check if the vehicle is parked
-	lastRecord, err := s.parkingRepo.GetLastParkingRecordByVehicleID(vehicle.ID)
+	// fetch one record more than requested to know whether there is a next page
+	limit := filter.Limit
+	filter.Limit++
+	records, err := s.parkingRepo.GetParkingRecordsByVehicleID(ctx, vehicle.ID, filter)
 	if err != nil {
-		return fmt.Errorf("error checking active parking: %w", err)
+		return nil, fmt.Errorf("error getting parking records: %w", err)
 	}
 
-	if !lastRecord.IsParked() {
-		return fmt.Errorf("vehicle with license plate %s is not parked", licensePlate)
+	history := &domain.ParkingHistory{
+		Entries: make([]domain.ParkingHistoryEntry, 0, min(len(records), limit)),
+	}
+	if len(records) > limit {
+		records = records[:limit]
+		last := records[limit-1]
+		history.NextCursor = domain.HistoryCursor{EntryTime: last.EntryTime, RecordID: last.ID}.String()
 	}
 
-	// Update parking record with exit time
-	lastRecord.ExitTime = sql.NullTime{
-		Time:  time.Now(),
-		Valid: true,
+	spots := map[int64]*domain.ParkingSpot{}
+	now := time.Now()
+	for _, record := range records {
+		spot, ok := spots[record.ParkingSpotID]
+		if !ok {
+			spot, err = s.parkingRepo.GetSpotByID(ctx, record.ParkingSpotID)
+			if err != nil {
+				return nil, fmt.Errorf("error getting parking spot: %w", err)
+			}
+			spots[record.ParkingSpotID] = spot
+		}
+
+		history.Entries = append(history.Entries, s.historyEntry(vehicle, record, spot, now))
 	}
 
-	err = s.parkingRepo.UpdateParkingRecord(lastRecord)
-	if err != nil {
-		return fmt.Errorf("error updating parking record: %w", err)
+	return history, nil
+}
+
+// historyEntry describes the record's stay, with the fee accrued until now while the vehicle is parked
+func (s *parkingService) historyEntry(vehicle *domain.Vehicle, record domain.ParkingRecord, spot *domain.ParkingSpot, now time.Time) domain.ParkingHistoryEntry {
+	entry := domain.ParkingHistoryEntry{
+		TicketCode:  record.TicketCode,
+		ParkingSpot: spot,
+		EntryTime:   record.EntryTime,
+		IsParked:    record.IsParked(),
+	}
+
+	if record.IsParked() {
+		fee := s.calculateFee(vehicle, &record, now)
+		entry.DurationMinutes = fee.DurationMinutes
+		entry.Fee = fee.Amount
+	} else {
+		exitTime := record.ExitTime.Time
+		entry.ExitTime = &exitTime
+		entry.DurationMinutes = int64(exitTime.Sub(record.EntryTime) / time.Minute)
+		entry.Fee = record.Fee.Int64
 	}
 
-	return nil
+	if record.EntryGateID.Valid {
+		entry.EntryGateID = &record.EntryGateID.Int64
+	}
+	if record.ExitGateID.Valid {
+		entry.ExitGateID = &record.ExitGateID.Int64
+	}
+
+	return entry
 }
 
-func (s *parkingService) GetAllAvailableSpots() ([]domain.ParkingSpot, error) {
-	spots, err := s.parkingRepo.GetAvailableSpots()
+// getParkedVehicle returns the vehicle found by the lookup and its open parking record
+func (s *parkingService) getParkedVehicle(ctx context.Context, lookup domain.ParkingLookup) (*domain.Vehicle, *domain.ParkingRecord, error) {
+	vehicle, record, err := s.findParkingRecord(ctx, lookup)
 	if err != nil {
-		return nil, fmt.Errorf("error getting available spots: %w", err)
+		return nil, nil, err
 	}
-	return spots, nil
+
+	if record == nil || !record.IsParked() {
+		return nil, nil, domain.NewConflictError(domain.CodeVehicleNotParked, "vehicle with %s is not parked", lookup)
+	}
+
+	return vehicle, record, nil
 }
 
-func (s *parkingService) SearchVehicle(licensePlate string) (*domain.ParkingSpot, bool, error) {
-	vehicle, err := s.vehicleRepo.GetVehicleByLicensePlate(licensePlate)
+// findParkingRecord returns the vehicle found by the lookup and its latest parking record, if any.
+// A ticket code lookup always returns the record the ticket was issued for.
+func (s *parkingService) findParkingRecord(ctx context.Context, lookup domain.ParkingLookup) (*domain.Vehicle, *domain.ParkingRecord, error) {
+	if lookup.TicketCode != "" {
+		record, err := s.parkingRepo.GetParkingRecordByTicketCode(ctx, lookup.TicketCode)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error getting parking record: %w", err)
+		}
+
+		if record == nil {
+			return nil, nil, domain.NewNotFoundError(domain.CodeTicketNotFound, "ticket %s not found", lookup.TicketCode)
+		}
+
+		vehicle, err := s.vehicleRepo.GetVehicleByID(ctx, record.VehicleID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error getting vehicle: %w", err)
+		}
+
+		return vehicle, record, nil
+	}
+
+	vehicle, err := s.vehicleRepo.GetVehicleByLicensePlate(ctx, lookup.LicensePlate)
 	if err != nil {
-		return nil, false, fmt.Errorf("error getting vehicle: %w", err)
+		return nil, nil, fmt.Errorf("error getting vehicle: %w", err)
 	}
 
 	if vehicle == nil {
-		return nil, false, fmt.Errorf("vehicle with license plate %s not found", licensePlate)
+		return nil, nil, domain.NewNotFoundError(domain.CodeVehicleNotFound, "vehicle with license plate %s not found", lookup.LicensePlate)
 	}
 
-	lastRecord, err := s.parkingRepo.GetLastParkingRecordByVehicleID(vehicle.ID)
+	record, err := s.parkingRepo.GetLastParkingRecordByVehicleID(ctx, vehicle.ID)
 	if err != nil {
-		return nil, false, fmt.Errorf("error getting last parking record: %w", err)
+		return nil, nil, fmt.Errorf("error getting last parking record: %w", err)
 	}
 
-	if lastRecord == nil {
-		return nil, false, fmt.Errorf("no parking history found for vehicle with license plate %s", licensePlate)
+	return vehicle, record, nil
+}
+
+// calculateFee returns the fee for the vehicle's stay from the record's entry time until exitTime
+func (s *parkingService) calculateFee(vehicle *domain.Vehicle, record *domain.ParkingRecord, exitTime time.Time) *domain.ParkingFee {
+	tariff := config.GetAppConfig().Tariff.VehicleTariffs[vehicle.Type]
+
+	return &domain.ParkingFee{
+		EntryTime:       record.EntryTime,
+		ExitTime:        exitTime,
+		DurationMinutes: int64(exitTime.Sub(record.EntryTime) / time.Minute),
+		Amount:          tariff.Calculate(record.EntryTime, exitTime),
 	}
+}
+
+func (s *parkingService) GetAllAvailableSpots(ctx context.Context) ([]domain.ParkingSpot, error) {
+	spots, err := s.parkingRepo.GetAvailableSpots(ctx, domain.SpotFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("error getting available spots: %w", err)
+	}
+	return spots, nil
+}
 
-	spot, err := s.parkingRepo.GetSpotByID(lastRecord.ParkingSpotID)
+func (s *parkingService) SearchVehicle(ctx context.Context, lookup domain.ParkingLookup) (*domain.ParkingSpot, bool, error) {
+	_, record, err := s.findParkingRecord(ctx, lookup)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if record == nil {
+		return nil, false, domain.NewNotFoundError(domain.CodeVehicleNotFound, "no parking history found for vehicle with %s", lookup)
+	}
+
+	spot, err := s.parkingRepo.GetSpotByID(ctx, record.ParkingSpotID)
 	if err != nil {
 		return nil, false, fmt.Errorf("error getting parking spot: %w", err)
 	}
 
-	return spot, !lastRecord.IsParked(), nil
+	return spot, record.IsParked(), nil
+}
+
+// allowedSizeClasses returns the size classes a vehicle needing sizeClass can park on, in order of
+// preference: its own, followed by the larger ones if size class fallback is enabled
+func allowedSizeClasses(sizeClass domain.SizeClass) []domain.SizeClass {
+	sizeClasses := []domain.SizeClass{sizeClass}
+	if config.GetAppConfig().Parking.SizeClassFallback {
+		sizeClasses = append(sizeClasses, sizeClass.Larger()...)
+	}
+	return sizeClasses
+}
+
+// vehicleTypeLabel returns the vehicle type as a metric label, unregistered types are
+// reported as unknown so invalid requests cannot create new series
+func vehicleTypeLabel(vehicleType domain.VehicleType) string {
+	if !config.GetAppConfig().VehicleTypes.IsValid(vehicleType) {
+		return "unknown"
+	}
+	return string(vehicleType)
+}
+
+// findGate returns the configured gate with the given id, or nil if the id is 0
+func findGate(gateID int64) (*domain.Gate, error) {
+	if gateID == 0 {
+		return nil, nil
+	}
+
+	for _, gate := range config.GetAppConfig().Parking.Gates {
+		if gate.ID == gateID {
+			return &gate, nil
+		}
+	}
+
+	return nil, domain.NewNotFoundError(domain.CodeGateNotFound, "gate %d not found", gateID)
+}
+
+// generateTicketCode returns a random, non-guessable ticket code
+func generateTicketCode() (string, error) {
+	b := make([]byte, 10)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.EncodeToString(b), nil
 }
@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"parking-lot/domain"
+)
+
+// TestParkVehicleConcurrently parks more vehicles than there are spots from two parking services sharing a
+// database, every vehicle at both of them at once, like two replicas behind a load balancer
+func TestParkVehicleConcurrently(t *testing.T) {
+	const (
+		spots    = 10
+		vehicles = 15
+	)
+
+	forEachStorage(t, func(t *testing.T, storage *testStorage) {
+		storage.createSpots(t, 1, domain.Car, spots)
+		services := []domain.ParkingService{storage.newParkingService(), storage.newParkingService()}
+
+		type result struct {
+			licensePlate string
+			spot         *domain.ParkingSpot
+			err          error
+		}
+		results := make(chan result, vehicles*len(services))
+
+		var wg sync.WaitGroup
+		for i := range vehicles {
+			licensePlate := fmt.Sprintf("CONC%02d", i)
+			for _, parkingService := range services {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					spot, _, err := parkingService.ParkVehicle(context.Background(), licensePlate, domain.Car, "", 0)
+					results <- result{licensePlate: licensePlate, spot: spot, err: err}
+				}()
+			}
+		}
+		wg.Wait()
+		close(results)
+
+		spotPlates := map[int64]string{}
+		parkedPlates := map[string]int64{}
+		for result := range results {
+			var domainErr *domain.Error
+			if result.err != nil {
+				if !errors.As(result.err, &domainErr) || (domainErr.Code != domain.CodeVehicleAlreadyParked && domainErr.Code != domain.CodeNoAvailableSpots) {
+					t.Errorf("parking %s failed unexpectedly: %v", result.licensePlate, result.err)
+				}
+				continue
+			}
+
+			if other, ok := spotPlates[result.spot.ID]; ok {
+				t.Errorf("spot %d was assigned to %s and %s", result.spot.ID, other, result.licensePlate)
+			}
+			spotPlates[result.spot.ID] = result.licensePlate
+
+			if other, ok := parkedPlates[result.licensePlate]; ok {
+				t.Errorf("%s was parked on spots %d and %d", result.licensePlate, other, result.spot.ID)
+			}
+			parkedPlates[result.licensePlate] = result.spot.ID
+		}
+
+		if len(spotPlates) != spots {
+			t.Errorf("got %d parked vehicles, want every one of the %d spots taken", len(spotPlates), spots)
+		}
+
+		available, err := services[0].GetAllAvailableSpots(context.Background())
+		if err != nil {
+			t.Fatalf("error getting available spots: %v", err)
+		}
+		if len(available) != 0 {
+			t.Errorf("got %d available spots, want 0", len(available))
+		}
+
+		if storage.db != nil {
+			assertNoDuplicateOpenRecords(t, storage)
+		}
+	})
+}
+
+// TestUnparkVehicleConcurrently unparks one vehicle from two parking services sharing a database, several
+// times at both of them at once, like a gate retrying against two replicas
+func TestUnparkVehicleConcurrently(t *testing.T) {
+	const attempts = 5
+
+	forEachStorage(t, func(t *testing.T, storage *testStorage) {
+		ctx := context.Background()
+		storage.createSpots(t, 1, domain.Car, 1)
+		services := []domain.ParkingService{storage.newParkingService(), storage.newParkingService()}
+		webhookRepo := storage.repositories().webhook
+		err := webhookRepo.CreateWebhook(ctx, &domain.Webhook{
+			URL:        "https://example.com/hook",
+			EventTypes: []domain.EventType{domain.EventVehicleUnparked},
+			Secret:     "secret",
+		})
+		if err != nil {
+			t.Fatalf("error creating webhook: %v", err)
+		}
+		mustPark(t, services[0], "AB123")
+
+		errs := make(chan error, attempts*len(services))
+		var wg sync.WaitGroup
+		for range attempts {
+			for _, parkingService := range services {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := parkingService.UnparkVehicle(ctx, domain.ParkingLookup{LicensePlate: "AB123"}, 0)
+					errs <- err
+				}()
+			}
+		}
+		wg.Wait()
+		close(errs)
+
+		var unparked int
+		for err := range errs {
+			if err == nil {
+				unparked++
+				continue
+			}
+			var domainErr *domain.Error
+			if !errors.As(err, &domainErr) || domainErr.Code != domain.CodeVehicleNotParked {
+				t.Errorf("unparking failed unexpectedly: %v", err)
+			}
+		}
+		if unparked != 1 {
+			t.Errorf("got %d successful unparks, want 1", unparked)
+		}
+
+		// the losing unparks must not have announced the vehicle leaving
+		deliveries, err := webhookRepo.GetDeliveriesByStatus(ctx, domain.DeliveryPending)
+		if err != nil {
+			t.Fatalf("error getting deliveries: %v", err)
+		}
+		if len(deliveries) != 1 {
+			t.Errorf("got %d %s deliveries, want 1", len(deliveries), domain.EventVehicleUnparked)
+		}
+	})
+}
+
+// TestParkVehicleRetriesTakenSpots parks while concurrent claims take every ranked spot in the first rounds,
+// each round must run in its own transaction so the wait before it holds no lock
+func TestParkVehicleRetriesTakenSpots(t *testing.T) {
+	tests := []struct {
+		name string
+		// takenRounds is how many rounds lose every ranked spot
+		takenRounds      int
+		wantCode         string
+		wantTransactions int
+	}{
+		{
+			name:             "parks in a new transaction after a lost round",
+			takenRounds:      1,
+			wantTransactions: 2,
+		},
+		{
+			name:             "gives up after the last round",
+			takenRounds:      maxClaimRounds,
+			wantCode:         domain.CodeNoAvailableSpots,
+			wantTransactions: maxClaimRounds,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachStorage(t, func(t *testing.T, storage *testStorage) {
+				storage.createSpots(t, 1, domain.Car, 2)
+				repos := storage.repositories()
+				transactor := &countingTransactor{Transactor: repos.transactor}
+				parkingService := NewParkingService(
+					&takenSpotsRepository{ParkingRepository: repos.parking, transactor: transactor, takenRounds: tt.takenRounds},
+					repos.vehicle,
+					repos.reservation,
+					NewSpotAllocator(domain.AllocationFirstFit),
+					transactor,
+					NewWebhookService(repos.webhook),
+					nopSpotNotifier{},
+				)
+
+				_, _, err := parkingService.ParkVehicle(context.Background(), "AB123", domain.Car, "", 0)
+				if tt.wantCode != "" {
+					assertErrorCode(t, err, tt.wantCode)
+				} else if err != nil {
+					t.Fatalf("ParkVehicle() error = %v", err)
+				}
+
+				if transactor.transactions != tt.wantTransactions {
+					t.Errorf("got %d transactions, want %d", transactor.transactions, tt.wantTransactions)
+				}
+			})
+		})
+	}
+}
+
+// countingTransactor counts the transactions, the count identifies the one running
+type countingTransactor struct {
+	domain.Transactor
+	transactions int
+}
+
+func (t *countingTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	t.transactions++
+	return t.Transactor.WithinTransaction(ctx, fn)
+}
+
+// takenSpotsRepository finds every ranked spot taken by concurrent claims in the first rounds, and checks
+// that no two rounds share a transaction
+type takenSpotsRepository struct {
+	domain.ParkingRepository
+	transactor  *countingTransactor
+	takenRounds int
+	// claimedIn is the transaction of every claim
+	claimedIn []int
+}
+
+func (r *takenSpotsRepository) ClaimSpot(ctx context.Context, vehicle *domain.Vehicle, record *domain.ParkingRecord, candidateIDs []int64) (*domain.ParkingSpot, error) {
+	if slices.Contains(r.claimedIn, r.transactor.transactions) {
+		return nil, fmt.Errorf("claim retried within transaction %d", r.transactor.transactions)
+	}
+	r.claimedIn = append(r.claimedIn, r.transactor.transactions)
+
+	if len(r.claimedIn) <= r.takenRounds {
+		return nil, nil
+	}
+	return r.ParkingRepository.ClaimSpot(ctx, vehicle, record, candidateIDs)
+}
+
+// TestCancelReservationWhileClaimed cancels a reservation whose vehicle parks on the reserved spot after the
+// cancellation read the reservation, like an attendant cancelling while the vehicle drives in
+func TestCancelReservationWhileClaimed(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, storage *testStorage) {
+		ctx := context.Background()
+		storage.createSpots(t, 1, domain.Car, 2)
+		repos := storage.repositories()
+
+		start := time.Now()
+		reservation, reservedSpot, err := NewReservationService(repos.reservation).CreateReservation(ctx, "AB123", domain.Car, start, start.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("CreateReservation() error = %v", err)
+		}
+
+		parkingService := storage.newParkingService()
+		var ticket *domain.Ticket
+		reservationService := NewReservationService(&parkBeforeUpdateRepository{
+			ReservationRepository: repos.reservation,
+			park: func() {
+				ticket = mustPark(t, parkingService, "AB123")
+			},
+		})
+
+		err = reservationService.CancelReservation(ctx, reservation.ID)
+		assertErrorCode(t, err, domain.CodeReservationNotActive)
+
+		if ticket.ParkingSpotID != reservedSpot.ID {
+			t.Errorf("got vehicle parked on spot %d, want the reserved spot %d", ticket.ParkingSpotID, reservedSpot.ID)
+		}
+		stored, err := repos.reservation.GetReservationByID(ctx, reservation.ID)
+		if err != nil {
+			t.Fatalf("error getting reservation: %v", err)
+		}
+		if stored.Status != domain.ReservationClaimed {
+			t.Errorf("got reservation %s, want %s", stored.Status, domain.ReservationClaimed)
+		}
+	})
+}
+
+// parkBeforeUpdateRepository parks the reserved vehicle right before updating the status of a reservation
+type parkBeforeUpdateRepository struct {
+	domain.ReservationRepository
+	park func()
+}
+
+func (r *parkBeforeUpdateRepository) UpdateReservationStatus(ctx context.Context, id int64, status domain.ReservationStatus) error {
+	r.park()
+	return r.ReservationRepository.UpdateReservationStatus(ctx, id, status)
+}
+
+// assertNoDuplicateOpenRecords checks the database for spots and vehicles with more than one open record
+func assertNoDuplicateOpenRecords(t *testing.T, storage *testStorage) {
+	t.Helper()
+
+	for _, column := range []string{"parking_spot_id", "vehicle_id"} {
+		var duplicates int
+		err := storage.db.QueryRow(fmt.Sprintf(`
+			SELECT COUNT(*)
+			FROM (
+				SELECT %s
+				FROM parking_records
+				WHERE exit_time IS NULL
+				GROUP BY %s
+				HAVING COUNT(*) > 1
+			) duplicates
+		`, column, column)).Scan(&duplicates)
+		if err != nil {
+			t.Fatalf("error counting open records: %v", err)
+		}
+		if duplicates > 0 {
+			t.Errorf("got %d values of %s with more than one open parking record", duplicates, column)
+		}
+	}
+}
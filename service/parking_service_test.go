@@ -0,0 +1,481 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"parking-lot/domain"
+)
+
+func TestParkVehicle(t *testing.T) {
+	tests := []struct {
+		name         string
+		spots        int
+		parked       []string
+		licensePlate string
+		vehicleType  domain.VehicleType
+		gateID       int64
+		wantCode     string
+	}{
+		{
+			name:         "parks on a free spot",
+			spots:        2,
+			licensePlate: "AB123",
+			vehicleType:  domain.Car,
+		},
+		{
+			name:         "parks through an entry gate",
+			spots:        2,
+			licensePlate: "AB123",
+			vehicleType:  domain.Car,
+			gateID:       2,
+		},
+		{
+			name:         "parks next to other vehicles",
+			spots:        2,
+			parked:       []string{"CD456"},
+			licensePlate: "AB123",
+			vehicleType:  domain.Car,
+		},
+		{
+			name:         "rejects a vehicle that is already parked",
+			spots:        2,
+			parked:       []string{"AB123"},
+			licensePlate: "AB123",
+			vehicleType:  domain.Car,
+			wantCode:     domain.CodeVehicleAlreadyParked,
+		},
+		{
+			name:         "rejects a vehicle when the lot is full",
+			spots:        1,
+			parked:       []string{"CD456"},
+			licensePlate: "AB123",
+			vehicleType:  domain.Car,
+			wantCode:     domain.CodeNoAvailableSpots,
+		},
+		{
+			name:         "rejects a vehicle type without spots",
+			spots:        2,
+			licensePlate: "AB123",
+			vehicleType:  domain.Motorcycle,
+			wantCode:     domain.CodeNoAvailableSpots,
+		},
+		{
+			name:         "rejects an exit gate",
+			spots:        2,
+			licensePlate: "AB123",
+			vehicleType:  domain.Car,
+			gateID:       3,
+			wantCode:     domain.CodeGateDirection,
+		},
+		{
+			name:         "rejects an unknown gate",
+			spots:        2,
+			licensePlate: "AB123",
+			vehicleType:  domain.Car,
+			gateID:       9,
+			wantCode:     domain.CodeGateNotFound,
+		},
+		{
+			name:         "rejects an unknown vehicle type",
+			spots:        2,
+			licensePlate: "AB123",
+			vehicleType:  "tank",
+			wantCode:     domain.CodeInvalidVehicleType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			storage := newMemoryStorage()
+			storage.createSpots(t, 1, domain.Car, tt.spots)
+			parkingService := storage.newParkingService()
+			for _, licensePlate := range tt.parked {
+				mustPark(t, parkingService, licensePlate)
+			}
+
+			spot, ticket, err := parkingService.ParkVehicle(ctx, tt.licensePlate, tt.vehicleType, "", tt.gateID)
+			if tt.wantCode != "" {
+				assertErrorCode(t, err, tt.wantCode)
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParkVehicle() error = %v", err)
+			}
+
+			if spot.VehicleType != tt.vehicleType {
+				t.Errorf("got spot for %s, want %s", spot.VehicleType, tt.vehicleType)
+			}
+			if ticket.Code == "" || ticket.LicensePlate != tt.licensePlate || ticket.ParkingSpotID != spot.ID {
+				t.Errorf("got ticket %+v for spot %d", ticket, spot.ID)
+			}
+
+			found, isParked, err := parkingService.SearchVehicle(ctx, domain.ParkingLookup{TicketCode: ticket.Code})
+			if err != nil {
+				t.Fatalf("SearchVehicle() error = %v", err)
+			}
+			if !isParked || found.ID != spot.ID {
+				t.Errorf("SearchVehicle() = spot %d, parked %v, want spot %d, parked", found.ID, isParked, spot.ID)
+			}
+		})
+	}
+}
+
+func TestUnparkVehicle(t *testing.T) {
+	tests := []struct {
+		name     string
+		parked   []string
+		unparked []string
+		lookup   domain.ParkingLookup
+		byTicket bool
+		gateID   int64
+		wantCode string
+	}{
+		{
+			name:   "unparks by license plate",
+			parked: []string{"AB123"},
+			lookup: domain.ParkingLookup{LicensePlate: "AB123"},
+		},
+		{
+			name:     "unparks by ticket code",
+			parked:   []string{"AB123"},
+			byTicket: true,
+		},
+		{
+			name:   "unparks through an exit gate",
+			parked: []string{"AB123"},
+			lookup: domain.ParkingLookup{LicensePlate: "AB123"},
+			gateID: 3,
+		},
+		{
+			name:     "rejects a vehicle that left",
+			parked:   []string{"AB123"},
+			unparked: []string{"AB123"},
+			lookup:   domain.ParkingLookup{LicensePlate: "AB123"},
+			wantCode: domain.CodeVehicleNotParked,
+		},
+		{
+			name:     "rejects a ticket of a vehicle that left",
+			parked:   []string{"AB123"},
+			unparked: []string{"AB123"},
+			byTicket: true,
+			wantCode: domain.CodeVehicleNotParked,
+		},
+		{
+			name:     "rejects an unknown vehicle",
+			parked:   []string{"AB123"},
+			lookup:   domain.ParkingLookup{LicensePlate: "CD456"},
+			wantCode: domain.CodeVehicleNotFound,
+		},
+		{
+			name:     "rejects an unknown ticket",
+			lookup:   domain.ParkingLookup{TicketCode: "UNKNOWN"},
+			wantCode: domain.CodeTicketNotFound,
+		},
+		{
+			name:     "rejects an entry gate",
+			parked:   []string{"AB123"},
+			lookup:   domain.ParkingLookup{LicensePlate: "AB123"},
+			gateID:   2,
+			wantCode: domain.CodeGateDirection,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			storage := newMemoryStorage()
+			storage.createSpots(t, 1, domain.Car, 2)
+			parkingService := storage.newParkingService()
+
+			lookup := tt.lookup
+			for _, licensePlate := range tt.parked {
+				ticket := mustPark(t, parkingService, licensePlate)
+				if tt.byTicket {
+					lookup = domain.ParkingLookup{TicketCode: ticket.Code}
+				}
+			}
+			for _, licensePlate := range tt.unparked {
+				_, err := parkingService.UnparkVehicle(ctx, domain.ParkingLookup{LicensePlate: licensePlate}, 0)
+				if err != nil {
+					t.Fatalf("UnparkVehicle(%s) error = %v", licensePlate, err)
+				}
+			}
+
+			fee, err := parkingService.UnparkVehicle(ctx, lookup, tt.gateID)
+			if tt.wantCode != "" {
+				assertErrorCode(t, err, tt.wantCode)
+				return
+			}
+			if err != nil {
+				t.Fatalf("UnparkVehicle() error = %v", err)
+			}
+
+			if fee.ExitTime.Before(fee.EntryTime) || fee.Amount < 0 {
+				t.Errorf("got fee %+v", fee)
+			}
+
+			available, err := parkingService.GetAllAvailableSpots(ctx)
+			if err != nil {
+				t.Fatalf("GetAllAvailableSpots() error = %v", err)
+			}
+			if len(available) != 2 {
+				t.Errorf("got %d available spots after unparking, want 2", len(available))
+			}
+		})
+	}
+}
+
+func TestSearchVehicle(t *testing.T) {
+	tests := []struct {
+		name         string
+		unparked     bool
+		byTicket     bool
+		licensePlate string
+		wantParked   bool
+		wantCode     string
+	}{
+		{
+			name:         "finds a parked vehicle by license plate",
+			licensePlate: "AB123",
+			wantParked:   true,
+		},
+		{
+			name:       "finds a parked vehicle by ticket code",
+			byTicket:   true,
+			wantParked: true,
+		},
+		{
+			name:         "finds the last spot of a vehicle that left by license plate",
+			unparked:     true,
+			licensePlate: "AB123",
+		},
+		{
+			name:     "finds the spot of a ticket of a vehicle that left",
+			unparked: true,
+			byTicket: true,
+		},
+		{
+			name:         "rejects an unknown vehicle",
+			licensePlate: "CD456",
+			wantCode:     domain.CodeVehicleNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			storage := newMemoryStorage()
+			storage.createSpots(t, 1, domain.Car, 2)
+			parkingService := storage.newParkingService()
+
+			ticket := mustPark(t, parkingService, "AB123")
+			if tt.unparked {
+				_, err := parkingService.UnparkVehicle(ctx, domain.ParkingLookup{LicensePlate: "AB123"}, 0)
+				if err != nil {
+					t.Fatalf("UnparkVehicle() error = %v", err)
+				}
+			}
+
+			lookup := domain.ParkingLookup{LicensePlate: tt.licensePlate}
+			if tt.byTicket {
+				lookup = domain.ParkingLookup{TicketCode: ticket.Code}
+			}
+			spot, isParked, err := parkingService.SearchVehicle(ctx, lookup)
+			if tt.wantCode != "" {
+				assertErrorCode(t, err, tt.wantCode)
+				return
+			}
+			if err != nil {
+				t.Fatalf("SearchVehicle() error = %v", err)
+			}
+
+			if spot.ID != ticket.ParkingSpotID {
+				t.Errorf("got spot %d, want spot %d", spot.ID, ticket.ParkingSpotID)
+			}
+			if isParked != tt.wantParked {
+				t.Errorf("got parked %v, want %v", isParked, tt.wantParked)
+			}
+		})
+	}
+}
+
+func TestParkVehicleOnHeldSpot(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, storage *testStorage) {
+		ctx := context.Background()
+		storage.createSpots(t, 1, domain.Car, 1)
+		parkingService := storage.newParkingService()
+		reservationService := NewReservationService(storage.repositories().reservation)
+
+		// the grace period lets the reserved vehicle park 15 minutes early, the hold keeps the spot free for an hour
+		start := time.Now().Add(10 * time.Minute)
+		reservation, spot, err := reservationService.CreateReservation(ctx, "AB123", domain.Car, start, start.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("CreateReservation() error = %v", err)
+		}
+		if !reservation.HeldFrom.Before(time.Now()) {
+			t.Fatalf("got reservation held from %v, want held now", reservation.HeldFrom)
+		}
+
+		_, _, err = parkingService.ParkVehicle(ctx, "CD456", domain.Car, "", 0)
+		assertErrorCode(t, err, domain.CodeNoAvailableSpots)
+
+		parked, _, err := parkingService.ParkVehicle(ctx, "AB123", domain.Car, "", 0)
+		if err != nil {
+			t.Fatalf("ParkVehicle() of the reserved vehicle error = %v", err)
+		}
+		if parked.ID != spot.ID {
+			t.Errorf("got spot %d, want reserved spot %d", parked.ID, spot.ID)
+		}
+	})
+}
+
+func TestGetVehicleHistory(t *testing.T) {
+	// the stays are numbered newest first, stay 0 is the vehicle parked now; none means no filter
+	const none = -1
+
+	tests := []struct {
+		name  string
+		limit int
+		// from and to filter on the entry time of a stay
+		from, to int
+		// wantPages are the stays of each page
+		wantPages [][]int
+	}{
+		{
+			name:      "returns every stay on one page",
+			limit:     5,
+			from:      none,
+			to:        none,
+			wantPages: [][]int{{0, 1, 2, 3, 4}},
+		},
+		{
+			name:      "pages through the stays",
+			limit:     2,
+			from:      none,
+			to:        none,
+			wantPages: [][]int{{0, 1}, {2, 3}, {4}},
+		},
+		{
+			name:      "includes the stays entering from the start of the range",
+			limit:     2,
+			from:      2,
+			to:        none,
+			wantPages: [][]int{{0, 1}, {2}},
+		},
+		{
+			name:      "excludes the stays entering at the end of the range",
+			limit:     2,
+			from:      none,
+			to:        1,
+			wantPages: [][]int{{2, 3}, {4}},
+		},
+		{
+			name:      "pages through a range",
+			limit:     1,
+			from:      3,
+			to:        1,
+			wantPages: [][]int{{2}, {3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachStorage(t, func(t *testing.T, storage *testStorage) {
+				ctx := context.Background()
+				storage.createSpots(t, 1, domain.Car, 2)
+				parkingService := storage.newParkingService()
+				mustPark(t, parkingService, "CD456")
+
+				var tickets []string
+				for i := range 5 {
+					// apart entry times keep the range bounds between the stays
+					time.Sleep(2 * time.Millisecond)
+					tickets = append([]string{mustPark(t, parkingService, "AB123").Code}, tickets...)
+					if i < 4 {
+						_, err := parkingService.UnparkVehicle(ctx, domain.ParkingLookup{LicensePlate: "AB123"}, 0)
+						if err != nil {
+							t.Fatalf("UnparkVehicle() error = %v", err)
+						}
+					}
+				}
+
+				all, err := parkingService.GetVehicleHistory(ctx, "AB123", domain.HistoryFilter{Limit: 100})
+				if err != nil {
+					t.Fatalf("GetVehicleHistory() error = %v", err)
+				}
+				if len(all.Entries) != len(tickets) {
+					t.Fatalf("got %d stays, want %d", len(all.Entries), len(tickets))
+				}
+				if !all.Entries[0].IsParked || all.Entries[1].IsParked {
+					t.Errorf("got parked %v and %v, want only the newest stay parked", all.Entries[0].IsParked, all.Entries[1].IsParked)
+				}
+
+				filter := domain.HistoryFilter{Limit: tt.limit}
+				if tt.from != none {
+					filter.From = &all.Entries[tt.from].EntryTime
+				}
+				if tt.to != none {
+					filter.To = &all.Entries[tt.to].EntryTime
+				}
+
+				for page, wantStays := range tt.wantPages {
+					history, err := parkingService.GetVehicleHistory(ctx, "AB123", filter)
+					if err != nil {
+						t.Fatalf("GetVehicleHistory() of page %d error = %v", page, err)
+					}
+
+					var got, want []string
+					for _, entry := range history.Entries {
+						got = append(got, entry.TicketCode)
+					}
+					for _, stay := range wantStays {
+						want = append(want, tickets[stay])
+					}
+					if !slices.Equal(got, want) {
+						t.Fatalf("got tickets %v on page %d, want %v", got, page, want)
+					}
+
+					lastPage := page == len(tt.wantPages)-1
+					if lastPage != (history.NextCursor == "") {
+						t.Fatalf("got next cursor %q on page %d of %d", history.NextCursor, page, len(tt.wantPages))
+					}
+					if !lastPage {
+						filter.After, err = domain.ParseHistoryCursor(history.NextCursor)
+						if err != nil {
+							t.Fatalf("ParseHistoryCursor() error = %v", err)
+						}
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestGetVehicleHistoryOfUnknownVehicle(t *testing.T) {
+	_, err := newMemoryStorage().newParkingService().GetVehicleHistory(context.Background(), "AB123", domain.HistoryFilter{Limit: 10})
+	assertErrorCode(t, err, domain.CodeVehicleNotFound)
+}
+
+func mustPark(t *testing.T, parkingService domain.ParkingService, licensePlate string) *domain.Ticket {
+	t.Helper()
+
+	_, ticket, err := parkingService.ParkVehicle(context.Background(), licensePlate, domain.Car, "", 0)
+	if err != nil {
+		t.Fatalf("ParkVehicle(%s) error = %v", licensePlate, err)
+	}
+	return ticket
+}
+
+func assertErrorCode(t *testing.T, err error, code string) {
+	t.Helper()
+
+	var domainErr *domain.Error
+	if !errors.As(err, &domainErr) || domainErr.Code != code {
+		t.Fatalf("got error %v, want %s", err, code)
+	}
+}
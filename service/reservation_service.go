@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"parking-lot/config"
+	"parking-lot/domain"
+)
+
+type reservationService struct {
+	reservationRepo domain.ReservationRepository
+}
+
+func NewReservationService(reservationRepo domain.ReservationRepository) domain.ReservationService {
+	return &reservationService{
+		reservationRepo: reservationRepo,
+	}
+}
+
+func (s *reservationService) CreateReservation(
+	ctx context.Context,
+	licensePlate string,
+	vehicleType domain.VehicleType,
+	startTime, endTime time.Time,
+) (*domain.Reservation, *domain.ParkingSpot, error) {
+	if !endTime.After(startTime) {
+		return nil, nil, domain.NewValidationError(domain.CodeInvalidReservation, "reservation end time must be after its start time")
+	}
+
+	if endTime.Before(time.Now()) {
+		return nil, nil, domain.NewValidationError(domain.CodeInvalidReservation, "reservation must end in the future")
+	}
+
+	// The spot is held from when the vehicle may arrive at the latest, so a walk-in parking shortly before
+	// cannot block it for the whole reservation
+	reservationConfig := config.GetAppConfig().Reservation
+	reservation := &domain.Reservation{
+		LicensePlate: licensePlate,
+		VehicleType:  vehicleType,
+		StartTime:    startTime,
+		EndTime:      endTime,
+		HeldFrom:     startTime.Add(-max(reservationConfig.Hold, reservationConfig.GracePeriod)),
+	}
+
+	// reserve a spot of the vehicle's size class, or a larger one if allowed and none is free
+	for _, sizeClass := range allowedSizeClasses(config.GetAppConfig().VehicleTypes.DefaultSizeClass(vehicleType)) {
+		spot, err := s.reservationRepo.CreateReservation(ctx, reservation, domain.SpotFilter{
+			VehicleType: vehicleType,
+			SizeClasses: []domain.SizeClass{sizeClass},
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating reservation: %w", err)
+		}
+
+		if spot != nil {
+			return reservation, spot, nil
+		}
+	}
+
+	return nil, nil, domain.NewCapacityExhaustedError(domain.CodeNoSpotsForReservation, "no parking spots available for the reservation window")
+}
+
+func (s *reservationService) GetReservation(ctx context.Context, id int64) (*domain.Reservation, error) {
+	reservation, err := s.reservationRepo.GetReservationByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting reservation: %w", err)
+	}
+
+	if reservation == nil {
+		return nil, domain.NewNotFoundError(domain.CodeReservationNotFound, "reservation %d not found", id)
+	}
+
+	return reservation, nil
+}
+
+func (s *reservationService) GetReservationsByLicensePlate(ctx context.Context, licensePlate string) ([]domain.Reservation, error) {
+	reservations, err := s.reservationRepo.GetReservationsByLicensePlate(ctx, licensePlate)
+	if err != nil {
+		return nil, fmt.Errorf("error getting reservations: %w", err)
+	}
+	return reservations, nil
+}
+
+func (s *reservationService) CancelReservation(ctx context.Context, id int64) error {
+	reservation, err := s.GetReservation(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if reservation.Status != domain.ReservationActive {
+		return domain.NewConflictError(domain.CodeReservationNotActive, "reservation %d is %s and cannot be cancelled", id, reservation.Status)
+	}
+
+	err = s.reservationRepo.UpdateReservationStatus(ctx, id, domain.ReservationCancelled)
+	if errors.Is(err, domain.ErrReservationNotActive) {
+		// claimed or expired since it was read, its current status is not known here
+		return domain.NewConflictError(domain.CodeReservationNotActive, "reservation %d is no longer active and cannot be cancelled", id)
+	}
+	if err != nil {
+		return fmt.Errorf("error cancelling reservation: %w", err)
+	}
+
+	return nil
+}
+
+func (s *reservationService) ExpireNoShows(ctx context.Context) (int64, error) {
+	gracePeriod := config.GetAppConfig().Reservation.GracePeriod
+
+	expired, err := s.reservationRepo.ExpireReservations(ctx, time.Now().Add(-gracePeriod))
+	if err != nil {
+		return 0, fmt.Errorf("error expiring reservations: %w", err)
+	}
+	return expired, nil
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"parking-lot/domain"
+)
+
+func TestCreateReservationOverlappingHold(t *testing.T) {
+	// the spot of a reservation is held an hour ahead of its start, see RESERVATION_HOLD_MINUTES
+	start := time.Now().Add(3 * time.Hour).Truncate(time.Minute)
+
+	tests := []struct {
+		name     string
+		start    time.Time
+		end      time.Time
+		wantCode string
+	}{
+		{
+			name:     "rejects a reservation ending during the hold",
+			start:    start.Add(-2 * time.Hour),
+			end:      start.Add(-30 * time.Minute),
+			wantCode: domain.CodeNoSpotsForReservation,
+		},
+		{
+			name:     "rejects a reservation during the reserved window",
+			start:    start.Add(30 * time.Minute),
+			end:      start.Add(90 * time.Minute),
+			wantCode: domain.CodeNoSpotsForReservation,
+		},
+		{
+			name:  "accepts a reservation ending before the hold",
+			start: start.Add(-3 * time.Hour),
+			end:   start.Add(-time.Hour),
+		},
+		{
+			name:  "accepts a reservation held from the end of the reserved window",
+			start: start.Add(3 * time.Hour),
+			end:   start.Add(4 * time.Hour),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachStorage(t, func(t *testing.T, storage *testStorage) {
+				ctx := context.Background()
+				storage.createSpots(t, 1, domain.Car, 1)
+				reservationService := NewReservationService(storage.repositories().reservation)
+
+				_, _, err := reservationService.CreateReservation(ctx, "AB123", domain.Car, start, start.Add(time.Hour))
+				if err != nil {
+					t.Fatalf("CreateReservation() error = %v", err)
+				}
+
+				_, _, err = reservationService.CreateReservation(ctx, "CD456", domain.Car, tt.start, tt.end)
+				if tt.wantCode != "" {
+					assertErrorCode(t, err, tt.wantCode)
+					return
+				}
+				if err != nil {
+					t.Errorf("CreateReservation() error = %v", err)
+				}
+			})
+		})
+	}
+}
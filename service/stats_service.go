@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"parking-lot/domain"
+)
+
+type statsService struct {
+	statsRepo domain.StatsRepository
+}
+
+func NewStatsService(statsRepo domain.StatsRepository) domain.StatsService {
+	return &statsService{
+		statsRepo: statsRepo,
+	}
+}
+
+func (s *statsService) GetOccupancy(ctx context.Context) (*domain.OccupancyStats, error) {
+	now := time.Now()
+	counts, err := s.statsRepo.GetOccupancyCounts(ctx, now)
+	if err != nil {
+		return nil, fmt.Errorf("error getting occupancy counts: %w", err)
+	}
+
+	// roll the per floor and vehicle type counts up to the lot, each floor and each vehicle type
+	var (
+		lot          domain.SpotCounts
+		floors       []int
+		floorCounts  = map[int]*domain.SpotCounts{}
+		vehicleTypes []domain.VehicleType
+		typeCounts   = map[domain.VehicleType]*domain.SpotCounts{}
+	)
+	for _, count := range counts {
+		lot.Add(count.SpotCounts)
+
+		if _, ok := floorCounts[count.Floor]; !ok {
+			floors = append(floors, count.Floor)
+			floorCounts[count.Floor] = &domain.SpotCounts{}
+		}
+		floorCounts[count.Floor].Add(count.SpotCounts)
+
+		if _, ok := typeCounts[count.VehicleType]; !ok {
+			vehicleTypes = append(vehicleTypes, count.VehicleType)
+			typeCounts[count.VehicleType] = &domain.SpotCounts{}
+		}
+		typeCounts[count.VehicleType].Add(count.SpotCounts)
+	}
+	slices.Sort(floors)
+	slices.Sort(vehicleTypes)
+
+	stats := &domain.OccupancyStats{
+		Lot:          domain.NewOccupancy(lot),
+		Floors:       make([]domain.FloorOccupancy, 0, len(floors)),
+		VehicleTypes: make([]domain.VehicleTypeOccupancy, 0, len(vehicleTypes)),
+		ComputedAt:   now,
+	}
+	for _, floor := range floors {
+		stats.Floors = append(stats.Floors, domain.FloorOccupancy{
+			Floor:     floor,
+			Occupancy: domain.NewOccupancy(*floorCounts[floor]),
+		})
+	}
+	for _, vehicleType := range vehicleTypes {
+		stats.VehicleTypes = append(stats.VehicleTypes, domain.VehicleTypeOccupancy{
+			VehicleType: vehicleType,
+			Occupancy:   domain.NewOccupancy(*typeCounts[vehicleType]),
+		})
+	}
+
+	return stats, nil
+}
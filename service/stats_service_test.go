@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"parking-lot/config"
+	"parking-lot/domain"
+)
+
+func TestGetOccupancy(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, storage *testStorage) {
+		ctx := context.Background()
+		repos := storage.repositories()
+		storage.createSpots(t, 1, domain.Car, 4)
+		storage.createSpots(t, 2, domain.Car, 2)
+		// the second row holds two motorcycle spots on the second floor and an inactive car spot on the first
+		for _, spot := range []domain.ParkingSpot{
+			{Floor: 2, Column: 1, VehicleType: domain.Motorcycle, IsActive: true},
+			{Floor: 2, Column: 2, VehicleType: domain.Motorcycle, IsActive: true},
+			{Floor: 1, Column: 1, VehicleType: domain.Car},
+		} {
+			spot.Row = 2
+			spot.SizeClass = config.GetAppConfig().VehicleTypes.DefaultSizeClass(spot.VehicleType)
+			err := repos.parking.CreateSpot(ctx, &spot)
+			if err != nil {
+				t.Fatalf("error creating spot: %v", err)
+			}
+		}
+
+		// first fit parks the cars and holds the reserved spot on the first floor
+		parkingService := storage.newParkingService()
+		mustPark(t, parkingService, "AB123")
+		mustPark(t, parkingService, "CD456")
+		_, _, err := parkingService.ParkVehicle(ctx, "EF789", domain.Motorcycle, "", 0)
+		if err != nil {
+			t.Fatalf("ParkVehicle() of the motorcycle error = %v", err)
+		}
+		start := time.Now().Add(10 * time.Minute)
+		_, _, err = NewReservationService(repos.reservation).CreateReservation(ctx, "GH012", domain.Car, start, start.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("CreateReservation() error = %v", err)
+		}
+
+		stats, err := NewStatsService(repos.stats).GetOccupancy(ctx)
+		if err != nil {
+			t.Fatalf("GetOccupancy() error = %v", err)
+		}
+
+		assertOccupancy(t, "lot", stats.Lot, domain.Occupancy{
+			SpotCounts:      domain.SpotCounts{Total: 9, Occupied: 3, Reserved: 1, Inactive: 1, Free: 4},
+			OccupiedPercent: 37.5,
+			ReservedPercent: 12.5,
+			FreePercent:     50,
+		})
+
+		if len(stats.Floors) != 2 || stats.Floors[0].Floor != 1 || stats.Floors[1].Floor != 2 {
+			t.Fatalf("got floors %+v, want floors 1 and 2", stats.Floors)
+		}
+		assertOccupancy(t, "floor 1", stats.Floors[0].Occupancy, domain.Occupancy{
+			SpotCounts:      domain.SpotCounts{Total: 5, Occupied: 2, Reserved: 1, Inactive: 1, Free: 1},
+			OccupiedPercent: 50,
+			ReservedPercent: 25,
+			FreePercent:     25,
+		})
+		assertOccupancy(t, "floor 2", stats.Floors[1].Occupancy, domain.Occupancy{
+			SpotCounts:      domain.SpotCounts{Total: 4, Occupied: 1, Free: 3},
+			OccupiedPercent: 25,
+			FreePercent:     75,
+		})
+
+		if len(stats.VehicleTypes) != 2 || stats.VehicleTypes[0].VehicleType != domain.Car || stats.VehicleTypes[1].VehicleType != domain.Motorcycle {
+			t.Fatalf("got vehicle types %+v, want car and motorcycle", stats.VehicleTypes)
+		}
+		assertOccupancy(t, "cars", stats.VehicleTypes[0].Occupancy, domain.Occupancy{
+			SpotCounts:      domain.SpotCounts{Total: 7, Occupied: 2, Reserved: 1, Inactive: 1, Free: 3},
+			OccupiedPercent: 33.33,
+			ReservedPercent: 16.67,
+			FreePercent:     50,
+		})
+		assertOccupancy(t, "motorcycles", stats.VehicleTypes[1].Occupancy, domain.Occupancy{
+			SpotCounts:      domain.SpotCounts{Total: 2, Occupied: 1, Free: 1},
+			OccupiedPercent: 50,
+			FreePercent:     50,
+		})
+	})
+}
+
+func TestGetOccupancyOfEmptyLot(t *testing.T) {
+	stats, err := NewStatsService(newMemoryStorage().repositories().stats).GetOccupancy(context.Background())
+	if err != nil {
+		t.Fatalf("GetOccupancy() error = %v", err)
+	}
+
+	assertOccupancy(t, "lot", stats.Lot, domain.Occupancy{})
+	if len(stats.Floors) != 0 || len(stats.VehicleTypes) != 0 {
+		t.Errorf("got floors %+v and vehicle types %+v, want none", stats.Floors, stats.VehicleTypes)
+	}
+}
+
+func assertOccupancy(t *testing.T, name string, got, want domain.Occupancy) {
+	t.Helper()
+
+	if got != want {
+		t.Errorf("got %s occupancy %+v, want %+v", name, got, want)
+	}
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"parking-lot/config"
+	"parking-lot/domain"
+	"parking-lot/metrics"
+)
+
+const (
+	// deliveryBatchSize is how many deliveries are claimed and sent concurrently per poll
+	deliveryBatchSize = 20
+	// deliveryCleanupInterval is how often delivered events past their retention are deleted
+	deliveryCleanupInterval = time.Hour
+	// maxErrorBodySize limits how much of a failed response is kept as the last error
+	maxErrorBodySize = 512
+)
+
+// WebhookDispatcher delivers the events in the outbox of the webhook repository. Several dispatchers,
+// e.g. one per instance, can run against the same database as claimed deliveries are leased.
+type WebhookDispatcher struct {
+	webhookRepo domain.WebhookRepository
+	config      config.WebhookConfig
+	client      *http.Client
+}
+
+func NewWebhookDispatcher(webhookRepo domain.WebhookRepository, webhookConfig config.WebhookConfig) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		webhookRepo: webhookRepo,
+		config:      webhookConfig,
+		client:      &http.Client{Timeout: webhookConfig.Timeout},
+	}
+}
+
+// Run delivers due events every poll interval until ctx is done
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	pollTicker := time.NewTicker(d.config.PollInterval)
+	defer pollTicker.Stop()
+	cleanupTicker := time.NewTicker(deliveryCleanupInterval)
+	defer cleanupTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-cleanupTicker.C:
+			d.deleteDelivered(ctx)
+		case <-pollTicker.C:
+			d.dispatchDue(ctx)
+		}
+	}
+}
+
+// dispatchDue delivers batches of due events until none are left
+func (d *WebhookDispatcher) dispatchDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		// the lease outlasts the request timeout, so a delivery is only claimed again if this dispatcher stopped
+		deliveries, err := d.webhookRepo.ClaimDueDeliveries(ctx, time.Now(), 2*d.config.Timeout, deliveryBatchSize)
+		if err != nil {
+			log.Printf("Failed to claim webhook deliveries: %v\n", err)
+			return
+		}
+
+		var wg sync.WaitGroup
+		for i := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d.deliver(ctx, &deliveries[i])
+			}()
+		}
+		wg.Wait()
+
+		if len(deliveries) < deliveryBatchSize {
+			return
+		}
+	}
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery *domain.WebhookDelivery) {
+	err := d.send(ctx, delivery)
+	if ctx.Err() != nil {
+		// shutting down, the delivery is retried once its lease ends
+		return
+	}
+
+	delivery.Attempts++
+	outcome := "delivered"
+	if err == nil {
+		delivery.Status = domain.DeliveryDelivered
+		delivery.LastError = ""
+	} else if delivery.Attempts >= d.config.MaxAttempts {
+		outcome = "dead"
+		delivery.Status = domain.DeliveryDead
+		delivery.LastError = err.Error()
+		log.Printf("Giving up webhook delivery %d of event %s to %s after %d attempts: %v\n",
+			delivery.ID, delivery.EventID, delivery.URL, delivery.Attempts, err)
+	} else {
+		outcome = "failed"
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = time.Now().Add(d.backoff(delivery.Attempts))
+	}
+	metrics.WebhookDeliveries.WithLabelValues(string(delivery.EventType), outcome).Inc()
+
+	err = d.webhookRepo.UpdateDelivery(ctx, delivery)
+	if err != nil {
+		log.Printf("Failed to update webhook delivery %d: %v\n", delivery.ID, err)
+	}
+}
+
+// send posts the payload of the delivery, signed with the secret of its webhook
+func (d *WebhookDispatcher) send(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-ID", strconv.FormatInt(delivery.WebhookID, 10))
+	req.Header.Set("X-Webhook-Event", string(delivery.EventType))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+SignWebhookPayload(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// backoff returns the wait after the given number of failed attempts, doubling from the initial backoff
+// up to the max backoff
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	backoff := d.config.InitialBackoff
+	for i := 1; i < attempts && backoff < d.config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, d.config.MaxBackoff)
+}
+
+func (d *WebhookDispatcher) deleteDelivered(ctx context.Context) {
+	deleted, err := d.webhookRepo.DeleteDeliveries(ctx, domain.DeliveryDelivered, time.Now().Add(-d.config.Retention))
+	if err != nil {
+		log.Printf("Failed to delete delivered webhook events: %v\n", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Deleted %d delivered webhook event(s) past their retention\n", deleted)
+	}
+}
+
+// SignWebhookPayload returns the hex HMAC-SHA256 of timestamp + "." + payload with the webhook secret,
+// receivers compute the same to verify the X-Webhook-Signature header
+func SignWebhookPayload(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
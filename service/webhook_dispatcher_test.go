@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"parking-lot/config"
+	"parking-lot/domain"
+)
+
+func TestWebhookDispatcherSignsDeliveries(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, storage *testStorage) {
+		ctx := context.Background()
+		receiver := newWebhookReceiver(t, func(attempt int) int { return http.StatusOK })
+		webhookRepo, webhook := newWebhookWithEvent(t, storage, receiver.URL)
+		dispatcher := NewWebhookDispatcher(webhookRepo, testWebhookConfig(3, time.Millisecond))
+
+		dispatcher.dispatchDue(ctx)
+
+		requests := receiver.received()
+		if len(requests) != 1 {
+			t.Fatalf("got %d requests, want 1", len(requests))
+		}
+		request := requests[0]
+		if event := request.header.Get("X-Webhook-Event"); event != string(domain.EventVehicleParked) {
+			t.Errorf("got event header %q, want %q", event, domain.EventVehicleParked)
+		}
+
+		// the receiver verifies the signature with nothing but the secret, the timestamp header and the body
+		mac := hmac.New(sha256.New, []byte(webhook.Secret))
+		mac.Write([]byte(request.header.Get("X-Webhook-Timestamp") + "."))
+		mac.Write(request.body)
+		want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		if signature := request.header.Get("X-Webhook-Signature"); signature != want {
+			t.Errorf("got signature %q, want %q", signature, want)
+		}
+
+		assertDeliveryStatus(t, webhookRepo, domain.DeliveryDelivered, 1)
+	})
+}
+
+func TestWebhookDispatcherRetriesFailedDeliveries(t *testing.T) {
+	const initialBackoff = 50 * time.Millisecond
+
+	forEachStorage(t, func(t *testing.T, storage *testStorage) {
+		ctx := context.Background()
+		receiver := newWebhookReceiver(t, func(attempt int) int {
+			if attempt <= 2 {
+				return http.StatusServiceUnavailable
+			}
+			return http.StatusOK
+		})
+		webhookRepo, _ := newWebhookWithEvent(t, storage, receiver.URL)
+		dispatcher := NewWebhookDispatcher(webhookRepo, testWebhookConfig(5, initialBackoff))
+
+		// every failed attempt postpones the delivery by a backoff that doubles
+		for attempt, backoff := range []time.Duration{initialBackoff, 2 * initialBackoff} {
+			before := time.Now()
+			dispatcher.dispatchDue(ctx)
+
+			delivery := assertDeliveryStatus(t, webhookRepo, domain.DeliveryPending, 1)[0]
+			if delivery.Attempts != attempt+1 || !strings.Contains(delivery.LastError, "503") {
+				t.Fatalf("got %d attempts with last error %q, want %d attempts failed with 503", delivery.Attempts, delivery.LastError, attempt+1)
+			}
+			if wait := delivery.NextAttemptAt.Sub(before); wait < backoff {
+				t.Errorf("got next attempt after %s, want a backoff of at least %s", wait, backoff)
+			}
+
+			// the delivery is not due before its backoff ends
+			dispatcher.dispatchDue(ctx)
+			if received := len(receiver.received()); received != attempt+1 {
+				t.Fatalf("got %d requests during the backoff, want %d", received, attempt+1)
+			}
+
+			time.Sleep(time.Until(delivery.NextAttemptAt) + time.Millisecond)
+		}
+
+		dispatcher.dispatchDue(ctx)
+
+		delivery := assertDeliveryStatus(t, webhookRepo, domain.DeliveryDelivered, 1)[0]
+		if delivery.Attempts != 3 {
+			t.Errorf("got %d attempts, want 3", delivery.Attempts)
+		}
+	})
+}
+
+func TestWebhookDispatcherGivesUpAfterMaxAttempts(t *testing.T) {
+	const maxAttempts = 3
+
+	forEachStorage(t, func(t *testing.T, storage *testStorage) {
+		ctx := context.Background()
+		receiver := newWebhookReceiver(t, func(attempt int) int { return http.StatusInternalServerError })
+		webhookRepo, _ := newWebhookWithEvent(t, storage, receiver.URL)
+		dispatcher := NewWebhookDispatcher(webhookRepo, testWebhookConfig(maxAttempts, time.Millisecond))
+
+		// more rounds than attempts, the dead delivery must not be sent again
+		for range maxAttempts + 2 {
+			dispatcher.dispatchDue(ctx)
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if received := len(receiver.received()); received != maxAttempts {
+			t.Errorf("got %d requests, want %d", received, maxAttempts)
+		}
+		delivery := assertDeliveryStatus(t, webhookRepo, domain.DeliveryDead, 1)[0]
+		if delivery.Attempts != maxAttempts || !strings.Contains(delivery.LastError, "500") {
+			t.Errorf("got %d attempts with last error %q, want %d attempts failed with 500", delivery.Attempts, delivery.LastError, maxAttempts)
+		}
+		assertDeliveryStatus(t, webhookRepo, domain.DeliveryPending, 0)
+	})
+}
+
+func TestRetryDeadDelivery(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, storage *testStorage) {
+		ctx := context.Background()
+		receiver := newWebhookReceiver(t, func(attempt int) int {
+			if attempt == 1 {
+				return http.StatusBadGateway
+			}
+			return http.StatusOK
+		})
+		webhookRepo, _ := newWebhookWithEvent(t, storage, receiver.URL)
+		webhookService := NewWebhookService(webhookRepo)
+		dispatcher := NewWebhookDispatcher(webhookRepo, testWebhookConfig(1, time.Millisecond))
+
+		dispatcher.dispatchDue(ctx)
+		dead := assertDeliveryStatus(t, webhookRepo, domain.DeliveryDead, 1)[0]
+
+		err := webhookService.RetryDeadDelivery(ctx, dead.ID+100)
+		assertErrorCode(t, err, domain.CodeDeliveryNotFound)
+
+		err = webhookService.RetryDeadDelivery(ctx, dead.ID)
+		if err != nil {
+			t.Fatalf("RetryDeadDelivery() error = %v", err)
+		}
+		assertDeliveryStatus(t, webhookRepo, domain.DeliveryDead, 0)
+
+		dispatcher.dispatchDue(ctx)
+
+		if received := len(receiver.received()); received != 2 {
+			t.Errorf("got %d requests, want 2", received)
+		}
+		assertDeliveryStatus(t, webhookRepo, domain.DeliveryDelivered, 1)
+
+		// only dead deliveries can be retried
+		err = webhookService.RetryDeadDelivery(ctx, dead.ID)
+		assertErrorCode(t, err, domain.CodeDeliveryNotFound)
+	})
+}
+
+// webhookReceiver is a webhook endpoint that records the requests it receives
+type webhookReceiver struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	requests []receivedRequest
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// newWebhookReceiver starts a webhook endpoint answering the nth request, counting from 1, with status(n)
+func newWebhookReceiver(t *testing.T, status func(attempt int) int) *webhookReceiver {
+	receiver := &webhookReceiver{}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		receiver.mutex.Lock()
+		receiver.requests = append(receiver.requests, receivedRequest{header: r.Header.Clone(), body: body})
+		attempt := len(receiver.requests)
+		receiver.mutex.Unlock()
+
+		w.WriteHeader(status(attempt))
+	}))
+	t.Cleanup(receiver.Close)
+
+	return receiver
+}
+
+func (r *webhookReceiver) received() []receivedRequest {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]receivedRequest(nil), r.requests...)
+}
+
+// newWebhookWithEvent subscribes a webhook at url to parked vehicles and publishes one such event
+func newWebhookWithEvent(t *testing.T, storage *testStorage, url string) (domain.WebhookRepository, *domain.Webhook) {
+	t.Helper()
+
+	ctx := context.Background()
+	webhookRepo := storage.repositories().webhook
+	webhookService := NewWebhookService(webhookRepo)
+
+	webhook, err := webhookService.CreateWebhook(ctx, url, []domain.EventType{domain.EventVehicleParked})
+	if err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+	err = webhookService.Publish(ctx, domain.EventVehicleParked, map[string]string{"license_plate": "AB123"})
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	return webhookRepo, webhook
+}
+
+// testWebhookConfig returns a dispatcher configuration with short waits
+func testWebhookConfig(maxAttempts int, initialBackoff time.Duration) config.WebhookConfig {
+	return config.WebhookConfig{
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: initialBackoff,
+		MaxBackoff:     time.Second,
+		Timeout:        5 * time.Second,
+		PollInterval:   time.Second,
+		Retention:      time.Hour,
+	}
+}
+
+// assertDeliveryStatus checks that count deliveries have the status and returns them
+func assertDeliveryStatus(t *testing.T, webhookRepo domain.WebhookRepository, status domain.DeliveryStatus, count int) []domain.WebhookDelivery {
+	t.Helper()
+
+	deliveries, err := webhookRepo.GetDeliveriesByStatus(context.Background(), status)
+	if err != nil {
+		t.Fatalf("error getting %s deliveries: %v", status, err)
+	}
+	if len(deliveries) != count {
+		t.Fatalf("got %d %s deliveries, want %d", len(deliveries), status, count)
+	}
+	return deliveries
+}
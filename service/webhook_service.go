@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"parking-lot/domain"
+)
+
+type webhookService struct {
+	webhookRepo domain.WebhookRepository
+}
+
+// NewWebhookService returns a webhook service that writes published events to the outbox of the webhook
+// repository, from where a WebhookDispatcher delivers them
+func NewWebhookService(webhookRepo domain.WebhookRepository) domain.WebhookService {
+	return &webhookService{
+		webhookRepo: webhookRepo,
+	}
+}
+
+func (s *webhookService) Publish(ctx context.Context, eventType domain.EventType, data any) error {
+	eventID, err := randomHex(16)
+	if err != nil {
+		return fmt.Errorf("error generating event ID: %w", err)
+	}
+
+	rawData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error encoding %s event: %w", eventType, err)
+	}
+
+	event := domain.Event{
+		ID:         "evt_" + eventID,
+		Type:       eventType,
+		OccurredAt: time.Now(),
+		Data:       rawData,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error encoding %s event: %w", eventType, err)
+	}
+
+	err = s.webhookRepo.EnqueueEvent(ctx, event, payload)
+	if err != nil {
+		return fmt.Errorf("error enqueueing %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+func (s *webhookService) CreateWebhook(ctx context.Context, webhookURL string, eventTypes []domain.EventType) (*domain.Webhook, error) {
+	parsed, err := url.Parse(webhookURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, domain.NewValidationError(domain.CodeInvalidWebhook, "webhook URL must be an absolute http or https URL")
+	}
+
+	if len(eventTypes) == 0 {
+		return nil, domain.NewValidationError(domain.CodeInvalidWebhook, "at least one event type is required, one of %v", domain.EventTypes)
+	}
+	for _, eventType := range eventTypes {
+		if !eventType.IsValid() {
+			return nil, domain.NewValidationError(domain.CodeInvalidWebhook, "invalid event type %q, must be %v", eventType, domain.EventTypes)
+		}
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, fmt.Errorf("error generating webhook secret: %w", err)
+	}
+
+	webhook := &domain.Webhook{
+		URL:        webhookURL,
+		EventTypes: slices.Compact(slices.Sorted(slices.Values(eventTypes))),
+		Secret:     "whsec_" + secret,
+	}
+	err = s.webhookRepo.CreateWebhook(ctx, webhook)
+	if err != nil {
+		return nil, fmt.Errorf("error creating webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+func (s *webhookService) GetWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	webhooks, err := s.webhookRepo.GetWebhooks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting webhooks: %w", err)
+	}
+
+	// the secret is only shown when the webhook is created
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	return webhooks, nil
+}
+
+func (s *webhookService) DeleteWebhook(ctx context.Context, id int64) error {
+	deleted, err := s.webhookRepo.DeleteWebhook(ctx, id)
+	if err != nil {
+		return fmt.Errorf("error deleting webhook: %w", err)
+	}
+	if !deleted {
+		return domain.NewNotFoundError(domain.CodeWebhookNotFound, "webhook %d not found", id)
+	}
+	return nil
+}
+
+func (s *webhookService) GetDeadDeliveries(ctx context.Context) ([]domain.WebhookDelivery, error) {
+	deliveries, err := s.webhookRepo.GetDeliveriesByStatus(ctx, domain.DeliveryDead)
+	if err != nil {
+		return nil, fmt.Errorf("error getting dead deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (s *webhookService) RetryDeadDelivery(ctx context.Context, id int64) error {
+	found, err := s.webhookRepo.RetryDeadDelivery(ctx, id, time.Now())
+	if err != nil {
+		return fmt.Errorf("error retrying delivery: %w", err)
+	}
+	if !found {
+		return domain.NewNotFoundError(domain.CodeDeliveryNotFound, "no dead delivery with ID %d", id)
+	}
+	return nil
+}
+
+func randomHex(size int) (string, error) {
+	b := make([]byte, size)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}